@@ -0,0 +1,269 @@
+// Command loadgen drives a running server's /v1 or /v2 delivery endpoint
+// and reports throughput and latency percentiles.
+//
+//	loadgen [-url URL] [-api v1|v2] [-concurrency N] [-duration D | -requests N]
+//	        [-apps LIST] [-countries LIST] [-os LIST] [-geo RATE] [-seed N]
+//	        [-capture FILE] [-out FILE] [-baseline FILE] [-o table|json]
+//	loadgen -write-catalogue FILE [-campaigns N] [-seed N]
+//
+// Requests are synthetic, drawn from weighted lists such as
+// "us:50,in:30,de", or replayed from a traffic capture file. Save a report
+// with -out and compare later runs against it with -baseline; loadgen then
+// exits with status 1 if any measure regressed by more than -tolerance.
+//
+// -write-catalogue writes a synthetic catalogue targeting the default
+// request vocabulary as a bulk JSON file, for "bulk import", and exits.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/bulk"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/latency"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/loadgen"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/synth"
+)
+
+func main() {
+	fs := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	url := fs.String("url", "http://localhost:8080", "base URL of the server")
+	api := fs.String("api", "v2", "delivery API: v1 or v2")
+	apiKey := fs.String("key", os.Getenv("LOADGEN_API_KEY"), "API key sent as X-API-Key")
+	concurrency := fs.Int("concurrency", 8, "requests in flight")
+	duration := fs.Duration("duration", 30*time.Second, "how long to run, unless -requests is set")
+	requests := fs.Int("requests", 0, "send this many requests, then stop")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of each request")
+	apps := fs.String("apps", "", "weighted apps, e.g. com.a:3,com.b (default: 1000 synthetic apps, Zipf-like)")
+	countries := fs.String("countries", "", "weighted countries, e.g. us:50,in:30,de (default: 30 countries, Zipf-like)")
+	oses := fs.String("os", "", "weighted operating systems (default: android:70,ios:25,web:5)")
+	geo := fs.Float64("geo", 0.2, "share of synthetic requests carrying lat/lon")
+	seed := fs.Uint64("seed", 1, "seed of the synthetic requests")
+	capturePath := fs.String("capture", "", "replay the requests of this traffic capture file instead")
+	outPath := fs.String("out", "", "write the JSON report to this file")
+	baselinePath := fs.String("baseline", "", "compare with the JSON report in this file")
+	tolerance := fs.Float64("tolerance", 0.1, "relative change tolerated before -baseline reports a regression")
+	format := fs.String("o", "table", "output: table or json")
+	cataloguePath := fs.String("write-catalogue", "", "write a synthetic catalogue as bulk JSON to this file and exit")
+	campaignCount := fs.Int("campaigns", 10000, "campaigns in the -write-catalogue catalogue")
+	fs.Parse(os.Args[1:])
+	if fs.NArg() != 0 || (*format != "table" && *format != "json") || *campaignCount < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *cataloguePath != "" {
+		if err := writeCatalogue(*cataloguePath, *campaignCount, *seed); err != nil {
+			log.Fatalf("❌ Failed to write catalogue: %v", err)
+		}
+		log.Printf("✅ Wrote %d synthetic campaigns to %s", *campaignCount, *cataloguePath)
+		return
+	}
+
+	cfg := loadgen.Config{
+		URL:         *url,
+		API:         *api,
+		APIKey:      *apiKey,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+		Seed:        *seed,
+	}
+	var src loadgen.Source
+	if *capturePath != "" {
+		f, err := os.Open(*capturePath)
+		if err != nil {
+			log.Fatalf("❌ Failed to open capture: %v", err)
+		}
+		reqs, err := loadgen.ReadCapture(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("❌ Failed to read capture: %v", err)
+		}
+		cfg.Source = "capture:" + *capturePath
+		src = loadgen.Recorded(reqs)
+	} else {
+		d, desc, err := distribution(*apps, *countries, *oses, *geo)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		cfg.Source = desc
+		src = loadgen.Synthetic(d, *seed)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("❌ Invalid run: %v", err)
+	}
+
+	var baseline *loadgen.Report
+	if *baselinePath != "" {
+		var err error
+		if baseline, err = readReport(*baselinePath); err != nil {
+			log.Fatalf("❌ Failed to read baseline: %v", err)
+		}
+	}
+
+	// Stop early on Ctrl-C and still report what was measured
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := &http.Client{
+		Timeout:   *timeout,
+		Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+	}
+	log.Printf("🚀 Sending %s traffic to %s/%s/delivery with %d worker(s)", cfg.Source, strings.TrimSuffix(cfg.URL, "/"), cfg.API, cfg.Concurrency)
+	report, err := loadgen.Run(ctx, client, cfg, src)
+	if err != nil {
+		log.Fatalf("❌ Load test failed: %v", err)
+	}
+
+	if *outPath != "" {
+		if err := writeReport(*outPath, report); err != nil {
+			log.Fatalf("❌ Failed to write report: %v", err)
+		}
+	}
+
+	var deltas []loadgen.Delta
+	if baseline != nil {
+		for _, m := range loadgen.Mismatches(baseline, report) {
+			log.Printf("⚠️  Run differs from the baseline in %s", m)
+		}
+		deltas = loadgen.Compare(baseline, report, *tolerance)
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Report   *loadgen.Report `json:"report"`
+			Baseline []loadgen.Delta `json:"baseline,omitempty"`
+		}{report, deltas})
+	} else {
+		err = printTable(report, deltas)
+	}
+	if err != nil {
+		log.Fatalf("❌ Failed to print report: %v", err)
+	}
+
+	for _, d := range deltas {
+		if d.Regressed {
+			log.Fatalf("❌ Regressed against %s", *baselinePath)
+		}
+	}
+}
+
+// distribution builds the synthetic distribution from the weighted list
+// flags, falling back to synth.DefaultDistribution, and describes it for
+// the report.
+func distribution(apps, countries, oses string, geo float64) (synth.Distribution, string, error) {
+	d := synth.DefaultDistribution()
+	d.GeoRate = geo
+	desc := []string{"synthetic"}
+	for _, f := range []struct {
+		name string
+		list string
+		dst  *[]synth.Weighted
+	}{
+		{"apps", apps, &d.Apps},
+		{"countries", countries, &d.Countries},
+		{"os", oses, &d.OSes},
+	} {
+		if f.list == "" {
+			continue
+		}
+		w, err := synth.ParseWeighted(f.list)
+		if err != nil {
+			return d, "", fmt.Errorf("invalid -%s: %w", f.name, err)
+		}
+		*f.dst = w
+		desc = append(desc, f.name+"="+f.list)
+	}
+	if geo != 0.2 {
+		desc = append(desc, "geo="+strconv.FormatFloat(geo, 'f', -1, 64))
+	}
+	return d, strings.Join(desc, " "), nil
+}
+
+// writeCatalogue writes the synthetic catalogue of n campaigns as a bulk
+// JSON file.
+func writeCatalogue(path string, n int, seed uint64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := bulk.EncodeJSON(f, bulk.Entries(synth.Catalogue(n, seed))); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readReport(path string) (*loadgen.Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report loadgen.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &report, nil
+}
+
+func writeReport(path string, report *loadgen.Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func printTable(report *loadgen.Report, deltas []loadgen.Delta) error {
+	ms := func(d time.Duration) string { return strconv.FormatFloat(latency.Milliseconds(d), 'f', 3, 64) }
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"requests", strconv.Itoa(report.Requests)},
+		{"elapsed_s", strconv.FormatFloat(report.Elapsed, 'f', 2, 64)},
+		{"throughput_rps", strconv.FormatFloat(report.Throughput, 'f', 1, 64)},
+		{"errors", strconv.Itoa(report.Errors())},
+		{"fill_rate", strconv.FormatFloat(report.FillRate, 'f', 3, 64)},
+		{"mean_ms", ms(report.Latency.Mean)},
+		{"p50_ms", ms(report.Latency.P50)},
+		{"p90_ms", ms(report.Latency.P90)},
+		{"p99_ms", ms(report.Latency.P99)},
+		{"max_ms", ms(report.Latency.Max)},
+	}
+	statuses := make([]string, 0, len(report.Status))
+	for status := range report.Status {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		rows = append(rows, [2]string{"status_" + status, strconv.Itoa(report.Status[status])})
+	}
+	fmt.Fprintln(tw, "METRIC\tVALUE")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	if len(deltas) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "METRIC\tBASELINE\tCURRENT\tCHANGE\t")
+		for _, d := range deltas {
+			mark := ""
+			if d.Regressed {
+				mark = "❌ regressed"
+			}
+			fmt.Fprintf(tw, "%s\t%.3f\t%.3f\t%+.1f%%\t%s\n", d.Metric, d.Baseline, d.Current, d.Change*100, mark)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,130 @@
+// Command migrate-countries rewrites include_country/exclude_country in
+// targeting_rules to ISO 3166-1 alpha-2 codes (e.g. "canada" -> "ca").
+//
+// It runs in a single transaction. Rules containing values that cannot be
+// resolved are reported and, unless -drop-unknown is set, abort the run.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/config"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/countries"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report changes without writing them")
+	dropUnknown := flag.Bool("drop-unknown", false, "remove unresolvable values instead of aborting")
+	flag.Parse()
+
+	cfg, err := config.LoadEnv()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to DB: %v", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Fatalf("❌ Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	changed, unknown, err := migrate(tx, *dropUnknown)
+	if err != nil {
+		log.Fatalf("❌ Migration failed: %v", err)
+	}
+
+	if unknown > 0 && !*dropUnknown {
+		log.Fatalf("❌ %d rule(s) contain unknown countries; fix them or rerun with -drop-unknown", unknown)
+	}
+	if *dryRun {
+		log.Printf("🔍 Dry run: %d rule(s) would be updated", changed)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatalf("❌ Failed to commit: %v", err)
+	}
+	log.Printf("✅ Updated %d rule(s)", changed)
+}
+
+// migrate normalises every rule inside tx and returns how many rules changed
+// and how many contained unknown values.
+func migrate(tx *sql.Tx, dropUnknown bool) (changed, unknown int, err error) {
+	rows, err := tx.Query(`SELECT id, cid, include_country, exclude_country FROM targeting_rules ORDER BY id FOR UPDATE`)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	type rule struct {
+		id               int64
+		cid              string
+		include, exclude []string
+	}
+	var rules []rule
+	for rows.Next() {
+		var r rule
+		if err := rows.Scan(&r.id, &r.cid, (*pq.StringArray)(&r.include), (*pq.StringArray)(&r.exclude)); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		rules = append(rules, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, r := range rules {
+		include, errInclude := countries.NormalizeList(r.include)
+		exclude, errExclude := countries.NormalizeList(r.exclude)
+		for _, e := range []error{errInclude, errExclude} {
+			if e != nil {
+				fmt.Fprintf(os.Stderr, "rule %d (%s): %v\n", r.id, r.cid, e)
+			}
+		}
+		if errInclude != nil || errExclude != nil {
+			unknown++
+			if !dropUnknown {
+				continue
+			}
+		}
+
+		if slices.Equal(include, r.include) && slices.Equal(exclude, r.exclude) &&
+			(include == nil) == (r.include == nil) && (exclude == nil) == (r.exclude == nil) {
+			continue
+		}
+		fmt.Printf("rule %d (%s): include_country %v -> %v, exclude_country %v -> %v\n",
+			r.id, r.cid, r.include, include, r.exclude, exclude)
+
+		// Recorded in the campaign's history like any other rule edit
+		_, err := campaigns.RecordChange(tx, r.cid, "migrate-countries", "rules.normalize_countries", func() error {
+			_, err := tx.Exec(`UPDATE targeting_rules SET include_country = $1, exclude_country = $2 WHERE id = $3`,
+				nullableArray(include), nullableArray(exclude), r.id)
+			return err
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		changed++
+	}
+	return changed, unknown, nil
+}
+
+func nullableArray(values []string) interface{} {
+	if values == nil {
+		return nil
+	}
+	return pq.StringArray(values)
+}
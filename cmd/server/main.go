@@ -3,8 +3,8 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"log"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,42 +14,113 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	_ "github.com/lib/pq"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/arunbajpai35/greedygame-targeting-engine/db/migrations"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/admin"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/breaker"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/config"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/delivery"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/endpoints"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/geo"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/health"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/migrate"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/ratelimit"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/replay"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/service"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/tracing"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/tracking"
 	transport "github.com/arunbajpai35/greedygame-targeting-engine/internal/transport/http"
 )
 
 func main() {
-	// Get database connection string from environment or use default
-	dbConnStr := getDBConnectionString()
+	printConfig := flag.Bool("print-config", false, "print the effective configuration (secrets redacted) and exit")
+	migrateFlag := flag.Bool("migrate", false, "shorthand for -migrate_on_start=true")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fatal("failed to load config", logging.Err(err))
+	}
+	if *printConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			fatal("failed to print config", logging.Err(err))
+		}
+		return
+	}
+
+	// Structured logs; the standard logger writes through the same handler
+	logger := logging.New(os.Stderr, cfg.Log)
+	slog.SetDefault(logger)
+
+	// Prometheus metrics, served on /metrics and passed to what records them
+	registry := metrics.New(metrics.WithLabelLimits(cfg.Metrics.MaxCampaigns, cfg.Metrics.MaxCountries, cfg.Metrics.MaxOS))
+
+	// Tracing (spans are exported only when an exporter is configured)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("failed to set up tracing", logging.Err(err))
+	}
 
 	// Connect to PostgreSQL
-	db, err := sql.Open("postgres", dbConnStr)
+	db, err := cfg.DB.Open()
 	if err != nil {
-		log.Fatalf("❌ Failed to connect to DB: %v", err)
+		fatal("failed to open database", logging.Err(err))
 	}
 	defer db.Close()
 
-	// Test database connection
+	// Test database connection. A server that can restore a persisted
+	// snapshot starts without it and serves stale until it recovers.
+	coldStart := cfg.Snapshot.ServeStale && cfg.Snapshot.PersistPath != ""
 	if err := db.Ping(); err != nil {
-		log.Fatalf("❌ Failed to ping DB: %v", err)
+		if !coldStart {
+			fatal("failed to ping database", "db", cfg.DB.String(), logging.Err(err))
+		}
+		slog.Warn("database unavailable, starting from persisted snapshot", "db", cfg.DB.String(), logging.Err(err))
+	} else {
+		slog.Info("database connection established", "db", cfg.DB.String(), "max_open_conns", cfg.DB.MaxOpenConns)
+		if cfg.MigrateOnStart || *migrateFlag {
+			runMigrations(db)
+		}
+	}
+
+	// Background workers (snapshot refresh, key refresh, usage audit)
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// API key authentication
+	keysPath := ""
+	if coldStart {
+		keysPath = cfg.Snapshot.PersistPath + ".keys"
 	}
-	log.Println("✅ Database connection established")
+	protect, keyring, auditor := setupAuth(bgCtx, db, cfg.Auth, keysPath)
+
+	// Per-publisher rate limiting (runs after auth so the key is known)
+	limit := rateLimitMiddleware(bgCtx, cfg.RateLimit, registry)
+
+	// Sampled capture of delivery requests for targetingctl replay
+	capture, recorder := captureMiddleware(bgCtx, cfg.Capture)
 
 	// Create router with middleware
 	r := chi.NewRouter()
 
 	// Add middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(60 * time.Second))
+	if cfg.Server.TrustProxyHeaders {
+		r.Use(middleware.RealIP)
+	}
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware(logger, cfg.Log.DeliverySampleRate))
+	r.Use(middleware.Recoverer)
+	if mw := geoMiddleware(cfg.Geo); mw != nil {
+		r.Use(mw)
+	}
+	r.Use(middleware.Timeout(time.Duration(cfg.Server.RequestTimeout)))
 
-	// Health check
+	// Health check (kept for existing monitors; see /livez and /readyz)
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -57,34 +128,110 @@ func main() {
 	})
 
 	// Prometheus metrics endpoint
-	r.Handle("/metrics", promhttp.Handler())
+	r.With(protect(auth.PermReporting)).Handle("/metrics", registry.Handler())
+
+	// Second-price auctions between matched campaigns
+	auctions := auctionConfig(cfg.Auction)
+
+	// Impression and click tracking URLs on served campaigns
+	tracker := trackingIssuer(cfg.Tracking)
+
+	// Audience segments, shared by per-request matching and the snapshot
+	segmentCache := segments.NewCache(registry)
+
+	// In-memory campaign snapshot backing /v2/explain and the fallback of
+	// both delivery APIs while the database is down
+	snapshots := loadSnapshots(db, cfg.Snapshot, segmentCache, registry)
+	refreshInterval := time.Duration(cfg.Snapshot.RefreshInterval)
+	go snapshots.Run(bgCtx, refreshInterval)
+	svc := service.NewDeliveryService(snapshots, auctions, registry)
+
+	// Delivery settings shared by v1 and v2, which query the database per
+	// request through one breaker
+	deliveryCfg := service.Config{
+		QueryTimeout: time.Duration(cfg.DB.QueryTimeout),
+		Auctions:     auctions,
+		Segments:     segmentCache,
+		Metrics:      registry,
+		Tracking:     tracker,
+	}
+	if cfg.Snapshot.ServeStale {
+		deliveryCfg.Fallback = service.Fallback{
+			Breaker:  breaker.New("db", cfg.DB.BreakerFailures, time.Duration(cfg.DB.BreakerCooldown), registry),
+			Snapshot: svc,
+		}
+	}
 
 	// API routes v1 (legacy/tests)
 	r.Route("/v1", func(r chi.Router) {
-		r.Get("/delivery", delivery.HandleDeliveryRequest(db))
+		r.Group(func(r chi.Router) {
+			r.Use(protect(auth.PermDelivery))
+			r.Use(limit)
+			r.Use(capture("v1"))
+			r.Get("/delivery", delivery.HandleDeliveryRequest(db, deliveryCfg))
+		})
+		// Called by devices, which hold no API key: the token authenticates
+		if tracker != nil {
+			r.With(limit).Get("/track/{event}", tracking.HandleEvent(tracker, registry))
+		}
 	})
 
+	// Liveness and readiness probes
+	schema, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		fatal("failed to load migrations", logging.Err(err))
+	}
+	database := health.Database(db)
+	if cfg.Snapshot.ServeStale {
+		// Delivery keeps serving from the snapshot while the database is down
+		database = health.Degraded(database)
+	}
+	checks := []health.Check{
+		database,
+		health.Snapshot(snapshots, time.Duration(cfg.Snapshot.MaxAge), cfg.Snapshot.ServeStale),
+		health.Migrations(db, schema.Latest(), cfg.Snapshot.ServeStale),
+	}
+	if keyring != nil {
+		checks = append(checks, health.Keys(keyring))
+	}
+	checker := health.NewChecker(time.Duration(cfg.Server.HealthCheckTimeout), checks...)
+	r.Get("/livez", health.HandleLive())
+	r.Get("/readyz", checker.HandleReady())
+
+	// Admin API, never served without authentication
+	if cfg.Auth.Enabled {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(protect(auth.PermAdmin))
+			admin.RegisterRoutes(r, db)
+		})
+	}
+
 	// API routes v2 (go-kit)
-	svc := service.NewDeliveryService(db)
-	eps := endpoints.Endpoints{Delivery: endpoints.MakeDeliveryEndpoint(svc)}
-	r.Route("/", func(r chi.Router) {
-		transport.RegisterV2Routes(r, eps)
+	eps := endpoints.Endpoints{
+		Delivery: tracing.EndpointMiddleware("endpoint.Delivery")(endpoints.MakeDeliveryEndpoint(service.NewDatabase(db, "v2", deliveryCfg), registry)),
+		Explain:  tracing.EndpointMiddleware("endpoint.Explain")(endpoints.MakeExplainEndpoint(svc)),
+	}
+	r.Group(func(r chi.Router) {
+		r.Use(protect(auth.PermDelivery))
+		r.Use(limit)
+		r.Use(capture("v2"))
+		transport.RegisterV2Routes(r, eps, registry)
 	})
 
 	// Create server
 	srv := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Server.Addr,
 		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("🚀 Server starting on :8080")
+		slog.Info("server starting", "addr", cfg.Server.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("❌ Server failed to start: %v", err)
+			fatal("server failed to start", logging.Err(err))
 		}
 	}()
 
@@ -93,36 +240,212 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("🛑 Server shutting down...")
+	slog.Info("server shutting down")
+
+	// Fail readiness first so load balancers stop sending new requests
+	checker.Drain()
+	if delay := time.Duration(cfg.Server.DrainDelay); delay > 0 {
+		slog.Info("draining before shutdown", "delay", delay.String())
+		time.Sleep(delay)
+	}
 
 	// Create a deadline for server shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
 	defer cancel()
 
 	// Attempt graceful shutdown
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("❌ Server forced to shutdown: %v", err)
+		fatal("server forced to shut down", logging.Err(err))
+	}
+
+	if auditor != nil {
+		if err := auditor.Flush(); err != nil {
+			slog.Error("failed to flush API key usage", logging.Err(err))
+		}
+	}
+
+	if recorder != nil {
+		if err := recorder.Flush(); err != nil {
+			slog.Error("failed to write captured delivery requests", logging.Err(err))
+		}
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("failed to flush traces", logging.Err(err))
 	}
 
-	log.Println("✅ Server exited gracefully")
+	slog.Info("server exited gracefully")
 }
 
-func getDBConnectionString() string {
-	// Get database configuration from environment variables
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	dbname := getEnv("DB_NAME", "targeting_db")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "password")
-	sslmode := getEnv("DB_SSL_MODE", "disable")
-
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		user, password, host, port, dbname, sslmode)
+// runMigrations applies the embedded schema migrations. Replicas starting
+// together wait on each other through the runner's advisory lock.
+func runMigrations(db *sql.DB) {
+	runner, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		fatal("failed to load migrations", logging.Err(err))
+	}
+	if _, err := runner.Up(context.Background()); err != nil {
+		fatal("failed to migrate database", logging.Err(err))
+	}
+	slog.Info("database schema migrated", "version", runner.Latest())
+}
+
+// loadSnapshots loads the first campaign snapshot from the database or,
+// failing that, from the persisted file.
+func loadSnapshots(db *sql.DB, cfg config.Snapshot, segmentCache *segments.Cache, registry *metrics.Registry) *campaigns.SnapshotStore {
+	opts := []campaigns.SnapshotOption{campaigns.WithSegmentCache(segmentCache)}
+	if cfg.PersistPath != "" {
+		opts = append(opts, campaigns.WithPersistPath(cfg.PersistPath))
+	}
+	snapshots := campaigns.NewSnapshotStore(db, registry, opts...)
+
+	err := snapshots.Refresh(context.Background())
+	if err == nil {
+		slog.Info("campaign snapshot loaded", "refresh_interval", cfg.RefreshInterval.String())
+		return snapshots
+	}
+	if !cfg.ServeStale || cfg.PersistPath == "" {
+		fatal("failed to load campaign snapshot", logging.Err(err))
+	}
+	if fileErr := snapshots.LoadFile(); fileErr != nil {
+		fatal("failed to load campaign snapshot", logging.Err(err), "path", cfg.PersistPath, "file_error", fileErr.Error())
+	}
+	slog.Warn("serving stale campaign snapshot", "path", cfg.PersistPath,
+		"loaded_at", snapshots.Current().LoadedAt, logging.Err(err))
+	return snapshots
+}
+
+// setupAuth returns a middleware factory enforcing API key permissions, the
+// keyring and the usage auditor. With auth disabled, for local development
+// only, delivery and metrics stay open, the admin API is not mounted and
+// the keyring is nil. Keys are saved to persistPath, if set, and restored
+// from it when the database is unreachable; without either the server
+// starts with only the bootstrap token and keeps refreshing.
+func setupAuth(ctx context.Context, db *sql.DB, cfg config.Auth, persistPath string) (func(auth.Permission) func(http.Handler) http.Handler, *auth.Keyring, *auth.Auditor) {
+	if !cfg.Enabled {
+		slog.Warn("API key authentication disabled by AUTH_ENABLED=false: delivery and metrics are open and /admin is not mounted; use only for local development")
+		return func(auth.Permission) func(http.Handler) http.Handler {
+			return auth.Disabled
+		}, nil, nil
+	}
+
+	var opts []auth.KeyringOption
+	if persistPath != "" {
+		opts = append(opts, auth.WithKeyPersistPath(persistPath))
+	}
+	keyring := auth.NewKeyring(db, cfg.AdminAPIKey.Value(), opts...)
+	if err := keyring.Refresh(); err != nil {
+		if persistPath == "" {
+			slog.Warn("failed to load API keys, accepting only the bootstrap key until a refresh succeeds", logging.Err(err))
+		} else if fileErr := keyring.LoadFile(); fileErr != nil {
+			slog.Warn("failed to load API keys, accepting only the bootstrap key until a refresh succeeds",
+				logging.Err(err), "path", persistPath, "file_error", fileErr.Error())
+		} else {
+			loadedAt, _ := keyring.Status()
+			slog.Warn("serving persisted API keys", "path", persistPath, "loaded_at", loadedAt, logging.Err(err))
+		}
+	}
+	refreshInterval := time.Duration(cfg.RefreshInterval)
+	go keyring.Run(ctx, refreshInterval)
+
+	auditor := auth.NewAuditor(db)
+	go auditor.Run(ctx, time.Minute)
+
+	slog.Info("API key authentication enabled", "refresh_interval", refreshInterval.String())
+	return auth.NewAuthenticator(keyring, auditor).Require, keyring, auditor
+}
+
+// rateLimitMiddleware loads the configured tiers. Without a tier file
+// requests are not limited.
+func rateLimitMiddleware(ctx context.Context, cfg config.RateLimit, registry *metrics.Registry) func(http.Handler) http.Handler {
+	path := cfg.ConfigPath
+	if path == "" {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	tiers, err := ratelimit.LoadConfig(path)
+	if err != nil {
+		fatal("failed to load rate limit config", logging.Err(err))
+	}
+	store := ratelimit.NewMemoryStore()
+	go store.Run(ctx, time.Minute)
+
+	slog.Info("rate limiting enabled", "tiers", len(tiers.Tiers), "default_tier", tiers.DefaultTier)
+	return ratelimit.NewLimiter(tiers, store, registry).Middleware
+}
+
+// captureMiddleware returns the capture middleware factory and the
+// recorder to flush on shutdown. Without a capture path nothing is
+// captured and the recorder is nil.
+func captureMiddleware(ctx context.Context, cfg config.Capture) (func(api string) func(http.Handler) http.Handler, *replay.Recorder) {
+	if cfg.Path == "" {
+		return func(string) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler { return next }
+		}, nil
+	}
+
+	f, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		fatal("failed to open capture file", "path", cfg.Path, logging.Err(err))
+	}
+	var opts []replay.RecorderOption
+	if cfg.RawDeviceIDs {
+		opts = append(opts, replay.WithRawDeviceIDs())
+	}
+	if cfg.PreciseLocation {
+		opts = append(opts, replay.WithPreciseLocation())
+	}
+	recorder := replay.NewRecorder(f, cfg.SampleRate, opts...)
+	go recorder.Run(ctx, time.Second)
+
+	slog.Info("delivery capture enabled", "path", cfg.Path, "sample_rate", cfg.SampleRate,
+		"raw_device_ids", cfg.RawDeviceIDs, "precise_location", cfg.PreciseLocation)
+	return recorder.Middleware, recorder
+}
+
+// auctionConfig converts the auction settings. Without auctions delivery
+// returns every matched campaign.
+func auctionConfig(cfg config.Auction) auction.Config {
+	if !cfg.Enabled {
+		return auction.Config{}
+	}
+	slog.Info("second-price auctions enabled", "increment_cpm", cfg.IncrementCPM)
+	return auction.Config{Enabled: true, IncrementCPM: cfg.IncrementCPM}
+}
+
+// trackingIssuer returns the tracking URL issuer, or nil when no tracking
+// secret is configured.
+func trackingIssuer(cfg config.Tracking) *tracking.Issuer {
+	if cfg.Secret.Value() == "" {
+		return nil
+	}
+	issuer, err := tracking.NewIssuer(cfg.Secret.Value(), cfg.BaseURL, time.Duration(cfg.MaxAge))
+	if err != nil {
+		fatal("failed to set up tracking", logging.Err(err))
+	}
+	slog.Info("tracking URLs enabled", "base_url", cfg.BaseURL, "max_age", time.Duration(cfg.MaxAge).String())
+	return issuer
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// geoMiddleware returns the IP geolocation middleware, or nil when no geo
+// database is configured.
+func geoMiddleware(cfg config.Geo) func(http.Handler) http.Handler {
+	if cfg.DBPath == "" {
+		return nil
+	}
+
+	// Validated by config.Load.
+	precedence, _ := geo.ParsePrecedence(cfg.Precedence)
+	resolver, err := geo.Open(cfg.DBPath)
+	if err != nil {
+		fatal("failed to load geo database", logging.Err(err))
 	}
-	return defaultValue
+	slog.Info("geo database loaded", "path", cfg.DBPath, "precedence", string(precedence))
+	return geo.Middleware(resolver, precedence)
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/bulk"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/lifecycle"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func (c *cli) campaigns(args []string) error {
+	if len(args) == 0 {
+		return usageError("campaigns list|show|create|pause|resume")
+	}
+	switch args[0] {
+	case "list":
+		return c.listCampaigns(args[1:])
+	case "show":
+		return c.showCampaign(args[1:])
+	case "create":
+		return c.createCampaign(args[1:])
+	case "pause":
+		return c.transition(lifecycle.ActionPause, args[1:])
+	case "resume":
+		return c.transition(lifecycle.ActionResume, args[1:])
+	default:
+		return usageError("campaigns list|show|create|pause|resume")
+	}
+}
+
+func (c *cli) listCampaigns(args []string) error {
+	fs := flag.NewFlagSet("campaigns list", flag.ExitOnError)
+	status := fs.String("status", "", "only campaigns in this status")
+	advertiser := fs.String("advertiser", "", "only this advertiser's campaigns")
+	fs.Parse(args)
+
+	if *status != "" {
+		s, err := lifecycle.ParseStatus(*status)
+		if err != nil {
+			return err
+		}
+		*status = s
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	all, err := campaigns.ListCampaigns(context.Background(), db, *advertiser)
+	if err != nil {
+		return err
+	}
+	list := []models.Campaign{}
+	for _, camp := range all {
+		if *status == "" || camp.Status == *status {
+			list = append(list, camp)
+		}
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, camp := range list {
+		rows = append(rows, []string{camp.ID, camp.Name, camp.Status, orDash(camp.AdvertiserID), formatBid(camp.BidCPM)})
+	}
+	return c.out.print(models.NewCampaignRecords(list), []string{"CID", "NAME", "STATUS", "ADVERTISER", "BID_CPM"}, rows)
+}
+
+func (c *cli) showCampaign(args []string) error {
+	if len(args) != 1 {
+		return usageError("campaigns show CID")
+	}
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	camp, err := campaigns.GetCampaignByID(db, args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("campaign %s not found", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	rules, err := campaigns.GetTargetingRules(db, camp.ID)
+	if err != nil {
+		return err
+	}
+	if rules == nil {
+		rules = []models.TargetingRule{}
+	}
+
+	rows := [][]string{
+		{"cid", camp.ID},
+		{"name", camp.Name},
+		{"status", camp.Status},
+		{"img", orDash(camp.Img)},
+		{"cta", orDash(camp.CTA)},
+		{"advertiser_id", orDash(camp.AdvertiserID)},
+		{"categories", orDash(strings.Join(camp.Categories, ","))},
+		{"bid_cpm", formatBid(camp.BidCPM)},
+		{"creative_rotation", orDash(camp.CreativeRotation)},
+	}
+	for i := range rules {
+		for _, line := range describeRule(&rules[i]) {
+			rows = append(rows, []string{fmt.Sprintf("rule %d", i+1), line})
+		}
+	}
+	return c.out.print(bulk.Entry{Campaign: *camp, Rules: rules}, []string{"FIELD", "VALUE"}, rows)
+}
+
+func (c *cli) createCampaign(args []string) error {
+	fs := flag.NewFlagSet("campaigns create", flag.ExitOnError)
+	var camp models.Campaign
+	fs.StringVar(&camp.ID, "cid", "", "campaign ID (required)")
+	fs.StringVar(&camp.Name, "name", "", "campaign name (required)")
+	fs.StringVar(&camp.Img, "img", "", "image URL")
+	fs.StringVar(&camp.CTA, "cta", "", "call to action")
+	fs.StringVar(&camp.AdvertiserID, "advertiser", "", "owning advertiser ID")
+	fs.Float64Var(&camp.BidCPM, "bid", 0, "auction bid per thousand impressions")
+	categories := fs.String("categories", "", "comma-separated IAB categories")
+	rulesFile := fs.String("rules", "", "JSON file with the campaign's targeting rules")
+	fs.Parse(args)
+
+	if *categories != "" {
+		camp.Categories = strings.Split(*categories, ",")
+	}
+	entries := []bulk.Entry{{Campaign: camp, Row: 1}}
+	if *rulesFile != "" {
+		rules, err := readRules(*rulesFile)
+		if err != nil {
+			return err
+		}
+		entries[0].Rules = rules
+	}
+	if errs := bulk.Validate(entries); len(errs) > 0 {
+		return errors.New(errs[0].Error)
+	}
+	camp = entries[0].Campaign
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := campaigns.CreateCampaign(db, camp, c.actor); err != nil {
+		return err
+	}
+	if entries[0].Rules != nil {
+		if err := campaigns.ReplaceTargetingRules(db, camp.ID, entries[0].Rules, c.actor); err != nil {
+			return err
+		}
+	}
+	created, err := campaigns.GetCampaignByID(db, camp.ID)
+	if err != nil {
+		return err
+	}
+	return c.out.print(models.NewCampaignRecord(*created), []string{"CID", "NAME", "STATUS"},
+		[][]string{{created.ID, created.Name, created.Status}})
+}
+
+func (c *cli) transition(action lifecycle.Action, args []string) error {
+	fs := flag.NewFlagSet("campaigns "+string(action), flag.ExitOnError)
+	reason := fs.String("reason", "", "reason recorded with the status change")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return usageError("campaigns %s [-reason R] CID", action)
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	t, err := campaigns.TransitionCampaign(db, fs.Arg(0), action, c.actor, *reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("campaign %s not found", fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	return c.out.print(t, []string{"CID", "FROM", "TO", "ACTION"},
+		[][]string{{t.CampaignID, t.From, t.To, string(t.Action)}})
+}
+
+func formatBid(bid float64) string {
+	if bid == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(bid, 'f', -1, 64)
+}
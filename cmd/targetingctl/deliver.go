@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/bulk"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/countries"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/service"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// deliver runs a delivery request through the snapshot delivery service
+// behind the outage fallback and /v2/explain, against a snapshot loaded
+// from the database or built from a bulk export file.
+func (c *cli) deliver(args []string) error {
+	fs := flag.NewFlagSet("deliver", flag.ExitOnError)
+	fixture := fs.String("fixture", "", "bulk export file (JSON or CSV) to serve from instead of the DB")
+	explain := fs.Bool("explain", false, "explain why each campaign did or did not match")
+	withAuction := fs.Bool("auction", false, "run the second-price auction on the matches")
+	var req models.DeliveryRequest
+	fs.StringVar(&req.App, "app", "", "app ID (required)")
+	fs.StringVar(&req.Country, "country", "", "country (required)")
+	fs.StringVar(&req.OS, "os", "", "OS (required)")
+	fs.StringVar(&req.Region, "region", "", "region")
+	fs.StringVar(&req.City, "city", "", "city")
+	fs.StringVar(&req.DeviceID, "device", "", "device ID")
+	fs.StringVar(&req.PlacementID, "placement", "", "placement ID (DB only)")
+	fs.StringVar(&req.Format, "format", "", "creative format")
+	fs.StringVar(&req.Size, "size", "", "creative size, WxH")
+	lat := fs.String("lat", "", "latitude")
+	lon := fs.String("lon", "", "longitude")
+	fs.Parse(args)
+
+	if err := completeRequest(&req, *lat, *lon); err != nil {
+		return err
+	}
+
+	var snapshots *campaigns.SnapshotStore
+	if *fixture != "" {
+		snap, err := fixtureSnapshot(*fixture)
+		if err != nil {
+			return err
+		}
+		snapshots = campaigns.NewSnapshotStore(nil, nil)
+		snapshots.Set(snap)
+	} else {
+		db, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		snapshots = campaigns.NewSnapshotStore(db, nil)
+		if err := snapshots.Refresh(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	svc := service.NewDeliveryService(snapshots, auction.Config{Enabled: *withAuction, IncrementCPM: auction.DefaultIncrementCPM}, nil)
+	if *explain {
+		explanations, err := svc.Explain(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(explanations))
+		for _, e := range explanations {
+			matched := "no"
+			if e.Matched {
+				matched = "yes"
+			}
+			rows = append(rows, []string{e.CampaignID, matched, orDash(e.Reason)})
+		}
+		return c.out.print(explanations, []string{"CID", "MATCHED", "REASON"}, rows)
+	}
+
+	matched, err := svc.Deliver(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	if matched == nil {
+		matched = []models.Campaign{}
+	}
+	rows := make([][]string, 0, len(matched))
+	for _, m := range matched {
+		creative, price := "-", "-"
+		if m.Creative != nil {
+			creative = m.Creative.ID
+		}
+		if m.Auction != nil {
+			price = formatBid(m.Auction.PriceCPM)
+		}
+		rows = append(rows, []string{m.ID, m.Name, creative, orDash(m.CTA), price})
+	}
+	return c.out.print(matched, []string{"CID", "NAME", "CREATIVE", "CTA", "PRICE_CPM"}, rows)
+}
+
+// completeRequest validates and normalises req as /v1/delivery does.
+func completeRequest(req *models.DeliveryRequest, lat, lon string) error {
+	req.App = strings.TrimSpace(req.App)
+	req.OS = strings.ToLower(strings.TrimSpace(req.OS))
+	if req.App == "" || req.Country == "" || req.OS == "" {
+		return usageError("deliver [-fixture FILE] -app A -country C -os O [...]")
+	}
+	country, ok := countries.Normalize(req.Country)
+	if !ok {
+		return fmt.Errorf("invalid country %q", req.Country)
+	}
+	req.Country = country
+
+	var err error
+	if req.Lat, req.Lon, err = targeting.ParseCoordinates(lat, lon); err != nil {
+		return err
+	}
+	if req.Format != "" {
+		if req.Format, err = creatives.ParseFormat(req.Format); err != nil {
+			return err
+		}
+	}
+	if req.Size != "" {
+		if req.Size, err = creatives.ParseSize(req.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixtureSnapshot builds a snapshot from a bulk export file. Fixtures hold
+// no creatives or placements, so campaigns serve their own img and cta.
+func fixtureSnapshot(name string) (*campaigns.Snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	format := bulk.FormatJSON
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		format = bulk.FormatCSV
+	}
+	entries, rowErrs, err := bulk.Decode(format, f)
+	if err != nil {
+		return nil, err
+	}
+	rowErrs = append(rowErrs, bulk.Validate(entries)...)
+	if len(rowErrs) > 0 {
+		e := rowErrs[0]
+		return nil, fmt.Errorf("%s: row %d: %s", name, e.Row, e.Error)
+	}
+
+	var list []models.Campaign
+	var rules []models.TargetingRule
+	for _, e := range entries {
+		if e.Status == "" {
+			return nil, fmt.Errorf("%s: campaign %s has no status", name, e.ID)
+		}
+		list = append(list, e.Campaign)
+		rules = append(rules, e.Rules...)
+	}
+	if len(list) == 0 {
+		return nil, errors.New(name + ": no campaigns")
+	}
+	return &campaigns.Snapshot{
+		Matcher:  targeting.NewMatcher(list, rules),
+		LoadedAt: time.Now(),
+	}, nil
+}
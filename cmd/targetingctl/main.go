@@ -0,0 +1,148 @@
+// Command targetingctl manages the targeting engine from the command line:
+// campaigns, their targeting rules, test deliveries, traffic replay and
+// schema migrations.
+//
+//	targetingctl [-o table|json] [-actor name] <command> [flags] [args]
+//
+// It connects with the same DB_* environment variables as the server.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	_ "github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/config"
+)
+
+const usageText = `usage: targetingctl [-o table|json] [-actor name] <command> [flags] [args]
+
+Commands:
+  campaigns list [-status S] [-advertiser ID]   list campaigns
+  campaigns show CID                            show a campaign and its rules
+  campaigns create -cid CID -name NAME [...]    create a DRAFT campaign
+  campaigns pause [-reason R] CID               pause an active campaign
+  campaigns resume [-reason R] CID              resume a paused campaign
+  rules show CID                                print a campaign's rules
+  rules set CID FILE                            replace rules from a JSON file
+  rules edit CID                                edit rules in $EDITOR
+  rules validate FILE...                        check rule files without a DB
+  deliver [-fixture FILE] -app A -country C -os O [...]
+                                                run a test delivery locally
+  replay [-a TARGET] [-b TARGET] [...] CAPTURE   diff captured requests across
+                                                two targets (sql, snapshot,
+                                                fixture:FILE or a URL)
+  migrate [up [-seed]|down [-steps N]|status|seed]
+                                                manage schema migrations
+`
+
+// cli holds the global flags shared by every command.
+type cli struct {
+	out   printer
+	actor string
+}
+
+func main() {
+	log.SetFlags(0)
+
+	output := flag.String("o", "table", "output format: table or json")
+	actor := flag.String("actor", "targetingctl", "name recorded in campaign history")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usageText) }
+	flag.Parse()
+
+	if *output != "table" && *output != "json" {
+		log.Fatalf("❌ unknown output format %q: want table or json", *output)
+	}
+	c := &cli{out: printer{w: os.Stdout, json: *output == "json"}, actor: *actor}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "campaigns":
+		err = c.campaigns(args[1:])
+	case "rules":
+		err = c.rules(args[1:])
+	case "deliver":
+		err = c.deliver(args[1:])
+	case "replay":
+		err = c.replay(args[1:])
+	case "migrate":
+		err = c.migrate(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
+
+// usageError reports a command used with the wrong arguments.
+func usageError(format string, args ...interface{}) error {
+	return fmt.Errorf("usage: targetingctl "+format, args...)
+}
+
+// printer writes results as an aligned table or as JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// print writes v as indented JSON, or header and rows as a table.
+func (p printer) print(v interface{}, header []string, rows [][]string) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func openDB() (*sql.DB, error) {
+	cfg, err := config.LoadEnv()
+	if err != nil {
+		return nil, err
+	}
+	db, err := cfg.DB.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to DB: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to DB: %w", err)
+	}
+	return db, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// orDash shows empty table cells as "-".
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
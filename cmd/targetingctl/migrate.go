@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/db/migrations"
+	"github.com/arunbajpai35/greedygame-targeting-engine/db/seed"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/migrate"
+)
+
+// migrate applies, reverts or lists the embedded schema migrations, and
+// loads the sample data on request.
+//
+//	migrate [up] [-seed]
+//	migrate down [-steps N]
+//	migrate status
+//	migrate seed
+func (c *cli) migrate(args []string) error {
+	cmd := "up"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		cmd, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("migrate "+cmd, flag.ExitOnError)
+	withSeed := fs.Bool("seed", false, "load the sample data after migrating (replaces existing data)")
+	steps := fs.Int("steps", 1, "number of migrations to revert")
+	fs.Parse(args)
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runner, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch cmd {
+	case "up":
+		applied, err := runner.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Printf("✅ Schema is up to date (version %d)", runner.Latest())
+		}
+		if *withSeed {
+			return loadSeed(db)
+		}
+		return nil
+	case "down":
+		if *steps < 1 {
+			return usageError("migrate down [-steps N], N >= 1")
+		}
+		_, err := runner.Down(ctx, *steps)
+		return err
+	case "status":
+		st, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(st))
+		for _, s := range st {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			rows = append(rows, []string{strconv.Itoa(s.Version), s.Name, applied, s.Checksum[:min(12, len(s.Checksum))]})
+		}
+		return c.out.print(st, []string{"VERSION", "NAME", "APPLIED", "CHECKSUM"}, rows)
+	case "seed":
+		return loadSeed(db)
+	default:
+		return usageError("migrate [up|down|status|seed]")
+	}
+}
+
+// loadSeed replaces all data with the sample catalogue.
+func loadSeed(db *sql.DB) error {
+	if _, err := db.Exec(seed.SQL); err != nil {
+		return fmt.Errorf("loading seed data: %w", err)
+	}
+	log.Printf("✅ Loaded seed data")
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/latency"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/replay"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+)
+
+// replay sends captured delivery requests to two targets and reports where
+// the campaigns they match differ. It fails if any request differs.
+func (c *cli) replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	a := fs.String("a", "sql", "first target: sql, snapshot, fixture:FILE or a delivery URL")
+	b := fs.String("b", "snapshot", "second target, as -a")
+	concurrency := fs.Int("concurrency", 4, "requests in flight")
+	limit := fs.Int("limit", 0, "replay at most this many requests (0: all)")
+	diffsPath := fs.String("diffs", "", "write each differing request to this NDJSON file")
+	apiKey := fs.String("key", os.Getenv("TARGETINGCTL_API_KEY"), "API key sent to URL targets")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of each request to a URL target")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return usageError("replay [-a TARGET] [-b TARGET] [...] CAPTURE")
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	targets := &replayTargets{client: &http.Client{Timeout: *timeout}, apiKey: *apiKey}
+	defer targets.close()
+	targetA, err := targets.resolve(*a)
+	if err != nil {
+		return err
+	}
+	targetB, err := targets.resolve(*b)
+	if err != nil {
+		return err
+	}
+
+	opts := replay.Options{Concurrency: *concurrency, Limit: *limit}
+	if *diffsPath != "" {
+		f, err := os.Create(*diffsPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		enc := json.NewEncoder(f)
+		opts.OnDiff = func(d replay.Diff) { enc.Encode(d) }
+	}
+
+	report, err := replay.Compare(context.Background(), replay.NewReader(in), targetA, targetB, opts)
+	if err != nil {
+		return err
+	}
+	report.A.Target, report.B.Target = *a, *b
+	if err := c.printReplay(report); err != nil {
+		return err
+	}
+	if report.Different > 0 || report.Errors > 0 {
+		return fmt.Errorf("%d of %d requests differ, %d failed", report.Different, report.Requests, report.Errors)
+	}
+	return nil
+}
+
+func (c *cli) printReplay(report *replay.Report) error {
+	ms := func(d time.Duration) string { return strconv.FormatFloat(latency.Milliseconds(d), 'f', 3, 64) }
+	side := func(name string, f func(s replay.Side) string) []string {
+		return []string{name, f(report.A), f(report.B)}
+	}
+	rows := [][]string{
+		{"requests", strconv.Itoa(report.Requests), ""},
+		{"identical", strconv.Itoa(report.Identical), ""},
+		{"different", strconv.Itoa(report.Different), ""},
+		side("errors", func(s replay.Side) string { return strconv.Itoa(s.Errors) }),
+		side("p50_ms", func(s replay.Side) string { return ms(s.Latency.P50) }),
+		side("p90_ms", func(s replay.Side) string { return ms(s.Latency.P90) }),
+		side("p99_ms", func(s replay.Side) string { return ms(s.Latency.P99) }),
+		side("max_ms", func(s replay.Side) string { return ms(s.Latency.Max) }),
+	}
+	if err := c.out.print(report, []string{"", "A: " + report.A.Target, "B: " + report.B.Target}, rows); err != nil {
+		return err
+	}
+	if c.out.json || report.Different == 0 {
+		return nil
+	}
+
+	// Campaigns returned by only one target, most frequent first
+	ids := make([]string, 0, len(report.A.Only)+len(report.B.Only))
+	for id := range report.A.Only {
+		ids = append(ids, id)
+	}
+	for id := range report.B.Only {
+		if _, ok := report.A.Only[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	total := func(id string) int { return report.A.Only[id] + report.B.Only[id] }
+	sort.Slice(ids, func(i, j int) bool {
+		if total(ids[i]) != total(ids[j]) {
+			return total(ids[i]) > total(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	diffRows := make([][]string, 0, len(ids))
+	for _, id := range ids {
+		diffRows = append(diffRows, []string{id, strconv.Itoa(report.A.Only[id]), strconv.Itoa(report.B.Only[id])})
+	}
+	fmt.Fprintln(c.out.w)
+	return c.out.print(nil, []string{"CID", "ONLY_A", "ONLY_B"}, diffRows)
+}
+
+// replayTargets resolves target specs, sharing one database connection.
+type replayTargets struct {
+	client *http.Client
+	apiKey string
+	db     *sql.DB
+}
+
+// resolve returns the target named by spec: sql for the per-request
+// queries of delivery, snapshot for the fallback matcher loaded from the
+// database, fixture:FILE for one built from a bulk export file, or the URL
+// of a running server's delivery endpoint.
+func (t *replayTargets) resolve(spec string) (replay.Target, error) {
+	switch {
+	case spec == "sql":
+		db, err := t.open()
+		if err != nil {
+			return nil, err
+		}
+		return replay.SQLTarget(db, segments.NewCache(nil)), nil
+	case spec == "snapshot":
+		db, err := t.open()
+		if err != nil {
+			return nil, err
+		}
+		snapshots := campaigns.NewSnapshotStore(db, nil)
+		if err := snapshots.Refresh(context.Background()); err != nil {
+			return nil, err
+		}
+		return replay.MatcherTarget(snapshots.Current().Matcher), nil
+	case strings.HasPrefix(spec, "fixture:"):
+		snap, err := fixtureSnapshot(strings.TrimPrefix(spec, "fixture:"))
+		if err != nil {
+			return nil, err
+		}
+		return replay.MatcherTarget(snap.Matcher), nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return replay.HTTPTarget(t.client, spec, t.apiKey), nil
+	}
+	return nil, fmt.Errorf("unknown replay target %q: want sql, snapshot, fixture:FILE or a URL", spec)
+}
+
+func (t *replayTargets) open() (*sql.DB, error) {
+	if t.db != nil {
+		return t.db, nil
+	}
+	db, err := openDB()
+	if err != nil {
+		return nil, err
+	}
+	t.db = db
+	return db, nil
+}
+
+func (t *replayTargets) close() {
+	if t.db != nil {
+		t.db.Close()
+	}
+}
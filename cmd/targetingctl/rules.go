@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+func (c *cli) rules(args []string) error {
+	if len(args) == 0 {
+		return usageError("rules show|set|edit|validate")
+	}
+	switch args[0] {
+	case "show":
+		return c.showRules(args[1:])
+	case "set":
+		return c.setRules(args[1:])
+	case "edit":
+		return c.editRules(args[1:])
+	case "validate":
+		return c.validateRules(args[1:])
+	default:
+		return usageError("rules show|set|edit|validate")
+	}
+}
+
+func (c *cli) showRules(args []string) error {
+	if len(args) != 1 {
+		return usageError("rules show CID")
+	}
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rules, err := campaignRules(db, args[0])
+	if err != nil {
+		return err
+	}
+	return c.printRules(rules)
+}
+
+func (c *cli) setRules(args []string) error {
+	if len(args) != 2 {
+		return usageError("rules set CID FILE")
+	}
+	rules, err := readRules(args[1])
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := campaignRules(db, args[0]); err != nil {
+		return err
+	}
+	return c.replaceRules(db, args[0], rules)
+}
+
+// editRules opens the campaign's rules as JSON in $EDITOR (vi by default)
+// and saves them if they changed and are valid.
+func (c *cli) editRules(args []string) error {
+	if len(args) != 1 {
+		return usageError("rules edit CID")
+	}
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	current, err := campaignRules(db, args[0])
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "rules-"+args[0]+"-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	editor := getEnv("EDITOR", "vi")
+	cmd := exec.Command("sh", "-c", editor+` "$0"`, f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor failed: %w", err)
+	}
+
+	edited, err := readRules(f.Name())
+	if err != nil {
+		return err
+	}
+	for i := range current {
+		current[i].CampaignID = ""
+		if err := targeting.NormalizeRule(&current[i]); err != nil {
+			return err
+		}
+	}
+	if reflect.DeepEqual(current, edited) {
+		fmt.Fprintln(os.Stderr, "No changes.")
+		return nil
+	}
+	return c.replaceRules(db, args[0], edited)
+}
+
+// validateRules checks rule files offline and lists every invalid rule.
+func (c *cli) validateRules(args []string) error {
+	if len(args) == 0 {
+		return usageError("rules validate FILE...")
+	}
+
+	type result struct {
+		File  string `json:"file"`
+		Rule  int    `json:"rule,omitempty"`
+		Valid bool   `json:"valid"`
+		Error string `json:"error,omitempty"`
+	}
+	results := []result{}
+	invalid := 0
+	for _, name := range args {
+		rules, err := decodeRulesFile(name)
+		if err != nil {
+			results = append(results, result{File: name, Error: err.Error()})
+			invalid++
+			continue
+		}
+		ok := true
+		for i := range rules {
+			if err := targeting.NormalizeRule(&rules[i]); err != nil {
+				results = append(results, result{File: name, Rule: i + 1, Error: err.Error()})
+				invalid++
+				ok = false
+			}
+		}
+		if ok {
+			results = append(results, result{File: name, Valid: true})
+		}
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rule, status := "-", "ok"
+		if r.Rule > 0 {
+			rule = fmt.Sprint(r.Rule)
+		}
+		if !r.Valid {
+			status = r.Error
+		}
+		rows = append(rows, []string{r.File, rule, status})
+	}
+	if err := c.out.print(results, []string{"FILE", "RULE", "RESULT"}, rows); err != nil {
+		return err
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d problem(s) found", invalid)
+	}
+	return nil
+}
+
+func (c *cli) replaceRules(db *sql.DB, cid string, rules []models.TargetingRule) error {
+	for i := range rules {
+		rules[i].CampaignID = cid
+	}
+	if err := campaigns.ReplaceTargetingRules(db, cid, rules, c.actor); err != nil {
+		return err
+	}
+	return c.printRules(rules)
+}
+
+func (c *cli) printRules(rules []models.TargetingRule) error {
+	if rules == nil {
+		rules = []models.TargetingRule{}
+	}
+	var rows [][]string
+	for i := range rules {
+		for _, line := range describeRule(&rules[i]) {
+			rows = append(rows, []string{fmt.Sprint(i + 1), line})
+		}
+	}
+	return c.out.print(rules, []string{"RULE", "CONSTRAINT"}, rows)
+}
+
+// campaignRules loads the rules of an existing campaign.
+func campaignRules(db *sql.DB, cid string) ([]models.TargetingRule, error) {
+	if _, err := campaigns.GetCampaignByID(db, cid); errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("campaign %s not found", cid)
+	} else if err != nil {
+		return nil, err
+	}
+	rules, err := campaigns.GetTargetingRules(db, cid)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.TargetingRule{}
+	}
+	return rules, nil
+}
+
+// readRules reads and normalises a JSON array of rules, as accepted by
+// PUT /admin/campaigns/{cid}/rules.
+func readRules(name string) ([]models.TargetingRule, error) {
+	rules, err := decodeRulesFile(name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		rules[i].CampaignID = ""
+		if err := targeting.NormalizeRule(&rules[i]); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", name, i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+func decodeRulesFile(name string) ([]models.TargetingRule, error) {
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	rules := []models.TargetingRule{}
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", name, err)
+	}
+	return rules, nil
+}
+
+// describeRule lists a rule's constraints as "field: values" lines.
+func describeRule(r *models.TargetingRule) []string {
+	lists := []struct {
+		name   string
+		values []string
+	}{
+		{"include_country", r.IncludeCountry}, {"exclude_country", r.ExcludeCountry},
+		{"include_os", r.IncludeOS}, {"exclude_os", r.ExcludeOS},
+		{"include_app", r.IncludeApp}, {"exclude_app", r.ExcludeApp},
+		{"include_region", r.IncludeRegion}, {"exclude_region", r.ExcludeRegion},
+		{"include_city", r.IncludeCity}, {"exclude_city", r.ExcludeCity},
+		{"include_segment", r.IncludeSegment}, {"exclude_segment", r.ExcludeSegment},
+		{"include_placement", r.IncludePlacement}, {"exclude_placement", r.ExcludePlacement},
+	}
+	var lines []string
+	for _, l := range lists {
+		if l.values != nil {
+			lines = append(lines, l.name+": "+strings.Join(l.values, ","))
+		}
+	}
+	if r.IncludeGeofence != nil {
+		lines = append(lines, fmt.Sprintf("include_geofence: %d fence(s)", len(r.IncludeGeofence)))
+	}
+	if r.ExcludeGeofence != nil {
+		lines = append(lines, fmt.Sprintf("exclude_geofence: %d fence(s)", len(r.ExcludeGeofence)))
+	}
+	if len(lines) == 0 {
+		lines = []string{"matches every request"}
+	}
+	return lines
+}
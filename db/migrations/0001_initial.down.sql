@@ -0,0 +1,15 @@
+-- Drops the whole schema, and with it all data.
+DROP TABLE IF EXISTS api_key_usage;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS segments;
+DROP TABLE IF EXISTS targeting_rules;
+DROP TABLE IF EXISTS app_floors;
+DROP TABLE IF EXISTS placements;
+DROP TABLE IF EXISTS creatives;
+DROP TABLE IF EXISTS campaign_versions;
+DROP FUNCTION IF EXISTS reject_history_change();
+DROP TABLE IF EXISTS campaign_status_history;
+DROP TABLE IF EXISTS campaigns;
+DROP TABLE IF EXISTS publisher_apps;
+DROP TABLE IF EXISTS publishers;
+DROP TABLE IF EXISTS advertisers;
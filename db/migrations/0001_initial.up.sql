@@ -0,0 +1,194 @@
+-- Initial schema. Written to be idempotent so that databases created with
+-- the former init.sql are adopted: tables and columns that already exist are
+-- left alone and only what is missing is added.
+
+-- advertisers own campaigns; blocked_apps holds app IDs or glob patterns
+CREATE TABLE IF NOT EXISTS advertisers (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    blocked_apps TEXT[]
+);
+
+-- publishers own apps and block advertisers or IAB categories on them
+CREATE TABLE IF NOT EXISTS publishers (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    blocked_advertisers TEXT[],
+    blocked_categories TEXT[]
+);
+
+CREATE TABLE IF NOT EXISTS publisher_apps (
+    app TEXT PRIMARY KEY,
+    publisher_id TEXT NOT NULL REFERENCES publishers(id) ON DELETE CASCADE
+);
+
+-- campaigns table
+CREATE TABLE IF NOT EXISTS campaigns (
+    cid TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    img TEXT,
+    cta TEXT,
+    status TEXT NOT NULL DEFAULT 'DRAFT' CONSTRAINT campaigns_status_check
+        CHECK (status IN ('DRAFT', 'PENDING_REVIEW', 'APPROVED', 'ACTIVE', 'PAUSED', 'COMPLETED', 'ARCHIVED')),
+    advertiser_id TEXT REFERENCES advertisers(id),
+    categories TEXT[],
+    creative_rotation TEXT CHECK (creative_rotation IN ('weighted')),
+    bid_cpm NUMERIC(12, 4) NOT NULL DEFAULT 0 CHECK (bid_cpm >= 0)
+);
+
+-- campaign status transitions, append-only
+CREATE TABLE IF NOT EXISTS campaign_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    cid TEXT NOT NULL REFERENCES campaigns(cid) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- campaign_versions: append-only history of each campaign and its rules.
+-- No foreign key, so history outlives deleted campaigns.
+CREATE TABLE IF NOT EXISTS campaign_versions (
+    cid TEXT NOT NULL,
+    version INT NOT NULL,
+    actor TEXT NOT NULL,
+    change TEXT NOT NULL,
+    diff JSONB NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (cid, version)
+);
+
+CREATE OR REPLACE FUNCTION reject_history_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS campaign_versions_append_only ON campaign_versions;
+CREATE TRIGGER campaign_versions_append_only BEFORE UPDATE OR DELETE ON campaign_versions
+    FOR EACH ROW EXECUTE FUNCTION reject_history_change();
+
+-- creatives: many per campaign; sizes are 'WxH' slots, NULL/empty fits any
+CREATE TABLE IF NOT EXISTS creatives (
+    id TEXT PRIMARY KEY,
+    cid TEXT NOT NULL REFERENCES campaigns(cid) ON DELETE CASCADE,
+    format TEXT CHECK (format IN ('banner', 'interstitial', 'native', 'video')) NOT NULL,
+    sizes TEXT[],
+    assets JSONB NOT NULL DEFAULT '{}',
+    status TEXT CHECK (status IN ('ACTIVE', 'PAUSED')) NOT NULL DEFAULT 'ACTIVE',
+    weight INT NOT NULL DEFAULT 1 CHECK (weight > 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- placements: ad slots within an app; NULL/empty sizes accept any size
+CREATE TABLE IF NOT EXISTS placements (
+    id TEXT PRIMARY KEY,
+    app TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    formats TEXT[] NOT NULL,
+    sizes TEXT[],
+    floor_cpm NUMERIC(12, 4) NOT NULL DEFAULT 0 CHECK (floor_cpm >= 0)
+);
+
+-- app_floors: auction floor for apps; a placement's own floor takes precedence
+CREATE TABLE IF NOT EXISTS app_floors (
+    app TEXT PRIMARY KEY,
+    floor_cpm NUMERIC(12, 4) NOT NULL CHECK (floor_cpm >= 0)
+);
+
+-- targeting_rules table with proper array support
+CREATE TABLE IF NOT EXISTS targeting_rules (
+    id SERIAL PRIMARY KEY,
+    cid TEXT REFERENCES campaigns(cid) ON DELETE CASCADE,
+    include_country TEXT[],
+    exclude_country TEXT[],
+    include_os TEXT[],
+    exclude_os TEXT[],
+    include_app TEXT[],
+    exclude_app TEXT[],
+    include_region TEXT[],
+    exclude_region TEXT[],
+    include_city TEXT[],
+    exclude_city TEXT[],
+    -- geofences: JSON arrays of {"lat","lon","radius_km"} or {"polygon":[[lat,lon],...]}
+    include_geofence JSONB,
+    exclude_geofence JSONB,
+    include_segment TEXT[],
+    exclude_segment TEXT[],
+    include_placement TEXT[],
+    exclude_placement TEXT[]
+);
+
+-- audience segments: serialized exact fingerprint sets or bloom filters
+CREATE TABLE IF NOT EXISTS segments (
+    name TEXT PRIMARY KEY,
+    kind TEXT CHECK (kind IN ('exact', 'bloom')) NOT NULL,
+    hash TEXT CHECK (hash IN ('none', 'sha256', 'md5')) NOT NULL DEFAULT 'none',
+    fp_rate DOUBLE PRECISION,
+    size BIGINT NOT NULL,
+    data BYTEA NOT NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- API keys: only the SHA-256 of each token is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    publisher_id TEXT,
+    advertiser_id TEXT,
+    apps TEXT[],
+    permissions TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+-- per-minute API key usage audit
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    key_id TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    endpoint TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    denied BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, window_start, endpoint)
+);
+
+-- Columns added after the initial schema, for existing databases
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS include_region TEXT[];
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS exclude_region TEXT[];
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS include_city TEXT[];
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS exclude_city TEXT[];
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS include_geofence JSONB;
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS exclude_geofence JSONB;
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS include_segment TEXT[];
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS exclude_segment TEXT[];
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS include_placement TEXT[];
+ALTER TABLE targeting_rules ADD COLUMN IF NOT EXISTS exclude_placement TEXT[];
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS advertiser_id TEXT REFERENCES advertisers(id);
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS categories TEXT[];
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS creative_rotation TEXT CHECK (creative_rotation IN ('weighted'));
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS bid_cpm NUMERIC(12, 4) NOT NULL DEFAULT 0 CHECK (bid_cpm >= 0);
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS advertiser_id TEXT;
+
+-- Campaign lifecycle: INACTIVE became PAUSED
+ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaigns_status_check;
+UPDATE campaigns SET status = 'PAUSED' WHERE status = 'INACTIVE';
+ALTER TABLE campaigns ADD CONSTRAINT campaigns_status_check
+    CHECK (status IN ('DRAFT', 'PENDING_REVIEW', 'APPROVED', 'ACTIVE', 'PAUSED', 'COMPLETED', 'ARCHIVED'));
+ALTER TABLE campaigns ALTER COLUMN status SET DEFAULT 'DRAFT';
+
+-- Create indexes for better performance
+CREATE INDEX IF NOT EXISTS idx_campaigns_status ON campaigns(status);
+CREATE INDEX IF NOT EXISTS idx_targeting_rules_cid ON targeting_rules(cid);
+CREATE INDEX IF NOT EXISTS idx_campaigns_advertiser ON campaigns(advertiser_id);
+CREATE INDEX IF NOT EXISTS idx_creatives_cid ON creatives(cid);
+CREATE INDEX IF NOT EXISTS idx_publisher_apps_publisher ON publisher_apps(publisher_id);
+CREATE INDEX IF NOT EXISTS idx_placements_app ON placements(app);
+CREATE INDEX IF NOT EXISTS idx_campaign_status_history_cid ON campaign_status_history(cid);
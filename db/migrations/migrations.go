@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned schema migrations. Each version N
+// has NNNN_name.up.sql and NNNN_name.down.sql; see internal/migrate.
+package migrations
+
+import "embed"
+
+// FS holds the migration files.
+//
+//go:embed *.sql
+var FS embed.FS
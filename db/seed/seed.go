@@ -0,0 +1,11 @@
+// Package seed embeds the sample catalogue used in development and by the
+// integration tests. It is kept apart from the schema migrations: loading it
+// replaces all existing data.
+package seed
+
+import _ "embed"
+
+// SQL clears every table and inserts the sample data.
+//
+//go:embed seed.sql
+var SQL string
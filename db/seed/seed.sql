@@ -0,0 +1,49 @@
+-- Clear previous data (optional for dev); TRUNCATE bypasses the history's
+-- append-only trigger
+TRUNCATE campaign_versions;
+DELETE FROM campaign_status_history;
+DELETE FROM creatives;
+DELETE FROM targeting_rules;
+DELETE FROM campaigns;
+DELETE FROM placements;
+DELETE FROM app_floors;
+DELETE FROM publisher_apps;
+DELETE FROM publishers;
+DELETE FROM advertisers;
+
+-- Seed advertisers and publishers (no block lists, so delivery is unchanged)
+INSERT INTO advertisers (id, name, blocked_apps) VALUES
+('spotify', 'Spotify AB', NULL),
+('duolingo', 'Duolingo, Inc.', NULL),
+('sybo', 'SYBO Games', NULL);
+
+INSERT INTO publishers (id, name, blocked_advertisers, blocked_categories) VALUES
+('gametion', 'Gametion Technologies', NULL, NULL);
+
+INSERT INTO publisher_apps (app, publisher_id) VALUES
+('com.gametion.ludokinggame', 'gametion');
+
+-- Seed placements (requests without placement_id are unaffected)
+INSERT INTO placements (id, app, name, formats, sizes, floor_cpm) VALUES
+('ludo-home-banner', 'com.gametion.ludokinggame', 'Home screen banner', ARRAY['banner'], ARRAY['320x50'], 0.5);
+
+-- Seed campaigns as per assignment
+INSERT INTO campaigns (cid, name, img, cta, status, advertiser_id, categories, bid_cpm) VALUES
+('spotify', 'Spotify - Music for everyone', 'https://somelink', 'Download', 'ACTIVE', 'spotify', ARRAY['IAB1-6'], 2.5),
+('duolingo', 'Duolingo: Best way to learn', 'https://somelink2', 'Install', 'ACTIVE', 'duolingo', ARRAY['IAB5'], 1.2),
+('subwaysurfer', 'Subway Surfer', 'https://somelink3', 'Play', 'ACTIVE', 'sybo', ARRAY['IAB9-30'], 1.8);
+
+-- Seed app floors (used by auctions when the placement sets no floor)
+INSERT INTO app_floors (app, floor_cpm) VALUES
+('com.gametion.ludokinggame', 0.3);
+
+-- Seed creatives (campaigns without creatives serve their img/cta as a banner)
+INSERT INTO creatives (id, cid, format, sizes, assets, status, weight) VALUES
+('spotify-banner', 'spotify', 'banner', ARRAY['320x50', '728x90'], '{"img": "https://somelink", "cta": "Download"}', 'ACTIVE', 1),
+('spotify-interstitial', 'spotify', 'interstitial', ARRAY['320x480'], '{"img": "https://somelink-full", "cta": "Download"}', 'ACTIVE', 1);
+
+-- Seed targeting rules as per assignment
+INSERT INTO targeting_rules (cid, include_country, exclude_country, include_os, exclude_os, include_app, exclude_app) VALUES
+('spotify', ARRAY['us', 'ca'], NULL, NULL, NULL, NULL, NULL),
+('duolingo', NULL, ARRAY['us'], ARRAY['android', 'ios'], NULL, NULL, NULL),
+('subwaysurfer', NULL, NULL, ARRAY['android'], NULL, ARRAY['com.gametion.ludokinggame'], NULL);
@@ -0,0 +1,83 @@
+// Package admin implements the management HTTP API mounted under /admin.
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+)
+
+// RegisterRoutes mounts every admin resource on r. Keys bound to a publisher
+// or advertiser only reach their own tenant's resources.
+func RegisterRoutes(r chi.Router, db *sql.DB) {
+	r.Route("/campaigns", func(r chi.Router) {
+		r.Get("/", HandleListCampaigns(db))
+		r.Get("/export", HandleExportCampaigns(db))
+		r.Post("/import", HandleImportCampaigns(db))
+		r.With(requireGlobal).Put("/{cid}/ownership", HandleSetCampaignOwnership(db))
+		r.Get("/{cid}/rules", HandleGetRules(db))
+		r.Put("/{cid}/rules", HandleReplaceRules(db))
+		r.Get("/{cid}/creatives", HandleListCreatives(db))
+		r.Put("/{cid}/creatives/{id}", HandleSaveCreative(db))
+		r.Delete("/{cid}/creatives/{id}", HandleDeleteCreative(db))
+		r.Put("/{cid}/creative-rotation", HandleSetCreativeRotation(db))
+		r.Put("/{cid}/bid", HandleSetCampaignBid(db))
+		r.Get("/{cid}/status-history", HandleStatusHistory(db))
+		r.Get("/{cid}/history", HandleListVersions(db))
+		r.Get("/{cid}/history/{version}", HandleGetVersion(db))
+		r.Post("/{cid}/history/{version}/restore", HandleRestoreRules(db))
+		r.Post("/{cid}/{action}", HandleCampaignTransition(db))
+	})
+	r.Route("/advertisers", func(r chi.Router) {
+		r.Get("/", HandleListAdvertisers(db))
+		r.Get("/{id}", HandleGetAdvertiser(db))
+		r.Put("/{id}", HandleSaveAdvertiser(db))
+	})
+	r.Route("/publishers", func(r chi.Router) {
+		r.Get("/", HandleListPublishers(db))
+		r.Get("/{id}", HandleGetPublisher(db))
+		r.Put("/{id}", HandleSavePublisher(db))
+	})
+	r.Route("/placements", func(r chi.Router) {
+		r.Get("/", HandleListPlacements(db))
+		r.Get("/{id}", HandleGetPlacement(db))
+		r.Put("/{id}", HandleSavePlacement(db))
+		r.Delete("/{id}", HandleDeletePlacement(db))
+	})
+	r.Route("/floors", func(r chi.Router) {
+		r.Get("/", HandleListAppFloors(db))
+		r.Put("/{app}", HandleSetAppFloor(db))
+		r.Delete("/{app}", HandleDeleteAppFloor(db))
+	})
+	r.Route("/keys", func(r chi.Router) {
+		r.Use(requireGlobal)
+		r.Get("/", HandleListKeys(db))
+		r.Post("/", HandleCreateKey(db))
+		r.Delete("/{id}", HandleRevokeKey(db))
+		r.Get("/{id}/usage", HandleKeyUsage(db))
+	})
+	r.Route("/segments", func(r chi.Router) {
+		r.Use(requireGlobal)
+		r.Get("/", HandleListSegments(db))
+		r.Get("/{name}", HandleGetSegment(db))
+		r.Put("/{name}", HandleUploadSegment(db))
+		r.Delete("/{name}", HandleDeleteSegment(db))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode response", logging.Err(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
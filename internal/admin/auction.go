@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+type bidRequest struct {
+	BidCPM *float64 `json:"bid_cpm"`
+}
+
+// HandleSetCampaignBid sets the CPM a campaign bids in delivery auctions.
+func HandleSetCampaignBid(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req bidRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if req.BidCPM == nil || *req.BidCPM < 0 {
+			writeError(w, http.StatusBadRequest, "bid_cpm must be zero or positive")
+			return
+		}
+
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		if err := campaigns.SetCampaignBid(db, c.ID, *req.BidCPM, actorOf(r)); err != nil {
+			slog.ErrorContext(r.Context(), "failed to set bid", "campaign", c.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		c.BidCPM = *req.BidCPM
+		writeJSON(w, http.StatusOK, models.NewCampaignRecord(*c))
+	}
+}
+
+type appFloor struct {
+	App      string   `json:"app"`
+	FloorCPM *float64 `json:"floor_cpm"`
+}
+
+// HandleListAppFloors lists the auction floor of every app that has one.
+func HandleListAppFloors(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		floors, err := placements.ListAppFloors(r.Context(), db)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list app floors", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		list := make([]appFloor, 0, len(floors))
+		for app, floor := range floors {
+			floor := floor
+			list = append(list, appFloor{App: app, FloorCPM: &floor})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].App < list[j].App })
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// HandleSetAppFloor sets an app's auction floor. Placements with their own
+// floor override it.
+func HandleSetAppFloor(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req appFloor
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if req.FloorCPM == nil || *req.FloorCPM < 0 {
+			writeError(w, http.StatusBadRequest, "floor_cpm must be zero or positive")
+			return
+		}
+		req.App = targeting.NormalizeApp(chi.URLParam(r, "app"))
+		if req.App == "" || targeting.IsPattern(req.App) {
+			writeError(w, http.StatusBadRequest, "floor app must be an exact app ID")
+			return
+		}
+		if !ownsApp(w, r, db, req.App) {
+			return
+		}
+
+		if err := placements.SaveAppFloor(db, req.App, *req.FloorCPM); err != nil {
+			slog.ErrorContext(r.Context(), "failed to set floor", "app", req.App, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, req)
+	}
+}
+
+// HandleDeleteAppFloor removes an app's auction floor.
+func HandleDeleteAppFloor(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app := targeting.NormalizeApp(chi.URLParam(r, "app"))
+		if !ownsApp(w, r, db, app) {
+			return
+		}
+		err := placements.DeleteAppFloor(db, app)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "app floor not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete floor", "app", app, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"database/sql"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/bulk"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+)
+
+// maxImportBytes bounds the body of a bulk import.
+const maxImportBytes = 16 << 20
+
+// HandleExportCampaigns writes every campaign the caller may manage, with
+// its targeting rules, as JSON or CSV (?format=csv).
+func HandleExportCampaigns(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := scopeOf(r)
+		if s.publisher != "" {
+			writeError(w, http.StatusForbidden, "api key is scoped to a publisher")
+			return
+		}
+		format, ok := bulkFormat(w, r)
+		if !ok {
+			return
+		}
+		entries, err := bulk.Export(r.Context(), db, s.advertiser)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to export campaigns", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		if format == bulk.FormatCSV {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="campaigns.csv"`)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		if err := bulk.Encode(format, w, entries); err != nil {
+			slog.ErrorContext(r.Context(), "failed to encode export", logging.Err(err))
+		}
+	}
+}
+
+// HandleImportCampaigns upserts campaigns and their rules from a JSON or
+// CSV body in one transaction. With ?dry_run=true it only validates. Row
+// errors are reported with 422 and nothing is written.
+func HandleImportCampaigns(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := scopeOf(r)
+		if s.publisher != "" {
+			writeError(w, http.StatusForbidden, "api key is scoped to a publisher")
+			return
+		}
+		format, ok := bulkFormat(w, r)
+		if !ok {
+			return
+		}
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				writeError(w, http.StatusBadRequest, "dry_run must be true or false")
+				return
+			}
+		}
+
+		entries, rowErrs, err := bulk.Decode(format, http.MaxBytesReader(w, r.Body, maxImportBytes))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		report, err := bulk.Apply(db, entries, rowErrs, bulk.Options{Actor: actorOf(r), DryRun: dryRun, Advertiser: s.advertiser})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to import campaigns", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if len(report.Errors) > 0 {
+			writeJSON(w, http.StatusUnprocessableEntity, report)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// bulkFormat reads the ?format parameter, falling back to CSV for a
+// text/csv body and JSON otherwise, writing a 400 if it is invalid.
+func bulkFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = bulk.FormatJSON
+		if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mt == "text/csv" {
+			name = bulk.FormatCSV
+		}
+	}
+	format, err := bulk.ParseFormat(name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return format, true
+}
@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// HandleListCreatives returns a campaign's creatives.
+func HandleListCreatives(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		list, err := creatives.List(db, c.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list creatives", "campaign", c.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if list == nil {
+			list = []models.Creative{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// HandleSaveCreative creates or replaces a creative of a campaign.
+func HandleSaveCreative(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var cr models.Creative
+		if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		cr.ID = chi.URLParam(r, "id")
+		cr.CampaignID = chi.URLParam(r, "cid")
+		if err := creatives.Normalize(&cr); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if _, ok := ownedCampaign(w, r, db); !ok {
+			return
+		}
+
+		err := creatives.Save(db, cr)
+		if errors.Is(err, creatives.ErrOtherCampaign) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to save creative", "creative", cr.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, cr)
+	}
+}
+
+// HandleDeleteCreative removes a creative of a campaign.
+func HandleDeleteCreative(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		id := chi.URLParam(r, "id")
+		err := creatives.Delete(db, c.ID, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "creative not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete creative", "creative", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type rotationRequest struct {
+	Rotation string `json:"rotation"`
+}
+
+// HandleSetCreativeRotation sets how a campaign rotates between its
+// creatives: "weighted" or "none".
+func HandleSetCreativeRotation(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req rotationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		rotation, err := creatives.ParseRotation(req.Rotation)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		if err := campaigns.SetCreativeRotation(db, c.ID, rotation, actorOf(r)); err != nil {
+			slog.ErrorContext(r.Context(), "failed to set creative rotation", "campaign", c.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		c.CreativeRotation = rotation
+		writeJSON(w, http.StatusOK, models.NewCampaignRecord(*c))
+	}
+}
@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/history"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+)
+
+// HandleListVersions returns a campaign's change history, newest first,
+// with the diff and actor of each version.
+func HandleListVersions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		versions, err := campaigns.ListVersions(db, c.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list versions", "campaign", c.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if versions == nil {
+			versions = []history.Version{}
+		}
+		writeJSON(w, http.StatusOK, versions)
+	}
+}
+
+// HandleGetVersion returns one version of a campaign with the full state
+// before and after the change.
+func HandleGetVersion(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version, ok := versionParam(w, r)
+		if !ok {
+			return
+		}
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		v, err := campaigns.GetVersion(db, c.ID, version)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "version not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get version", "campaign", c.ID, "version", version, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+// HandleRestoreRules atomically restores the targeting rules a campaign had
+// after the given version. The restore is itself recorded as a new version,
+// which is returned.
+func HandleRestoreRules(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version, ok := versionParam(w, r)
+		if !ok {
+			return
+		}
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		v, err := campaigns.RestoreRules(db, c.ID, version, actorOf(r))
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "version not found")
+			return
+		}
+		if errors.Is(err, campaigns.ErrNoRulesInVersion) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to restore version", "campaign", c.ID, "version", version, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if v == nil {
+			// The rules already match that version
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func versionParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid version")
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+type createKeyRequest struct {
+	Name        string   `json:"name"`
+	Publisher   string   `json:"publisher"`
+	Advertiser  string   `json:"advertiser"`
+	Apps        []string `json:"apps"`
+	Permissions []string `json:"permissions"`
+}
+
+type createKeyResponse struct {
+	*auth.Key
+	// Token is only returned once, at creation
+	Token string `json:"token"`
+}
+
+// HandleCreateKey issues a new API key.
+func HandleCreateKey(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			writeError(w, http.StatusBadRequest, "missing name")
+			return
+		}
+		if len(req.Permissions) == 0 {
+			writeError(w, http.StatusBadRequest, "missing permissions")
+			return
+		}
+
+		if req.Publisher != "" && req.Advertiser != "" {
+			writeError(w, http.StatusBadRequest, "key cannot belong to both a publisher and an advertiser")
+			return
+		}
+
+		if req.Publisher != "" && !publisherOwnsApps(w, r, db, req.Publisher, req.Apps) {
+			return
+		}
+
+		key := auth.Key{Name: strings.TrimSpace(req.Name), Publisher: req.Publisher, Advertiser: req.Advertiser, Apps: req.Apps}
+		for _, p := range req.Permissions {
+			perm, err := auth.ParsePermission(p)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			key.Permissions = append(key.Permissions, perm)
+		}
+
+		created, token, err := auth.CreateKey(db, key)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to create API key", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusCreated, createKeyResponse{Key: created, Token: token})
+	}
+}
+
+// publisherOwnsApps checks that publisher exists and that every entry of
+// apps matches at least one of its apps, writing a 400 otherwise.
+func publisherOwnsApps(w http.ResponseWriter, r *http.Request, db *sql.DB, publisher string, apps []string) bool {
+	p, err := campaigns.GetPublisher(db, publisher)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusBadRequest, "unknown publisher: "+publisher)
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get publisher", "publisher", publisher, logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return false
+	}
+
+	for _, pattern := range apps {
+		pattern := targeting.NormalizeApp(pattern)
+		if !slices.ContainsFunc(p.Apps, func(app string) bool {
+			return targeting.MatchGlob(pattern, targeting.NormalizeApp(app))
+		}) {
+			writeError(w, http.StatusBadRequest, "app "+pattern+" does not belong to publisher "+publisher)
+			return false
+		}
+	}
+	return true
+}
+
+// HandleListKeys lists every API key without its token.
+func HandleListKeys(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := auth.ListKeys(db)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list API keys", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if keys == nil {
+			keys = []auth.Key{}
+		}
+		writeJSON(w, http.StatusOK, keys)
+	}
+}
+
+// HandleRevokeKey revokes an API key. Servers stop accepting it on their
+// next keyring refresh.
+func HandleRevokeKey(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := auth.RevokeKey(db, chi.URLParam(r, "id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "api key not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to revoke API key", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleKeyUsage returns per-minute usage of a key. The optional since
+// parameter is an RFC 3339 time and defaults to 24 hours ago.
+func HandleKeyUsage(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		since := time.Now().Add(-24 * time.Hour)
+		if v := r.URL.Query().Get("since"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid since param")
+				return
+			}
+			since = t
+		}
+
+		usage, err := auth.GetUsage(db, chi.URLParam(r, "id"), since)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get API key usage", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if usage == nil {
+			usage = []auth.Usage{}
+		}
+		writeJSON(w, http.StatusOK, usage)
+	}
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/lifecycle"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+)
+
+type transitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// HandleCampaignTransition applies the {action} lifecycle action (submit,
+// approve, reject, activate, pause, resume, complete, archive) to a
+// campaign. Approving and rejecting need a global key.
+func HandleCampaignTransition(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		action, err := lifecycle.ParseAction(chi.URLParam(r, "action"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		var req transitionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if action.NeedsReviewer() && !scopeOf(r).global() {
+			writeError(w, http.StatusForbidden, "only global api keys can review campaigns")
+			return
+		}
+
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		t, err := campaigns.TransitionCampaign(db, c.ID, action, actorOf(r), strings.TrimSpace(req.Reason))
+		if errors.Is(err, lifecycle.ErrInvalidTransition) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "campaign not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to change campaign status", "campaign", c.ID, "action", action, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	}
+}
+
+// HandleStatusHistory returns a campaign's status transitions, oldest first.
+func HandleStatusHistory(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := ownedCampaign(w, r, db)
+		if !ok {
+			return
+		}
+		history, err := campaigns.GetStatusHistory(db, c.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get status history", "campaign", c.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if history == nil {
+			history = []lifecycle.Transition{}
+		}
+		writeJSON(w, http.StatusOK, history)
+	}
+}
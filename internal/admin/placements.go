@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+)
+
+// HandleListPlacements lists every placement. Any scope may read them, so
+// advertisers can target placements by ID.
+func HandleListPlacements(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := placements.List(r.Context(), db)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list placements", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if list == nil {
+			list = []models.Placement{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// HandleGetPlacement returns one placement.
+func HandleGetPlacement(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		p, err := placements.Get(r.Context(), db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "placement not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get placement", "placement", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// HandleSavePlacement creates or replaces a placement. Publisher-scoped keys
+// may only manage placements of their own apps.
+func HandleSavePlacement(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var p models.Placement
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		p.ID = chi.URLParam(r, "id")
+		if err := placements.Normalize(&p); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		current, err := placements.Get(r.Context(), db, p.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "failed to get placement", "placement", p.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if !ownsApp(w, r, db, p.App) {
+			return
+		}
+		if current != nil && current.App != p.App && !ownsApp(w, r, db, current.App) {
+			return
+		}
+
+		if err := placements.Save(db, p); err != nil {
+			slog.ErrorContext(r.Context(), "failed to save placement", "placement", p.ID, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// HandleDeletePlacement removes a placement.
+func HandleDeletePlacement(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		p, err := placements.Get(r.Context(), db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "placement not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get placement", "placement", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if !ownsApp(w, r, db, p.App) {
+			return
+		}
+
+		err = placements.Delete(db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "placement not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete placement", "placement", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ownsApp reports whether the caller may manage the placements and floors
+// of app, writing a 403 if not: global keys may manage any app, publisher
+// keys only the apps they own.
+func ownsApp(w http.ResponseWriter, r *http.Request, db *sql.DB, app string) bool {
+	s := scopeOf(r)
+	if s.global() {
+		return true
+	}
+	if s.publisher != "" {
+		pub, err := campaigns.GetPublisher(db, s.publisher)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			slog.ErrorContext(r.Context(), "failed to get publisher", "publisher", s.publisher, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return false
+		}
+		if err == nil && slices.Contains(pub.Apps, app) {
+			return true
+		}
+	}
+	writeError(w, http.StatusForbidden, "api key cannot manage app "+app)
+	return false
+}
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// HandleGetRules returns a campaign's targeting rules. Advertiser-scoped
+// keys only see their own campaigns.
+func HandleGetRules(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cid := chi.URLParam(r, "cid")
+		if _, ok := ownedCampaign(w, r, db); !ok {
+			return
+		}
+
+		rules, err := campaigns.GetTargetingRules(db, cid)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get rules", "campaign", cid, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if rules == nil {
+			rules = []models.TargetingRule{}
+		}
+		writeJSON(w, http.StatusOK, rules)
+	}
+}
+
+// HandleReplaceRules replaces a campaign's targeting rules with the JSON
+// array in the body. Rules are normalised first and rejected with 400 if
+// they contain unknown countries or invalid geofences.
+func HandleReplaceRules(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cid := chi.URLParam(r, "cid")
+
+		var rules []models.TargetingRule
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		for i := range rules {
+			rules[i].CampaignID = cid
+			if err := targeting.NormalizeRule(&rules[i]); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+
+		if _, ok := ownedCampaign(w, r, db); !ok {
+			return
+		}
+
+		if err := campaigns.ReplaceTargetingRules(db, cid, rules, actorOf(r)); err != nil {
+			slog.ErrorContext(r.Context(), "failed to replace rules", "campaign", cid, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, rules)
+	}
+}
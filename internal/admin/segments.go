@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+)
+
+// maxSegmentUpload caps the size of an uploaded device ID list.
+const maxSegmentUpload = 512 << 20
+
+var segmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,127}$`)
+
+// HandleUploadSegment creates or replaces a segment from a plain-text or CSV
+// body of device IDs. Query parameters: kind (exact|bloom), hash
+// (none|sha256|md5) and fp_rate for bloom segments.
+func HandleUploadSegment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if !segmentNamePattern.MatchString(name) {
+			writeError(w, http.StatusBadRequest, "invalid segment name")
+			return
+		}
+
+		q := r.URL.Query()
+		opts := segments.Options{
+			Kind: segments.Kind(q.Get("kind")),
+			Hash: segments.HashType(q.Get("hash")),
+		}
+		if v := q.Get("fp_rate"); v != "" {
+			rate, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid fp_rate param")
+				return
+			}
+			opts.FPRate = rate
+		}
+
+		ids, err := segments.ParseIDs(http.MaxBytesReader(w, r.Body, maxSegmentUpload))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid device id list: "+err.Error())
+			return
+		}
+
+		seg, err := segments.Build(name, ids, opts)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := segments.Save(db, seg); err != nil {
+			slog.ErrorContext(r.Context(), "failed to save segment", "segment", name, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		slog.InfoContext(r.Context(), "segment saved", "segment", seg.Name, "kind", seg.Kind,
+			"hash", seg.Hash, "size", seg.Size, "version", seg.Version)
+		writeJSON(w, http.StatusOK, seg)
+	}
+}
+
+// HandleListSegments returns the metadata of every segment.
+func HandleListSegments(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := segments.List(db)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list segments", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if list == nil {
+			list = []segments.Segment{}
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// HandleGetSegment returns a segment's metadata.
+func HandleGetSegment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		seg, err := segments.Get(db, chi.URLParam(r, "name"))
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "segment not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get segment", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, seg)
+	}
+}
+
+// HandleDeleteSegment removes a segment. Rules still referencing it stop
+// matching devices against it.
+func HandleDeleteSegment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := segments.Delete(db, chi.URLParam(r, "name"))
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "segment not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to delete segment", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,369 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// scope is the tenant an admin request is limited to. Requests without a
+// key (auth disabled) and keys bound to no tenant are global.
+type scope struct {
+	advertiser string
+	publisher  string
+}
+
+func scopeOf(r *http.Request) scope {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return scope{advertiser: key.Advertiser, publisher: key.Publisher}
+	}
+	return scope{}
+}
+
+// actorOf names who made an admin request for the history: the API key ID,
+// or "anonymous" when authentication is disabled.
+func actorOf(r *http.Request) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return key.ID
+	}
+	return "anonymous"
+}
+
+func (s scope) global() bool {
+	return s.advertiser == "" && s.publisher == ""
+}
+
+// ownsCampaign reports whether the scope may manage c.
+func (s scope) ownsCampaign(c *models.Campaign) bool {
+	return s.global() || (s.advertiser != "" && s.advertiser == c.AdvertiserID)
+}
+
+// ownedCampaign loads the {cid} campaign, writing a 404 if it does not exist
+// or belongs to another advertiser.
+func ownedCampaign(w http.ResponseWriter, r *http.Request, db *sql.DB) (*models.Campaign, bool) {
+	cid := chi.URLParam(r, "cid")
+	c, err := campaigns.GetCampaignByID(db, cid)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !scopeOf(r).ownsCampaign(c)) {
+		writeError(w, http.StatusNotFound, "campaign not found")
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to get campaign", "campaign", cid, logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return nil, false
+	}
+	return c, true
+}
+
+// requireGlobal rejects tenant-scoped keys from resources shared by every
+// tenant, such as API keys and audience segments.
+func requireGlobal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !scopeOf(r).global() {
+			writeError(w, http.StatusForbidden, "api key is scoped to a tenant")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleListCampaigns lists campaigns, limited to the caller's own for
+// advertiser-scoped keys.
+func HandleListCampaigns(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := scopeOf(r)
+		if s.publisher != "" {
+			writeError(w, http.StatusForbidden, "api key is scoped to a publisher")
+			return
+		}
+		list, err := campaigns.ListCampaigns(r.Context(), db, s.advertiser)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list campaigns", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, models.NewCampaignRecords(list))
+	}
+}
+
+type campaignOwnershipRequest struct {
+	AdvertiserID string   `json:"advertiser_id"`
+	Categories   []string `json:"categories"`
+}
+
+// HandleSetCampaignOwnership assigns a campaign to an advertiser and sets
+// its categories. Only global keys may do so, since categories drive
+// publisher block lists.
+func HandleSetCampaignOwnership(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cid := chi.URLParam(r, "cid")
+
+		var req campaignOwnershipRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if req.AdvertiserID != "" {
+			if _, err := campaigns.GetAdvertiser(db, req.AdvertiserID); errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusBadRequest, "unknown advertiser "+req.AdvertiserID)
+				return
+			} else if err != nil {
+				slog.ErrorContext(r.Context(), "failed to get advertiser", "advertiser", req.AdvertiserID, logging.Err(err))
+				writeError(w, http.StatusInternalServerError, "internal server error")
+				return
+			}
+		}
+
+		err := campaigns.UpdateCampaignOwnership(db, cid, req.AdvertiserID, normalizeCategories(req.Categories), actorOf(r))
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "campaign not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to update campaign", "campaign", cid, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		c, err := campaigns.GetCampaignByID(db, cid)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get campaign", "campaign", cid, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, models.NewCampaignRecord(*c))
+	}
+}
+
+// HandleListAdvertisers lists advertisers. Advertiser-scoped keys only see
+// their own; publishers see all so they can build block lists.
+func HandleListAdvertisers(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		all, err := campaigns.GetAdvertisers(r.Context(), db)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list advertisers", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		s := scopeOf(r)
+		list := []models.Advertiser{}
+		for _, a := range all {
+			if s.advertiser == "" || s.advertiser == a.ID {
+				list = append(list, a)
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// HandleGetAdvertiser returns one advertiser.
+func HandleGetAdvertiser(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if s := scopeOf(r); s.advertiser != "" && s.advertiser != id {
+			writeError(w, http.StatusNotFound, "advertiser not found")
+			return
+		}
+		a, err := campaigns.GetAdvertiser(db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "advertiser not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get advertiser", "advertiser", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, a)
+	}
+}
+
+// HandleSaveAdvertiser creates or updates an advertiser. Advertiser-scoped
+// keys may only update themselves.
+func HandleSaveAdvertiser(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if s := scopeOf(r); !s.global() && s.advertiser != id {
+			writeError(w, http.StatusForbidden, "api key cannot manage advertiser "+id)
+			return
+		}
+
+		var a models.Advertiser
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		a.ID = id
+		a.Name = strings.TrimSpace(a.Name)
+		if a.Name == "" {
+			writeError(w, http.StatusBadRequest, "missing name")
+			return
+		}
+		a.BlockedApps = normalizeApps(a.BlockedApps)
+
+		if err := campaigns.SaveAdvertiser(db, a); err != nil {
+			slog.ErrorContext(r.Context(), "failed to save advertiser", "advertiser", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, a)
+	}
+}
+
+// HandleListPublishers lists publishers, limited to the caller's own for
+// publisher-scoped keys.
+func HandleListPublishers(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := scopeOf(r)
+		if s.advertiser != "" {
+			writeError(w, http.StatusForbidden, "api key is scoped to an advertiser")
+			return
+		}
+		all, err := campaigns.GetPublishers(r.Context(), db)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to list publishers", logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		list := []models.Publisher{}
+		for _, p := range all {
+			if s.publisher == "" || s.publisher == p.ID {
+				list = append(list, p)
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// HandleGetPublisher returns one publisher with its apps and block lists.
+func HandleGetPublisher(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if s := scopeOf(r); !s.global() && s.publisher != id {
+			writeError(w, http.StatusNotFound, "publisher not found")
+			return
+		}
+		p, err := campaigns.GetPublisher(db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "publisher not found")
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to get publisher", "publisher", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// HandleSavePublisher creates or updates a publisher. Publisher-scoped keys
+// may update their own name and block lists; assigning apps needs a global
+// key, since app ownership decides whose block lists apply. Omitting apps
+// leaves them unchanged.
+func HandleSavePublisher(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		s := scopeOf(r)
+		if !s.global() && s.publisher != id {
+			writeError(w, http.StatusForbidden, "api key cannot manage publisher "+id)
+			return
+		}
+
+		var p models.Publisher
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		p.ID = id
+		p.Name = strings.TrimSpace(p.Name)
+		if p.Name == "" {
+			writeError(w, http.StatusBadRequest, "missing name")
+			return
+		}
+		p.Apps = normalizeApps(p.Apps)
+		p.BlockedCategories = normalizeCategories(p.BlockedCategories)
+		for _, app := range p.Apps {
+			if targeting.IsPattern(app) {
+				writeError(w, http.StatusBadRequest, "publisher apps must be exact app IDs: "+app)
+				return
+			}
+		}
+
+		// Omitting apps keeps the current ones
+		var owned []string
+		current, err := campaigns.GetPublisher(db, id)
+		switch {
+		case err == nil:
+			owned = current.Apps
+		case !errors.Is(err, sql.ErrNoRows):
+			slog.ErrorContext(r.Context(), "failed to get publisher", "publisher", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		if p.Apps == nil {
+			p.Apps = owned
+		} else if !s.global() && !slices.Equal(owned, p.Apps) {
+			writeError(w, http.StatusForbidden, "only global api keys can assign apps")
+			return
+		}
+
+		err = campaigns.SavePublisher(db, p)
+		if errors.Is(err, campaigns.ErrAppClaimed) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to save publisher", "publisher", id, logging.Err(err))
+			writeError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// normalizeApps lower-cases, de-duplicates and sorts app IDs. Nil stays nil.
+func normalizeApps(apps []string) []string {
+	if apps == nil {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(apps))
+	out := []string{}
+	for _, app := range apps {
+		app = targeting.NormalizeApp(app)
+		if _, dup := seen[app]; app == "" || dup {
+			continue
+		}
+		seen[app] = struct{}{}
+		out = append(out, app)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// normalizeCategories upper-cases IAB category codes ("iab9-30" -> "IAB9-30").
+func normalizeCategories(categories []string) []string {
+	if categories == nil {
+		return nil
+	}
+	out := make([]string, 0, len(categories))
+	for _, c := range categories {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
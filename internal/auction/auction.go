@@ -0,0 +1,127 @@
+// Package auction runs a sealed-bid second-price auction between the
+// campaigns matched for a delivery request.
+package auction
+
+import (
+	"log/slog"
+	"math"
+	"sort"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// DefaultIncrementCPM is added to the floor when the winner has no
+// competitor to set the price.
+const DefaultIncrementCPM = 0.01
+
+// Auction outcomes, also used as metric labels.
+const (
+	OutcomeWon        = "won"
+	OutcomeNoBid      = "no_bid"
+	OutcomeBelowFloor = "below_floor"
+)
+
+// Price sources reported in models.Auction.PriceSource.
+const (
+	PriceSecond = "second_price"
+	PriceFloor  = "floor"
+)
+
+// Config controls whether delivery runs an auction. The zero value returns
+// every matched campaign, as before auctions existed.
+type Config struct {
+	Enabled      bool
+	IncrementCPM float64
+}
+
+// Result is the outcome of one auction.
+type Result struct {
+	Outcome string
+	// Winner is the winning campaign with Auction set, or nil.
+	Winner *models.Campaign
+	// Auction holds the diagnostics, also copied onto Winner.
+	Auction models.Auction
+}
+
+// Run auctions candidates against floor. Campaigns without a positive bid
+// or bidding below floor do not take part. The highest bid wins (ties go to
+// the lowest campaign ID) and pays the second-highest bid, or floor plus
+// increment when it is the only bidder, never more than its own bid.
+// candidates is not modified.
+func Run(candidates []models.Campaign, floor, increment float64) Result {
+	res := Result{Auction: models.Auction{FloorCPM: floor}}
+
+	var eligible []int
+	for i, c := range candidates {
+		switch {
+		case c.BidCPM <= 0:
+			res.Auction.NoBid++
+		case c.BidCPM < floor:
+			res.Auction.BelowFloor++
+		default:
+			eligible = append(eligible, i)
+		}
+	}
+	res.Auction.Bidders = len(eligible)
+	if len(eligible) == 0 {
+		res.Outcome = OutcomeNoBid
+		if res.Auction.BelowFloor > 0 {
+			res.Outcome = OutcomeBelowFloor
+		}
+		return res
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		a, b := candidates[eligible[i]], candidates[eligible[j]]
+		if a.BidCPM != b.BidCPM {
+			return a.BidCPM > b.BidCPM
+		}
+		return a.ID < b.ID
+	})
+
+	winner := candidates[eligible[0]]
+	res.Auction.BidCPM = winner.BidCPM
+	if len(eligible) > 1 {
+		res.Auction.SecondCPM = candidates[eligible[1]].BidCPM
+		res.Auction.PriceCPM = res.Auction.SecondCPM
+		res.Auction.PriceSource = PriceSecond
+	} else {
+		res.Auction.PriceCPM = math.Min(floor+increment, winner.BidCPM)
+		res.Auction.PriceSource = PriceFloor
+	}
+	res.Auction.PriceCPM = round(res.Auction.PriceCPM)
+
+	diagnostics := res.Auction
+	winner.Auction = &diagnostics
+	res.Winner = &winner
+	res.Outcome = OutcomeWon
+	return res
+}
+
+// Campaigns returns the campaigns to deliver for the result: the winner
+// alone, or none.
+func (r Result) Campaigns() []models.Campaign {
+	if r.Winner == nil {
+		return []models.Campaign{}
+	}
+	return []models.Campaign{*r.Winner}
+}
+
+// round keeps prices to the 4 decimal places stored in the database.
+func round(cpm float64) float64 {
+	return math.Round(cpm*1e4) / 1e4
+}
+
+// LogAttrs describes a clearing price and its diagnostics in a log record.
+func LogAttrs(priceCPM float64, d models.AuctionDiagnostics) []slog.Attr {
+	return []slog.Attr{
+		slog.Float64("price_cpm", priceCPM),
+		slog.String("price_source", d.PriceSource),
+		slog.Float64("bid_cpm", d.BidCPM),
+		slog.Float64("second_cpm", d.SecondCPM),
+		slog.Float64("floor_cpm", d.FloorCPM),
+		slog.Int("bidders", d.Bidders),
+		slog.Int("below_floor", d.BelowFloor),
+		slog.Int("no_bid", d.NoBid),
+	}
+}
@@ -0,0 +1,131 @@
+package auction
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func bids(cpms map[string]float64) []models.Campaign {
+	var list []models.Campaign
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if cpm, ok := cpms[id]; ok {
+			list = append(list, models.Campaign{ID: id, BidCPM: cpm})
+		}
+	}
+	return list
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		bids     map[string]float64
+		floor    float64
+		outcome  string
+		winner   string
+		expected models.Auction
+	}{
+		{
+			name:     "Winner pays second price",
+			bids:     map[string]float64{"a": 1.2, "b": 2.5, "c": 1.8},
+			floor:    0.3,
+			outcome:  OutcomeWon,
+			winner:   "b",
+			expected: models.Auction{PriceCPM: 1.8, PriceSource: PriceSecond, BidCPM: 2.5, SecondCPM: 1.8, FloorCPM: 0.3, Bidders: 3},
+		},
+		{
+			name:     "Sole bidder pays floor plus increment",
+			bids:     map[string]float64{"a": 2, "b": 0.2, "c": 0},
+			floor:    0.5,
+			outcome:  OutcomeWon,
+			winner:   "a",
+			expected: models.Auction{PriceCPM: 0.51, PriceSource: PriceFloor, BidCPM: 2, FloorCPM: 0.5, Bidders: 1, BelowFloor: 1, NoBid: 1},
+		},
+		{
+			name:     "Price never exceeds own bid",
+			bids:     map[string]float64{"a": 0.505},
+			floor:    0.5,
+			outcome:  OutcomeWon,
+			winner:   "a",
+			expected: models.Auction{PriceCPM: 0.505, PriceSource: PriceFloor, BidCPM: 0.505, FloorCPM: 0.5, Bidders: 1},
+		},
+		{
+			name:     "Bid equal to floor is eligible",
+			bids:     map[string]float64{"a": 0.5, "b": 0.5},
+			floor:    0.5,
+			outcome:  OutcomeWon,
+			winner:   "a",
+			expected: models.Auction{PriceCPM: 0.5, PriceSource: PriceSecond, BidCPM: 0.5, SecondCPM: 0.5, FloorCPM: 0.5, Bidders: 2},
+		},
+		{
+			name:     "Ties go to the lowest campaign ID",
+			bids:     map[string]float64{"c": 3, "b": 3, "d": 1},
+			outcome:  OutcomeWon,
+			winner:   "b",
+			expected: models.Auction{PriceCPM: 3, PriceSource: PriceSecond, BidCPM: 3, SecondCPM: 3, Bidders: 3},
+		},
+		{
+			name:     "Everyone below floor",
+			bids:     map[string]float64{"a": 0.1, "b": 0},
+			floor:    0.5,
+			outcome:  OutcomeBelowFloor,
+			expected: models.Auction{FloorCPM: 0.5, BelowFloor: 1, NoBid: 1},
+		},
+		{
+			name:     "Nobody bids",
+			bids:     map[string]float64{"a": 0},
+			outcome:  OutcomeNoBid,
+			expected: models.Auction{NoBid: 1},
+		},
+		{
+			name:    "No candidates",
+			bids:    map[string]float64{},
+			outcome: OutcomeNoBid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := bids(tt.bids)
+			res := Run(candidates, tt.floor, DefaultIncrementCPM)
+
+			assert.Equal(t, tt.outcome, res.Outcome)
+			assert.Equal(t, tt.expected, res.Auction)
+			if tt.winner == "" {
+				assert.Nil(t, res.Winner)
+				assert.Empty(t, res.Campaigns())
+				return
+			}
+			require.NotNil(t, res.Winner)
+			assert.Equal(t, tt.winner, res.Winner.ID)
+			require.NotNil(t, res.Winner.Auction)
+			assert.Equal(t, tt.expected, *res.Winner.Auction)
+			assert.Equal(t, []models.Campaign{*res.Winner}, res.Campaigns())
+
+			// The matcher output is left untouched
+			for _, c := range candidates {
+				assert.Nil(t, c.Auction)
+			}
+		})
+	}
+}
+
+func TestWinnerJSON(t *testing.T) {
+	res := Run(bids(map[string]float64{"a": 1.2, "b": 2.5}), 0.3, DefaultIncrementCPM)
+
+	// Delivery responses carry the clearing price, never a bid
+	data, err := json.Marshal(res.Campaigns())
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"cid":"b","name":"","img":"","cta":"","status":"","auction":{"price_cpm":1.2}}]`, string(data))
+
+	// Debug responses add the diagnostics, leaving the result untouched
+	data, err = json.Marshal(models.WithAuctionDiagnostics(res.Campaigns()))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"cid":"b","name":"","img":"","cta":"","status":"","auction":{"price_cpm":1.2,"diagnostics":{
+		"price_source":"second_price","bid_cpm":2.5,"second_cpm":1.2,"floor_cpm":0.3,"bidders":2,"below_floor":0,"no_bid":0}}}]`, string(data))
+	assert.Nil(t, res.Winner.Auction.Debug)
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+)
+
+// Auditor counts requests per key and endpoint in memory and periodically
+// flushes them to api_key_usage, so auditing adds no per-request DB write.
+type Auditor struct {
+	db     *sql.DB
+	mu     sync.Mutex
+	counts map[usageKey]*usageCount
+}
+
+type usageKey struct {
+	keyID    string
+	window   time.Time
+	endpoint string
+}
+
+type usageCount struct {
+	requests, denied int64
+}
+
+// NewAuditor creates an auditor writing to db.
+func NewAuditor(db *sql.DB) *Auditor {
+	return &Auditor{db: db, counts: make(map[usageKey]*usageCount)}
+}
+
+// Record counts one request by keyID to endpoint.
+func (a *Auditor) Record(keyID, endpoint string, denied bool) {
+	k := usageKey{keyID: keyID, window: time.Now().UTC().Truncate(time.Minute), endpoint: endpoint}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, ok := a.counts[k]
+	if !ok {
+		c = &usageCount{}
+		a.counts[k] = c
+	}
+	c.requests++
+	if denied {
+		c.denied++
+	}
+}
+
+// Flush writes the pending counts. On failure they are merged back so the
+// next flush retries them.
+func (a *Auditor) Flush() error {
+	a.mu.Lock()
+	pending := a.counts
+	a.counts = make(map[usageKey]*usageCount)
+	a.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	usage := make([]Usage, 0, len(pending))
+	for k, c := range pending {
+		usage = append(usage, Usage{KeyID: k.keyID, WindowStart: k.window, Endpoint: k.endpoint, Requests: c.requests, Denied: c.denied})
+	}
+	if err := saveUsage(a.db, usage); err != nil {
+		a.mu.Lock()
+		for k, c := range pending {
+			if cur, ok := a.counts[k]; ok {
+				cur.requests += c.requests
+				cur.denied += c.denied
+			} else {
+				a.counts[k] = c
+			}
+		}
+		a.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is cancelled, then flushes once more.
+func (a *Auditor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := a.Flush(); err != nil {
+				slog.Error("failed to flush API key usage", logging.Err(err))
+			}
+			return
+		case <-ticker.C:
+			if err := a.Flush(); err != nil {
+				slog.Error("failed to flush API key usage", logging.Err(err))
+			}
+		}
+	}
+}
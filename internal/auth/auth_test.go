@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyAllowsApp(t *testing.T) {
+	gametion := []string{"com.gametion.ludokinggame", "com.gametion.snakes"}
+	tests := []struct {
+		name          string
+		publisher     string
+		publisherApps []string
+		apps          []string
+		app           string
+		expected      bool
+	}{
+		{"Unscoped key", "", nil, nil, "com.anything", true},
+		{"Exact app", "", nil, []string{"com.gametion.ludokinggame"}, "com.gametion.ludokinggame", true},
+		{"Case-insensitive", "", nil, []string{"com.Gametion.LudoKingGame"}, "COM.gametion.ludokinggame", true},
+		{"Glob pattern", "", nil, []string{"com.gametion.*"}, "com.gametion.ludokinggame", true},
+		{"Other app", "", nil, []string{"com.gametion.*"}, "com.spotify.music", false},
+		{"Missing app", "", nil, []string{"com.gametion.*"}, "", false},
+		{"Publisher's app", "gametion", gametion, nil, "COM.gametion.snakes", true},
+		{"Other publisher's app", "gametion", gametion, nil, "com.spotify.music", false},
+		{"Publisher without apps", "gametion", nil, nil, "com.gametion.snakes", false},
+		{"Publisher's app out of list", "gametion", gametion, []string{"com.gametion.ludo*"}, "com.gametion.snakes", false},
+		{"Glob beyond publisher", "gametion", gametion, []string{"com.*"}, "com.spotify.music", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &Key{Publisher: tt.publisher, publisherApps: tt.publisherApps, Apps: tt.apps}
+			assert.Equal(t, tt.expected, k.AllowsApp(tt.app))
+		})
+	}
+}
+
+func TestKeyHas(t *testing.T) {
+	k := &Key{Permissions: []Permission{PermDelivery, PermReporting}}
+	assert.True(t, k.Has(PermDelivery))
+	assert.True(t, k.Has(PermReporting))
+	assert.False(t, k.Has(PermAdmin))
+}
+
+func TestParsePermission(t *testing.T) {
+	p, err := ParsePermission(" Admin ")
+	require.NoError(t, err)
+	assert.Equal(t, PermAdmin, p)
+
+	_, err = ParsePermission("superuser")
+	assert.Error(t, err)
+}
+
+func TestGenerateToken(t *testing.T) {
+	token, hash, err := GenerateToken()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, tokenPrefix))
+	assert.Equal(t, HashToken(token), hash)
+	assert.Len(t, hash, 64)
+
+	other, _, err := GenerateToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestRequire(t *testing.T) {
+	keys := NewKeyring(nil, "bootstrap-secret")
+	keys.keys.Store(&map[string]*Key{
+		HashToken("reporting"): {ID: "r", Permissions: []Permission{PermReporting}},
+		HashToken("ludo"):      {ID: "l", Apps: []string{"com.gametion.*"}, Permissions: []Permission{PermDelivery}},
+		HashToken("gametion"): {
+			ID: "g", Publisher: "gametion", publisherApps: []string{"com.gametion.ludo"},
+			Permissions: []Permission{PermDelivery},
+		},
+	})
+	audit := NewAuditor(nil)
+	authn := NewAuthenticator(keys, audit)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, found := FromContext(r.Context())
+		require.True(t, found)
+		w.Write([]byte(key.ID))
+	})
+
+	tests := []struct {
+		name     string
+		perm     Permission
+		url      string
+		header   string
+		value    string
+		expected int
+	}{
+		{"Missing key", PermDelivery, "/v1/delivery?app=com.gametion.ludo", "", "", http.StatusUnauthorized},
+		{"Invalid key", PermDelivery, "/v1/delivery?app=com.gametion.ludo", "X-API-Key", "nope", http.StatusUnauthorized},
+		{"Bootstrap key", PermAdmin, "/admin/keys", "Authorization", "Bearer bootstrap-secret", http.StatusOK},
+		{"Missing permission", PermDelivery, "/v1/delivery?app=com.gametion.ludo", "X-API-Key", "reporting", http.StatusForbidden},
+		{"Permitted", PermReporting, "/metrics", "Authorization", "bearer reporting", http.StatusOK},
+		{"App in scope", PermDelivery, "/v1/delivery?app=com.gametion.ludo", "X-API-Key", "ludo", http.StatusOK},
+		{"App out of scope", PermDelivery, "/v1/delivery?app=com.spotify.music", "X-API-Key", "ludo", http.StatusForbidden},
+		{"Publisher's app", PermDelivery, "/v1/delivery?app=com.gametion.ludo", "X-API-Key", "gametion", http.StatusOK},
+		{"Other publisher's app", PermDelivery, "/v2/delivery?app=com.spotify.music", "X-API-Key", "gametion", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			authn.Require(tt.perm)(ok).ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+
+	// Only authenticated requests are audited: bootstrap, two reporting, two
+	// ludo and two gametion requests, of which one of each is denied.
+	var requests, denied int64
+	for k, c := range audit.counts {
+		assert.NotEmpty(t, k.keyID)
+		requests += c.requests
+		denied += c.denied
+	}
+	assert.Equal(t, int64(7), requests)
+	assert.Equal(t, int64(3), denied)
+}
+
+func TestMaySeeBids(t *testing.T) {
+	tests := []struct {
+		name     string
+		wrap     func(http.Handler) http.Handler
+		key      *Key
+		expected bool
+	}{
+		{"Global admin key", nil, &Key{Permissions: []Permission{PermDelivery, PermAdmin}}, true},
+		{"Delivery key", nil, &Key{Permissions: []Permission{PermDelivery}}, false},
+		{"Publisher admin key", nil, &Key{Publisher: "gametion", Permissions: []Permission{PermDelivery, PermAdmin}}, false},
+		{"Advertiser admin key", nil, &Key{Advertiser: "spotify", Permissions: []Permission{PermDelivery, PermAdmin}}, false},
+		{"No key", nil, nil, false},
+		{"Authentication off", Disabled, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = MaySeeBids(r.Context())
+			})
+			if tt.wrap != nil {
+				h = tt.wrap(h)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/v1/delivery", nil)
+			if tt.key != nil {
+				req = req.WithContext(NewContext(req.Context(), tt.key))
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestRequireAuditsRoutePatterns(t *testing.T) {
+	keys := NewKeyring(nil, "bootstrap-secret")
+	keys.keys.Store(&map[string]*Key{
+		HashToken("reporting"): {ID: "r", Permissions: []Permission{PermReporting}},
+	})
+	audit := NewAuditor(nil)
+	authn := NewAuthenticator(keys, audit)
+
+	r := chi.NewRouter()
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(authn.Require(PermAdmin))
+		r.Get("/campaigns/{cid}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+	for _, req := range []struct{ token, path string }{
+		{"reporting", "/admin/campaigns/spotify"},
+		{"reporting", "/admin/campaigns/duolingo"},
+		{"bootstrap-secret", "/admin/campaigns/spotify"},
+		{"bootstrap-secret", "/admin/campaigns/duolingo"},
+	} {
+		httpReq := httptest.NewRequest(http.MethodGet, req.path, nil)
+		httpReq.Header.Set("X-API-Key", req.token)
+		r.ServeHTTP(httptest.NewRecorder(), httpReq)
+	}
+
+	endpoints := map[string]usageCount{}
+	for k, c := range audit.counts {
+		endpoints[k.keyID+" "+k.endpoint] = *c
+	}
+	assert.Equal(t, map[string]usageCount{
+		"r /admin/*":                       {requests: 2, denied: 2},
+		"bootstrap /admin/campaigns/{cid}": {requests: 2},
+	}, endpoints)
+}
+
+func TestKeyringDatabaseUnreachable(t *testing.T) {
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	require.NoError(t, err)
+	defer db.Close()
+	path := filepath.Join(t.TempDir(), "snapshot.json.keys")
+	keys := NewKeyring(db, "bootstrap-secret", WithKeyPersistPath(path))
+
+	// Only the bootstrap token works until keys load
+	require.Error(t, keys.Refresh())
+	_, ok := keys.Authenticate("bootstrap-secret")
+	assert.True(t, ok)
+	_, ok = keys.Authenticate("gametion")
+	assert.False(t, ok)
+	loadedAt, err := keys.Status()
+	assert.True(t, loadedAt.IsZero())
+	assert.Error(t, err)
+	assert.Error(t, keys.LoadFile())
+
+	// Keys persisted by an earlier run are restored with their publisher apps
+	saved := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	require.NoError(t, saveKeys(path, map[string]*Key{
+		HashToken("gametion"): {
+			ID: "g", Publisher: "gametion", publisherApps: []string{"com.gametion.ludo"},
+			Permissions: []Permission{PermDelivery},
+		},
+	}, saved))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	require.NoError(t, keys.LoadFile())
+	key, ok := keys.Authenticate("gametion")
+	require.True(t, ok)
+	assert.True(t, key.AllowsApp("com.gametion.ludo"))
+	assert.False(t, key.AllowsApp("com.spotify.music"))
+	loadedAt, err = keys.Status()
+	assert.Equal(t, saved, loadedAt.UTC())
+	assert.Error(t, err, "restored keys are still not refreshed")
+}
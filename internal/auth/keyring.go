@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+)
+
+// BootstrapKeyID identifies the key configured through NewKeyring's
+// bootstrap token rather than the api_keys table.
+const BootstrapKeyID = "bootstrap"
+
+// Keyring holds the active API keys, with the apps of their publishers, in
+// memory so authentication never hits the database. Revocations and changes
+// to a publisher's apps take effect on the next refresh. With a persist path
+// it also writes every loaded key set to disk, so a server restarting while
+// the database is down can authenticate with the last one.
+type Keyring struct {
+	db          *sql.DB
+	keys        atomic.Pointer[map[string]*Key]
+	bootstrap   string
+	persistPath string
+
+	mu sync.Mutex
+	// loadedAt is when the keys in use were read from the database, zero
+	// until a load or LoadFile succeeds
+	loadedAt time.Time
+	// err is the error of the last Refresh, nil once one succeeds
+	err error
+}
+
+// KeyringOption configures a Keyring.
+type KeyringOption func(*Keyring)
+
+// WithKeyPersistPath saves each loaded key set to path and lets LoadFile
+// restore it.
+func WithKeyPersistPath(path string) KeyringOption {
+	return func(k *Keyring) {
+		k.persistPath = path
+	}
+}
+
+// NewKeyring creates a keyring backed by db. A non-empty bootstrapToken is
+// accepted as an unscoped key with every permission, so the first real keys
+// can be created through the admin API and the admin can still get in while
+// no keys have loaded.
+func NewKeyring(db *sql.DB, bootstrapToken string, opts ...KeyringOption) *Keyring {
+	k := &Keyring{db: db, bootstrap: bootstrapToken}
+	empty := map[string]*Key{}
+	k.keys.Store(&empty)
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
+// Refresh reloads the active keys and publisher apps. On error the previous
+// set is kept.
+func (k *Keyring) Refresh() error {
+	keys, err := loadActiveKeys(k.db)
+	now := time.Now()
+
+	k.mu.Lock()
+	k.err = err
+	if err == nil {
+		k.loadedAt = now
+	}
+	k.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	k.keys.Store(&keys)
+	if k.persistPath != "" {
+		if err := saveKeys(k.persistPath, keys, now); err != nil {
+			slog.Error("failed to persist API keys", "path", k.persistPath, logging.Err(err))
+		}
+	}
+	return nil
+}
+
+// LoadFile restores the keys last persisted to disk. They stay in use until
+// the next successful Refresh.
+func (k *Keyring) LoadFile() error {
+	if k.persistPath == "" {
+		return errors.New("no API key persist path configured")
+	}
+	keys, loadedAt, err := readKeys(k.persistPath)
+	if err != nil {
+		return err
+	}
+	k.keys.Store(&keys)
+
+	k.mu.Lock()
+	k.loadedAt = loadedAt
+	k.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of active keys, not counting the bootstrap token.
+func (k *Keyring) Len() int {
+	return len(*k.keys.Load())
+}
+
+// Status returns when the keys in use were read from the database, zero if
+// they never were, and the error of the last Refresh if it failed.
+func (k *Keyring) Status() (loadedAt time.Time, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.loadedAt, k.err
+}
+
+// Run refreshes the keyring every interval until ctx is cancelled.
+func (k *Keyring) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Refresh(); err != nil {
+				slog.Error("failed to refresh API keys", logging.Err(err))
+			}
+		}
+	}
+}
+
+// Authenticate resolves a token to its key.
+func (k *Keyring) Authenticate(token string) (*Key, bool) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, false
+	}
+	if k.bootstrap != "" && subtle.ConstantTimeCompare([]byte(token), []byte(k.bootstrap)) == 1 {
+		return &Key{
+			ID:          BootstrapKeyID,
+			Name:        "bootstrap",
+			Permissions: []Permission{PermDelivery, PermAdmin, PermReporting},
+		}, true
+	}
+	key, ok := (*k.keys.Load())[HashToken(token)]
+	return key, ok
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// keyFileFormat versions the persisted key file. Files written in another
+// format are rejected rather than misread.
+const keyFileFormat = 1
+
+// keyFile is the on-disk form of the active keys. Like api_keys it holds
+// token hashes, never tokens.
+type keyFile struct {
+	Format   int         `json:"format"`
+	LoadedAt time.Time   `json:"loaded_at"`
+	Keys     []keyRecord `json:"keys"`
+}
+
+type keyRecord struct {
+	Key
+	Hash          string   `json:"hash"`
+	PublisherApps []string `json:"publisher_apps,omitempty"`
+}
+
+// saveKeys writes keys to path atomically: readers see either the old file
+// or the complete new one. The file is only readable by its owner.
+func saveKeys(path string, keys map[string]*Key, loadedAt time.Time) error {
+	f := keyFile{Format: keyFileFormat, LoadedAt: loadedAt}
+	for hash, k := range keys {
+		f.Keys = append(f.Keys, keyRecord{Key: *k, Hash: hash, PublisherApps: k.publisherApps})
+	}
+
+	// CreateTemp opens the file with mode 0600
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(f); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readKeys reads keys written by saveKeys, indexed by token hash.
+func readKeys(path string) (map[string]*Key, time.Time, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, time.Time{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if f.Format != keyFileFormat {
+		return nil, time.Time{}, fmt.Errorf("%s: unsupported key file format %d", path, f.Format)
+	}
+
+	keys := make(map[string]*Key, len(f.Keys))
+	for _, rec := range f.Keys {
+		k := rec.Key
+		k.publisherApps = rec.PublisherApps
+		keys[rec.Hash] = &k
+	}
+	return keys, f.LoadedAt, nil
+}
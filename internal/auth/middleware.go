@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying key.
+func NewContext(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the key that authenticated the request, if any.
+func FromContext(ctx context.Context) (*Key, bool) {
+	k, ok := ctx.Value(contextKey{}).(*Key)
+	return k, ok
+}
+
+type disabledKey struct{}
+
+// Disabled is the route middleware that stands in for Require when
+// authentication is off: requests pass without a key, and are marked so
+// that they may do anything a key could.
+func Disabled(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), disabledKey{}, true)))
+	})
+}
+
+// MaySeeBids reports whether the request in ctx may see what campaigns bid.
+// Only global admin keys may, as only they see every campaign's bid through
+// the admin API, and any request passed by Disabled.
+func MaySeeBids(ctx context.Context) bool {
+	if k, ok := FromContext(ctx); ok {
+		return k.Has(PermAdmin) && k.Global()
+	}
+	disabled, _ := ctx.Value(disabledKey{}).(bool)
+	return disabled
+}
+
+// Authenticator builds route middleware from a keyring and an auditor.
+type Authenticator struct {
+	keys  *Keyring
+	audit *Auditor
+}
+
+// NewAuthenticator creates an authenticator. audit may be nil.
+func NewAuthenticator(keys *Keyring, audit *Auditor) *Authenticator {
+	return &Authenticator{keys: keys, audit: audit}
+}
+
+// Require rejects requests without a valid key carrying perm. For
+// PermDelivery the key must also be scoped to the requested app, which must
+// belong to the key's publisher if it has one. The key is
+// read from "Authorization: Bearer <token>" or the X-API-Key header.
+func (a *Authenticator) Require(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := tokenFromRequest(r)
+			if token == "" {
+				writeError(w, http.StatusUnauthorized, "missing api key")
+				return
+			}
+			key, ok := a.keys.Authenticate(token)
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "invalid api key")
+				return
+			}
+
+			if !key.Has(perm) {
+				a.record(key, endpointOf(r), true)
+				writeError(w, http.StatusForbidden, "api key lacks "+string(perm)+" permission")
+				return
+			}
+			if perm == PermDelivery && !key.AllowsApp(r.URL.Query().Get("app")) {
+				a.record(key, endpointOf(r), true)
+				writeError(w, http.StatusForbidden, "api key not authorised for app")
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(NewContext(r.Context(), key)))
+			a.record(key, endpointOf(r), ww.Status() == http.StatusUnauthorized || ww.Status() == http.StatusForbidden)
+		})
+	}
+}
+
+func (a *Authenticator) record(key *Key, endpoint string, denied bool) {
+	if a.audit != nil {
+		a.audit.Record(key.ID, endpoint, denied)
+	}
+}
+
+// UnroutedEndpoint is the usage endpoint of requests with no route pattern.
+const UnroutedEndpoint = "unrouted"
+
+// endpointOf names the request's route for usage records. It is the route
+// pattern so far, e.g. "/admin/*" when a group's middleware denies the
+// request, and never the raw path, whose IDs would add a row per value.
+func endpointOf(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return UnroutedEndpoint
+}
+
+func tokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="targeting-engine"`)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+const keyColumns = `id, name, prefix, COALESCE(publisher_id, ''), COALESCE(advertiser_id, ''), apps, permissions, created_at, last_used_at, revoked_at`
+
+// CreateKey stores a new key and returns it with the plaintext token. The
+// token cannot be recovered afterwards.
+func CreateKey(db *sql.DB, k Key) (*Key, string, error) {
+	token, hash, err := GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	if k.ID, err = newKeyID(); err != nil {
+		return nil, "", err
+	}
+	k.Prefix = token[:len(tokenPrefix)+6]
+
+	query := `
+	INSERT INTO api_keys (id, name, prefix, key_hash, publisher_id, advertiser_id, apps, permissions)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+	RETURNING created_at
+	`
+	err = db.QueryRow(query, k.ID, k.Name, k.Prefix, hash, k.Publisher, k.Advertiser,
+		pq.StringArray(k.Apps), pq.StringArray(permissionStrings(k.Permissions))).Scan(&k.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return &k, token, nil
+}
+
+// ListKeys returns every key, including revoked ones, newest first.
+func ListKeys(db *sql.DB) ([]Key, error) {
+	rows, err := db.Query(`SELECT ` + keyColumns + ` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+	for rows.Next() {
+		k, _, err := scanKey(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey marks a key revoked. It returns sql.ErrNoRows if no active key
+// has that ID.
+func RevokeKey(db *sql.DB, id string) error {
+	res, err := db.Exec(`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// loadActiveKeys returns the non-revoked keys indexed by token hash, each
+// with the apps of its publisher.
+func loadActiveKeys(db *sql.DB) (map[string]*Key, error) {
+	rows, err := db.Query(`SELECT ` + keyColumns + `, key_hash FROM api_keys WHERE revoked_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]*Key)
+	for rows.Next() {
+		k, hash, err := scanKey(rows, true)
+		if err != nil {
+			return nil, err
+		}
+		keys[hash] = k
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	apps, err := loadPublisherApps(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		k.publisherApps = apps[k.Publisher]
+	}
+	return keys, nil
+}
+
+// loadPublisherApps returns the apps of every publisher, normalised and
+// indexed by publisher ID.
+func loadPublisherApps(db *sql.DB) (map[string][]string, error) {
+	rows, err := db.Query(`SELECT publisher_id, app FROM publisher_apps`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apps := make(map[string][]string)
+	for rows.Next() {
+		var publisher, app string
+		if err := rows.Scan(&publisher, &app); err != nil {
+			return nil, err
+		}
+		apps[publisher] = append(apps[publisher], targeting.NormalizeApp(app))
+	}
+	return apps, rows.Err()
+}
+
+func scanKey(rows *sql.Rows, withHash bool) (*Key, string, error) {
+	var k Key
+	var perms []string
+	var lastUsed, revoked sql.NullTime
+	var hash string
+	dest := []interface{}{&k.ID, &k.Name, &k.Prefix, &k.Publisher, &k.Advertiser,
+		(*pq.StringArray)(&k.Apps), (*pq.StringArray)(&perms), &k.CreatedAt, &lastUsed, &revoked}
+	if withHash {
+		dest = append(dest, &hash)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, "", err
+	}
+	for _, p := range perms {
+		k.Permissions = append(k.Permissions, Permission(p))
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	return &k, hash, nil
+}
+
+func permissionStrings(perms []Permission) []string {
+	out := make([]string, len(perms))
+	for i, p := range perms {
+		out[i] = string(p)
+	}
+	return out
+}
+
+// Usage is the number of requests a key made to one endpoint within a
+// one-minute window, split by outcome.
+type Usage struct {
+	KeyID       string    `json:"key_id"`
+	WindowStart time.Time `json:"window_start"`
+	Endpoint    string    `json:"endpoint"`
+	Requests    int64     `json:"requests"`
+	Denied      int64     `json:"denied"`
+}
+
+// GetUsage returns a key's usage records since the given time, newest first.
+func GetUsage(db *sql.DB, keyID string, since time.Time) ([]Usage, error) {
+	query := `
+	SELECT key_id, window_start, endpoint, requests, denied
+	FROM api_key_usage
+	WHERE key_id = $1 AND window_start >= $2
+	ORDER BY window_start DESC, endpoint
+	`
+	rows, err := db.Query(query, keyID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []Usage
+	for rows.Next() {
+		var u Usage
+		if err := rows.Scan(&u.KeyID, &u.WindowStart, &u.Endpoint, &u.Requests, &u.Denied); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// saveUsage adds the counts to api_key_usage and bumps last_used_at.
+func saveUsage(db *sql.DB, usage []Usage) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert := `
+	INSERT INTO api_key_usage (key_id, window_start, endpoint, requests, denied)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (key_id, window_start, endpoint) DO UPDATE SET
+		requests = api_key_usage.requests + EXCLUDED.requests,
+		denied = api_key_usage.denied + EXCLUDED.denied
+	`
+	lastUsed := make(map[string]time.Time)
+	for _, u := range usage {
+		if _, err := tx.Exec(upsert, u.KeyID, u.WindowStart, u.Endpoint, u.Requests, u.Denied); err != nil {
+			return err
+		}
+		if u.WindowStart.After(lastUsed[u.KeyID]) {
+			lastUsed[u.KeyID] = u.WindowStart
+		}
+	}
+	for id, ts := range lastUsed {
+		_, err := tx.Exec(`UPDATE api_keys SET last_used_at = GREATEST(COALESCE(last_used_at, $2), $2) WHERE id = $1`, id, ts)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
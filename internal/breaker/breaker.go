@@ -0,0 +1,127 @@
+// Package breaker implements a circuit breaker guarding calls to a
+// dependency such as the database. After Threshold consecutive failures it
+// opens and rejects calls for Cooldown, then lets a single probe through:
+// the probe's success closes it again, its failure reopens it.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+)
+
+// ErrOpen is returned in place of calls rejected by an open breaker.
+var ErrOpen = errors.New("circuit breaker open")
+
+// State is the breaker state.
+type State int
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is safe for concurrent use. A nil *Breaker allows every call.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	metrics   *metrics.Registry
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+}
+
+// New creates a closed breaker that opens after threshold consecutive
+// failures. Its state is recorded in reg, labelled with name.
+func New(name string, threshold int, cooldown time.Duration, reg *metrics.Registry) *Breaker {
+	b := &Breaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now, metrics: reg}
+	reg.SetBreakerState(name, int(Closed))
+	return b
+}
+
+// Allow reports whether a call may proceed. Once the cooldown has passed,
+// an open breaker allows one probe and rejects other calls until the probe
+// reports back.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Closed {
+		return true
+	}
+	// Open, or half-open with a probe in flight. A probe that never
+	// reported back is replaced after another cooldown.
+	if b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.openedAt = b.now()
+	b.setState(HalfOpen)
+	return true
+}
+
+// Success records a successful call, closing the breaker.
+func (b *Breaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != Closed {
+		b.setState(Closed)
+	}
+}
+
+// Failure records a failed call, opening the breaker when the probe failed
+// or the threshold is reached.
+func (b *Breaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.setState(Open)
+	}
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState must be called with mu held.
+func (b *Breaker) setState(s State) {
+	b.state = s
+	b.metrics.SetBreakerState(b.name, int(s))
+}
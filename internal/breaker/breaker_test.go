@@ -0,0 +1,107 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+)
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		advance   time.Duration
+		call      string // "success", "failure" or "" (only Allow)
+		wantAllow bool
+		wantState State
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold consecutive failures",
+			steps: []step{
+				{call: "failure", wantAllow: true, wantState: Closed},
+				{call: "failure", wantAllow: true, wantState: Closed},
+				{call: "failure", wantAllow: true, wantState: Open},
+				{wantAllow: false, wantState: Open},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{call: "failure", wantAllow: true, wantState: Closed},
+				{call: "failure", wantAllow: true, wantState: Closed},
+				{call: "success", wantAllow: true, wantState: Closed},
+				{call: "failure", wantAllow: true, wantState: Closed},
+				{call: "failure", wantAllow: true, wantState: Closed},
+			},
+		},
+		{
+			name: "successful probe closes",
+			steps: []step{
+				{call: "failure", wantAllow: true},
+				{call: "failure", wantAllow: true},
+				{call: "failure", wantAllow: true, wantState: Open},
+				{advance: 5 * time.Second, call: "success", wantAllow: true, wantState: Closed},
+				{wantAllow: true, wantState: Closed},
+			},
+		},
+		{
+			name: "failed probe reopens",
+			steps: []step{
+				{call: "failure", wantAllow: true},
+				{call: "failure", wantAllow: true},
+				{call: "failure", wantAllow: true, wantState: Open},
+				{advance: 5 * time.Second, call: "failure", wantAllow: true, wantState: Open},
+				{advance: 4 * time.Second, wantAllow: false, wantState: Open},
+			},
+		},
+		{
+			name: "one probe at a time",
+			steps: []step{
+				{call: "failure", wantAllow: true},
+				{call: "failure", wantAllow: true},
+				{call: "failure", wantAllow: true, wantState: Open},
+				{advance: 5 * time.Second, wantAllow: true, wantState: HalfOpen},
+				{wantAllow: false, wantState: HalfOpen},
+				// The probe never reported back
+				{advance: 5 * time.Second, wantAllow: true, wantState: HalfOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+			reg := metrics.New()
+			b := New("test", 3, 5*time.Second, reg)
+			b.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.advance)
+				assert.Equal(t, s.wantAllow, b.Allow(), "step %d allow", i)
+				switch s.call {
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				}
+				assert.Equal(t, s.wantState, b.State(), "step %d state", i)
+				assert.Equal(t, float64(s.wantState), testutil.ToFloat64(reg.BreakerState.WithLabelValues("test")), "step %d gauge", i)
+			}
+		})
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	var b *Breaker
+	assert.True(t, b.Allow())
+	b.Failure()
+	b.Success()
+	assert.Equal(t, Closed, b.State())
+}
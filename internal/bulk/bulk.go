@@ -0,0 +1,178 @@
+// Package bulk imports and exports campaigns with their targeting rules as
+// CSV or JSON.
+package bulk
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/lifecycle"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// Formats accepted by Decode and Encode.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// ParseFormat validates a format name, ignoring case.
+func ParseFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case FormatCSV, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q: want csv or json", s)
+	}
+}
+
+// Entry is one campaign with all of its targeting rules. In JSON the
+// campaign fields sit next to "rules". Nil Rules leave an existing
+// campaign's rules as they are; an empty list removes them. JSON entries
+// without "rules" (or with null) have nil Rules, so only an explicit []
+// clears; CSV always lists a campaign's rules.
+type Entry struct {
+	models.Campaign
+	Rules []models.TargetingRule `json:"rules"`
+
+	// Omitted are the optional fields the input left out, which keep their
+	// current value: JSON keys that are missing or null, and CSV columns
+	// missing from the header.
+	Omitted []campaigns.Field `json:"-"`
+
+	// Row is where the entry starts in the input: the CSV line or the
+	// 1-based JSON array index.
+	Row int `json:"-"`
+}
+
+// RowError is a problem with one input row.
+type RowError struct {
+	Row   int    `json:"row"`
+	CID   string `json:"cid,omitempty"`
+	Error string `json:"error"`
+}
+
+// optionalFields are the campaign fields an entry may omit. Their names are
+// also their JSON keys and CSV columns.
+var optionalFields = []campaigns.Field{
+	campaigns.FieldAdvertiser, campaigns.FieldCategories,
+	campaigns.FieldCreativeRotation, campaigns.FieldBidCPM,
+}
+
+// Report summarises an import.
+type Report struct {
+	DryRun    bool       `json:"dry_run"`
+	Applied   bool       `json:"applied"`
+	Created   int        `json:"created"`
+	Updated   int        `json:"updated"`
+	Unchanged int        `json:"unchanged"`
+	Errors    []RowError `json:"errors"`
+}
+
+// Entries pairs each campaign with its rules, in the order of list.
+// Rules of campaigns not in list are left out.
+func Entries(list []models.Campaign, rules []models.TargetingRule) []Entry {
+	byCampaign := make(map[string][]models.TargetingRule)
+	for _, r := range rules {
+		byCampaign[r.CampaignID] = append(byCampaign[r.CampaignID], r)
+	}
+
+	entries := make([]Entry, 0, len(list))
+	for _, c := range list {
+		rs := byCampaign[c.ID]
+		if rs == nil {
+			rs = []models.TargetingRule{}
+		}
+		entries = append(entries, Entry{Campaign: c, Rules: rs})
+	}
+	return entries
+}
+
+// Validate normalises entries in place and returns every problem found.
+// Campaign IDs must be unique; a missing status means DRAFT for new
+// campaigns and "unchanged" for existing ones.
+func Validate(entries []Entry) []RowError {
+	errs := []RowError{}
+	fail := func(e *Entry, format string, args ...interface{}) {
+		errs = append(errs, RowError{Row: e.Row, CID: e.ID, Error: fmt.Sprintf(format, args...)})
+	}
+
+	seen := make(map[string]int, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		e.ID = strings.TrimSpace(e.ID)
+		if e.ID == "" {
+			fail(e, "missing cid")
+			continue
+		}
+		if row, dup := seen[e.ID]; dup {
+			fail(e, "duplicate cid, first seen in row %d", row)
+			continue
+		}
+		seen[e.ID] = e.Row
+
+		if e.Name = strings.TrimSpace(e.Name); e.Name == "" {
+			fail(e, "missing name")
+		}
+		if e.Status != "" {
+			status, err := lifecycle.ParseStatus(e.Status)
+			if err != nil {
+				fail(e, "%v", err)
+			}
+			e.Status = status
+		}
+		if e.BidCPM < 0 {
+			fail(e, "bid_cpm must be zero or positive")
+		}
+		rotation, err := creatives.ParseRotation(e.CreativeRotation)
+		if err != nil {
+			fail(e, "%v", err)
+		}
+		e.CreativeRotation = rotation
+		e.AdvertiserID = strings.TrimSpace(e.AdvertiserID)
+		e.Categories = normalizeCategories(e.Categories)
+
+		for j := range e.Rules {
+			e.Rules[j].CampaignID = e.ID
+			if err := targeting.NormalizeRule(&e.Rules[j]); err != nil {
+				fail(e, "rule %d: %v", j+1, err)
+			}
+		}
+	}
+	return errs
+}
+
+// normalizeCategories upper-cases IAB category codes ("iab9-30" -> "IAB9-30").
+func normalizeCategories(categories []string) []string {
+	if categories == nil {
+		return nil
+	}
+	out := make([]string, 0, len(categories))
+	for _, c := range categories {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Decode reads entries in format. Row errors are problems with individual
+// rows; an error means the input could not be read at all.
+func Decode(format string, r io.Reader) ([]Entry, []RowError, error) {
+	if format == FormatCSV {
+		return DecodeCSV(r)
+	}
+	return DecodeJSON(r)
+}
+
+// Encode writes entries in format.
+func Encode(format string, w io.Writer, entries []Entry) error {
+	if format == FormatCSV {
+		return EncodeCSV(w, entries)
+	}
+	return EncodeJSON(w, entries)
+}
@@ -0,0 +1,236 @@
+package bulk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/synth"
+)
+
+func sampleEntries() []Entry {
+	return []Entry{
+		{
+			Campaign: models.Campaign{ID: "spotify", Name: "Spotify", Img: "https://x/s.png", CTA: "Download",
+				Status: "ACTIVE", AdvertiserID: "acme", Categories: []string{"IAB1", "IAB9-30"}, BidCPM: 2.5},
+			Rules: []models.TargetingRule{
+				{CampaignID: "spotify", IncludeCountry: []string{"us", "ca"}, ExcludeOS: []string{"web"}},
+				{CampaignID: "spotify", IncludeApp: []string{"com.test.*"},
+					IncludeGeofence: []models.Geofence{{Lat: 12.97, Lon: 77.59, RadiusKm: 5}}},
+			},
+		},
+		{
+			Campaign: models.Campaign{ID: "duolingo", Name: "Duolingo, the app", Status: "DRAFT", CreativeRotation: "weighted"},
+			Rules:    []models.TargetingRule{},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Encode(format, &buf, sampleEntries()))
+
+			got, rowErrs, err := Decode(format, &buf)
+			require.NoError(t, err)
+			assert.Empty(t, rowErrs)
+			require.Len(t, got, 2)
+
+			want := sampleEntries()
+			for i := range got {
+				assert.Equal(t, want[i].Campaign, got[i].Campaign)
+				if len(want[i].Rules) == 0 {
+					assert.NotNil(t, got[i].Rules, "no rules clears them")
+					assert.Empty(t, got[i].Rules)
+					continue
+				}
+				require.Len(t, got[i].Rules, len(want[i].Rules))
+				for j := range want[i].Rules {
+					r := got[i].Rules[j]
+					r.CampaignID = want[i].Rules[j].CampaignID
+					assert.Equal(t, want[i].Rules[j], r)
+				}
+			}
+			assert.Empty(t, Validate(got))
+		})
+	}
+}
+
+func TestDecodeCSVRowErrors(t *testing.T) {
+	header := strings.Join(Header(), ",")
+	tests := []struct {
+		name     string
+		rows     []string
+		row      int
+		errorMsg string
+	}{
+		{"Campaign columns disagree", []string{
+			"a,A,,,,,,,,1,us",
+			"a,Other,,,,,,,,2,ca",
+		}, 3, "name differs from row 2"},
+		{"Bad bid", []string{"a,A,,,,,,cheap,,1"}, 2, `invalid bid_cpm "cheap"`},
+		{"Rule cells without rule number", []string{"a,A,,,,,,,,,us"}, 2, "rule column is empty"},
+		{"Repeated rule number", []string{
+			"a,A,,,,,,,,1,us",
+			"a,A,,,,,,,,1,ca",
+		}, 3, "rule 1 appears twice"},
+		{"Rules after a rule-less row", []string{
+			"a,A,,,,,,,,",
+			"a,A,,,,,,,,1,us",
+		}, 3, "without rules must have a single row"},
+		{"Bad geofence", []string{"a,A,,,,,,,,1" + strings.Repeat(",", 14) + ",not json"}, 2, "invalid include_geofence"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := header + "\n" + strings.Join(tt.rows, "\n") + "\n"
+			_, rowErrs, err := DecodeCSV(strings.NewReader(in))
+			require.NoError(t, err)
+			require.Len(t, rowErrs, 1)
+			assert.Equal(t, tt.row, rowErrs[0].Row)
+			assert.Contains(t, rowErrs[0].Error, tt.errorMsg)
+		})
+	}
+}
+
+func TestDecodeJSONRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantRules []models.TargetingRule
+	}{
+		{"Missing key leaves rules unchanged", `[{"cid":"a","name":"A","bid_cpm":1.5}]`, nil},
+		{"Null leaves rules unchanged", `[{"cid":"a","name":"A","bid_cpm":1.5,"rules":null}]`, nil},
+		{"Empty list clears rules", `[{"cid":"a","name":"A","bid_cpm":1.5,"rules":[]}]`, []models.TargetingRule{}},
+		{"Rules replace rules", `[{"cid":"a","name":"A","bid_cpm":1.5,"rules":[{"include_os":["ios"]}]}]`,
+			[]models.TargetingRule{{IncludeOS: []string{"ios"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, rowErrs, err := DecodeJSON(strings.NewReader(tt.input))
+			require.NoError(t, err)
+			assert.Empty(t, rowErrs)
+			require.Len(t, entries, 1)
+			assert.Equal(t, 1.5, entries[0].BidCPM)
+			assert.Equal(t, tt.wantRules, entries[0].Rules)
+
+			// Encoding keeps the difference
+			var buf bytes.Buffer
+			require.NoError(t, EncodeJSON(&buf, entries))
+			again, _, err := DecodeJSON(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRules, again[0].Rules)
+		})
+	}
+}
+
+func TestDecodeOmitted(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		input       string
+		wantOmitted []campaigns.Field
+	}{
+		{"JSON with every field", FormatJSON,
+			`[{"cid":"a","name":"A","advertiser_id":"","categories":[],"creative_rotation":"","bid_cpm":0}]`, nil},
+		{"JSON missing or null fields", FormatJSON, `[{"cid":"a","name":"A","Categories":["IAB1"],"bid_cpm":null}]`,
+			[]campaigns.Field{campaigns.FieldAdvertiser, campaigns.FieldCreativeRotation, campaigns.FieldBidCPM}},
+		{"CSV with every column", FormatCSV, strings.Join(Header(), ",") + "\na,A,,,,,,,,\n", nil},
+		{"CSV missing columns", FormatCSV, "cid,name,img,cta,status,categories,rule\na,A,,,,,\n",
+			[]campaigns.Field{campaigns.FieldAdvertiser, campaigns.FieldCreativeRotation, campaigns.FieldBidCPM}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, rowErrs, err := Decode(tt.format, strings.NewReader(tt.input))
+			require.NoError(t, err)
+			assert.Empty(t, rowErrs)
+			require.Len(t, entries, 1)
+			assert.Equal(t, tt.wantOmitted, entries[0].Omitted)
+		})
+	}
+}
+
+func TestDecodeCSVHeader(t *testing.T) {
+	_, _, err := DecodeCSV(strings.NewReader("cid,name,color\n"))
+	assert.EqualError(t, err, `unknown CSV column "color"`)
+
+	_, _, err = DecodeCSV(strings.NewReader("cid,name\n"))
+	assert.EqualError(t, err, `missing CSV column "img"`)
+
+	// Rule columns are optional and rules are ordered by number
+	entries, rowErrs, err := DecodeCSV(strings.NewReader(
+		"cid,name,img,cta,status,advertiser_id,categories,bid_cpm,creative_rotation,rule,include_os\n" +
+			"a,A,,,,,,,,2,ios\n" +
+			"a,A,,,,,,,,1,android\n"))
+	require.NoError(t, err)
+	assert.Empty(t, rowErrs)
+	require.Len(t, entries, 1)
+	require.Len(t, entries[0].Rules, 2)
+	assert.Equal(t, []string{"android"}, entries[0].Rules[0].IncludeOS)
+	assert.Equal(t, []string{"ios"}, entries[0].Rules[1].IncludeOS)
+}
+
+func TestEntries(t *testing.T) {
+	list := []models.Campaign{{ID: "spotify"}, {ID: "duolingo"}}
+	rules := []models.TargetingRule{
+		{CampaignID: "spotify", IncludeOS: []string{"ios"}},
+		{CampaignID: "gone", IncludeOS: []string{"web"}},
+		{CampaignID: "spotify", IncludeOS: []string{"android"}},
+	}
+
+	entries := Entries(list, rules)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "spotify", entries[0].ID)
+	assert.Equal(t, []models.TargetingRule{rules[0], rules[2]}, entries[0].Rules)
+	assert.Equal(t, "duolingo", entries[1].ID)
+	assert.NotNil(t, entries[1].Rules)
+	assert.Empty(t, entries[1].Rules)
+}
+
+func TestSynthCatalogueIsValid(t *testing.T) {
+	entries := Entries(synth.Catalogue(1000, 1))
+	assert.Empty(t, Validate(entries))
+}
+
+func TestValidate(t *testing.T) {
+	entries := []Entry{
+		{Row: 1, Campaign: models.Campaign{ID: " a ", Name: "A", Status: "active", Categories: []string{"iab9-30"}},
+			Rules: []models.TargetingRule{{IncludeCountry: []string{"Canada"}}}},
+		{Row: 2, Campaign: models.Campaign{ID: "a", Name: "Again"}},
+		{Row: 3, Campaign: models.Campaign{Name: "No ID"}},
+		{Row: 4, Campaign: models.Campaign{ID: "b"}},
+		{Row: 5, Campaign: models.Campaign{ID: "c", Name: "C", Status: "LIVE"}},
+		{Row: 6, Campaign: models.Campaign{ID: "d", Name: "D", BidCPM: -1}},
+		{Row: 7, Campaign: models.Campaign{ID: "e", Name: "E", CreativeRotation: "random"}},
+		{Row: 8, Campaign: models.Campaign{ID: "f", Name: "F"},
+			Rules: []models.TargetingRule{{IncludeCountry: []string{"atlantis"}}}},
+	}
+
+	errs := Validate(entries)
+	got := make(map[int]string)
+	for _, e := range errs {
+		got[e.Row] = e.Error
+	}
+	assert.NotContains(t, got, 1)
+	assert.Contains(t, got[2], "duplicate cid, first seen in row 1")
+	assert.Equal(t, "missing cid", got[3])
+	assert.Equal(t, "missing name", got[4])
+	assert.Contains(t, got[5], `unknown campaign status "LIVE"`)
+	assert.Equal(t, "bid_cpm must be zero or positive", got[6])
+	assert.Contains(t, got[7], `unknown creative rotation "random"`)
+	assert.Contains(t, got[8], "rule 1:")
+
+	a := entries[0]
+	assert.Equal(t, "a", a.ID)
+	assert.Equal(t, "ACTIVE", a.Status)
+	assert.Equal(t, []string{"IAB9-30"}, a.Categories)
+	assert.Equal(t, "a", a.Rules[0].CampaignID)
+	assert.Equal(t, []string{"ca"}, a.Rules[0].IncludeCountry)
+}
@@ -0,0 +1,354 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// CSV holds one targeting rule per row. The campaign columns repeat on every
+// row of a campaign and must agree; a campaign without rules has one row
+// with an empty "rule" column and no rule cells. List cells are separated
+// by "|" and an empty cell means no constraint. Geofence cells hold the
+// JSON array used by the admin API.
+var campaignHeader = []string{
+	"cid", "name", "img", "cta", "status", "advertiser_id", "categories",
+	"bid_cpm", "creative_rotation", "rule",
+}
+
+// listSeparator joins list values within a CSV cell.
+const listSeparator = "|"
+
+// ruleField is a targeting rule column: its name and how to reach the list
+// or geofences it holds.
+type ruleField struct {
+	name   string
+	list   func(r *models.TargetingRule) *[]string
+	fences func(r *models.TargetingRule) *[]models.Geofence
+}
+
+var ruleFields = []ruleField{
+	{name: "include_country", list: func(r *models.TargetingRule) *[]string { return &r.IncludeCountry }},
+	{name: "exclude_country", list: func(r *models.TargetingRule) *[]string { return &r.ExcludeCountry }},
+	{name: "include_os", list: func(r *models.TargetingRule) *[]string { return &r.IncludeOS }},
+	{name: "exclude_os", list: func(r *models.TargetingRule) *[]string { return &r.ExcludeOS }},
+	{name: "include_app", list: func(r *models.TargetingRule) *[]string { return &r.IncludeApp }},
+	{name: "exclude_app", list: func(r *models.TargetingRule) *[]string { return &r.ExcludeApp }},
+	{name: "include_region", list: func(r *models.TargetingRule) *[]string { return &r.IncludeRegion }},
+	{name: "exclude_region", list: func(r *models.TargetingRule) *[]string { return &r.ExcludeRegion }},
+	{name: "include_city", list: func(r *models.TargetingRule) *[]string { return &r.IncludeCity }},
+	{name: "exclude_city", list: func(r *models.TargetingRule) *[]string { return &r.ExcludeCity }},
+	{name: "include_segment", list: func(r *models.TargetingRule) *[]string { return &r.IncludeSegment }},
+	{name: "exclude_segment", list: func(r *models.TargetingRule) *[]string { return &r.ExcludeSegment }},
+	{name: "include_placement", list: func(r *models.TargetingRule) *[]string { return &r.IncludePlacement }},
+	{name: "exclude_placement", list: func(r *models.TargetingRule) *[]string { return &r.ExcludePlacement }},
+	{name: "include_geofence", fences: func(r *models.TargetingRule) *[]models.Geofence { return &r.IncludeGeofence }},
+	{name: "exclude_geofence", fences: func(r *models.TargetingRule) *[]models.Geofence { return &r.ExcludeGeofence }},
+}
+
+// Header returns the CSV header row.
+func Header() []string {
+	header := append([]string{}, campaignHeader...)
+	for _, f := range ruleFields {
+		header = append(header, f.name)
+	}
+	return header
+}
+
+// EncodeCSV writes entries as CSV, one row per rule.
+func EncodeCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Header()); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		campaign := campaignCells(e.Campaign)
+		if len(e.Rules) == 0 {
+			row := append(campaign, "")
+			if err := cw.Write(append(row, make([]string, len(ruleFields))...)); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := range e.Rules {
+			row := append(append([]string{}, campaign...), strconv.Itoa(i+1))
+			cells, err := ruleCells(&e.Rules[i])
+			if err != nil {
+				return fmt.Errorf("campaign %s rule %d: %w", e.ID, i+1, err)
+			}
+			if err := cw.Write(append(row, cells...)); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func campaignCells(c models.Campaign) []string {
+	bid := ""
+	if c.BidCPM != 0 {
+		bid = strconv.FormatFloat(c.BidCPM, 'f', -1, 64)
+	}
+	return []string{c.ID, c.Name, c.Img, c.CTA, c.Status, c.AdvertiserID,
+		joinList(c.Categories), bid, c.CreativeRotation}
+}
+
+func ruleCells(r *models.TargetingRule) ([]string, error) {
+	cells := make([]string, 0, len(ruleFields))
+	for _, f := range ruleFields {
+		if f.list != nil {
+			cells = append(cells, joinList(*f.list(r)))
+			continue
+		}
+		fences := *f.fences(r)
+		if fences == nil {
+			cells = append(cells, "")
+			continue
+		}
+		raw, err := json.Marshal(fences)
+		if err != nil {
+			return nil, err
+		}
+		cells = append(cells, string(raw))
+	}
+	return cells, nil
+}
+
+func joinList(values []string) string {
+	return strings.Join(values, listSeparator)
+}
+
+// splitList parses a list cell; an empty cell is nil (no constraint).
+func splitList(cell string) []string {
+	if strings.TrimSpace(cell) == "" {
+		return nil
+	}
+	var values []string
+	for _, v := range strings.Split(cell, listSeparator) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// DecodeCSV reads entries written by EncodeCSV. Rows of one campaign need
+// not be adjacent, and optional campaign columns missing from the header
+// are omitted from every entry. Problems with individual rows are returned as row errors
+// (rows count from 1, the header); a malformed file or header is an error.
+func DecodeCSV(r io.Reader) ([]Entry, []RowError, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	// Spreadsheets often drop trailing empty cells
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("empty CSV: missing header row")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns, err := columnIndex(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	var omitted []campaigns.Field
+	for _, f := range optionalFields {
+		if _, ok := columns[string(f)]; !ok {
+			omitted = append(omitted, f)
+		}
+	}
+
+	var (
+		entries  []*Entry
+		byCID    = make(map[string]*Entry)
+		ruleRows = make(map[*Entry][]numberedRule)
+		noRules  = make(map[*Entry]bool)
+		rowErrs  []RowError
+	)
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		fail := func(cid, format string, args ...interface{}) {
+			rowErrs = append(rowErrs, RowError{Row: line, CID: cid, Error: fmt.Sprintf(format, args...)})
+		}
+
+		c, err := parseCampaign(cell)
+		if err != nil {
+			fail(c.ID, "%v", err)
+			continue
+		}
+		if c.ID == "" {
+			fail("", "missing cid")
+			continue
+		}
+
+		e, seen := byCID[c.ID]
+		if !seen {
+			e = &Entry{Campaign: c, Omitted: omitted, Row: line}
+			byCID[c.ID] = e
+			entries = append(entries, e)
+		} else if diff := campaignMismatch(e.Campaign, c); diff != "" {
+			fail(c.ID, "%s differs from row %d of the same campaign", diff, e.Row)
+			continue
+		}
+
+		rule, empty, err := parseRule(cell)
+		if err != nil {
+			fail(c.ID, "%v", err)
+			continue
+		}
+		index := cell("rule")
+		if index == "" {
+			if !empty {
+				fail(c.ID, "rule cells set but the rule column is empty")
+				continue
+			}
+			if seen && (noRules[e] || len(ruleRows[e]) > 0) {
+				fail(c.ID, "a campaign without rules must have a single row")
+				continue
+			}
+			noRules[e] = true
+			continue
+		}
+		if noRules[e] {
+			fail(c.ID, "a campaign without rules must have a single row")
+			continue
+		}
+		n, err := strconv.Atoi(index)
+		if err != nil || n < 1 {
+			fail(c.ID, "rule must be a positive number, got %q", index)
+			continue
+		}
+		if slices.ContainsFunc(ruleRows[e], func(r numberedRule) bool { return r.n == n }) {
+			fail(c.ID, "rule %d appears twice", n)
+			continue
+		}
+		ruleRows[e] = append(ruleRows[e], numberedRule{n, rule})
+	}
+
+	out := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		rules := ruleRows[e]
+		sort.Slice(rules, func(i, j int) bool { return rules[i].n < rules[j].n })
+		// A CSV campaign always lists its rules: none clears them
+		e.Rules = make([]models.TargetingRule, 0, len(rules))
+		for _, r := range rules {
+			e.Rules = append(e.Rules, r.rule)
+		}
+		out = append(out, *e)
+	}
+	return out, rowErrs, nil
+}
+
+// numberedRule is a rule with its number from the "rule" column.
+type numberedRule struct {
+	n    int
+	rule models.TargetingRule
+}
+
+// columnIndex maps header names to positions. The campaign columns and
+// "rule" are required, except optional fields; rule columns may be left
+// out.
+func columnIndex(header []string) (map[string]int, error) {
+	known := make(map[string]bool)
+	for _, name := range Header() {
+		known[name] = true
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[name] {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		if _, dup := columns[name]; dup {
+			return nil, fmt.Errorf("duplicate CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range campaignHeader {
+		if _, ok := columns[name]; !ok && !slices.Contains(optionalFields, campaigns.Field(name)) {
+			return nil, fmt.Errorf("missing CSV column %q", name)
+		}
+	}
+	return columns, nil
+}
+
+func parseCampaign(cell func(string) string) (models.Campaign, error) {
+	c := models.Campaign{
+		ID:               cell("cid"),
+		Name:             cell("name"),
+		Img:              cell("img"),
+		CTA:              cell("cta"),
+		Status:           cell("status"),
+		AdvertiserID:     cell("advertiser_id"),
+		Categories:       splitList(cell("categories")),
+		CreativeRotation: cell("creative_rotation"),
+	}
+	if bid := cell("bid_cpm"); bid != "" {
+		v, err := strconv.ParseFloat(bid, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid bid_cpm %q", bid)
+		}
+		c.BidCPM = v
+	}
+	return c, nil
+}
+
+// campaignMismatch names the first campaign column in which a and b differ,
+// or returns "".
+func campaignMismatch(a, b models.Campaign) string {
+	x, y := campaignCells(a), campaignCells(b)
+	for i := range x {
+		if x[i] != y[i] {
+			return campaignHeader[i]
+		}
+	}
+	return ""
+}
+
+// parseRule reads the rule columns of a row and reports whether they were
+// all empty.
+func parseRule(cell func(string) string) (models.TargetingRule, bool, error) {
+	var r models.TargetingRule
+	empty := true
+	for _, f := range ruleFields {
+		v := cell(f.name)
+		if v == "" {
+			continue
+		}
+		empty = false
+		if f.list != nil {
+			*f.list(&r) = splitList(v)
+			continue
+		}
+		var fences []models.Geofence
+		if err := json.Unmarshal([]byte(v), &fences); err != nil {
+			return r, false, fmt.Errorf("invalid %s: %v", f.name, err)
+		}
+		*f.fences(&r) = fences
+	}
+	return r, empty, nil
+}
@@ -0,0 +1,86 @@
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// entryJSON is the JSON form of an Entry. Unlike delivery responses it
+// carries the bid. Rules is a pointer so that a missing "rules" key can be
+// told apart from an empty list.
+type entryJSON struct {
+	models.CampaignRecord
+	Rules *[]models.TargetingRule `json:"rules,omitempty"`
+}
+
+// MarshalJSON writes the campaign fields, bid included, next to "rules",
+// which is left out when Rules is nil.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	v := entryJSON{CampaignRecord: models.NewCampaignRecord(e.Campaign)}
+	if e.Rules != nil {
+		v.Rules = &e.Rules
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON reads an entry written by MarshalJSON. Rules stay nil when
+// "rules" is missing or null, and optional campaign fields that are missing
+// or null are listed in Omitted.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var v entryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	// Keys match case-insensitively, as they do for v
+	present := make(map[string]bool, len(fields))
+	for k, raw := range fields {
+		if string(raw) != "null" {
+			present[strings.ToLower(k)] = true
+		}
+	}
+
+	e.Campaign, e.Rules, e.Omitted = v.Model(), nil, nil
+	for _, f := range optionalFields {
+		if !present[string(f)] {
+			e.Omitted = append(e.Omitted, f)
+		}
+	}
+	if v.Rules != nil {
+		e.Rules = *v.Rules
+		if e.Rules == nil {
+			e.Rules = []models.TargetingRule{}
+		}
+	}
+	return nil
+}
+
+// DecodeJSON reads a JSON array of entries. Rows are 1-based array
+// indexes.
+func DecodeJSON(r io.Reader) ([]Entry, []RowError, error) {
+	var entries []Entry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	for i := range entries {
+		entries[i].Row = i + 1
+	}
+	return entries, nil, nil
+}
+
+// EncodeJSON writes entries as an indented JSON array.
+func EncodeJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
@@ -0,0 +1,149 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/lifecycle"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// ChangeImport names bulk imports in campaign history.
+const ChangeImport = "bulk.import"
+
+// Options controls an import.
+type Options struct {
+	// Actor is recorded in the history of every changed campaign.
+	Actor string
+	// DryRun validates and counts the changes but commits nothing.
+	DryRun bool
+	// Advertiser limits the import to one advertiser's campaigns: entries
+	// without an advertiser are assigned to it, new campaigns start in
+	// DRAFT and campaigns of other advertisers are rejected.
+	Advertiser string
+}
+
+// Apply validates entries and upserts them, keyed by cid, in a single
+// transaction. rowErrs are errors found while decoding them. If any row has
+// an error nothing is written and the report lists every error. Status is
+// only taken from new campaigns, and recorded in their status history when
+// it is not DRAFT; an existing campaign's status must be left empty or
+// unchanged, as it moves only through lifecycle actions. Entries with nil
+// Rules keep the campaign's current rules, and omitted fields keep their
+// current values.
+func Apply(db *sql.DB, entries []Entry, rowErrs []RowError, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Errors: append(append([]RowError{}, rowErrs...), Validate(entries)...)}
+	invalid := make(map[int]bool, len(report.Errors))
+	for _, e := range report.Errors {
+		invalid[e.Row] = true
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i := range entries {
+		e := &entries[i]
+		if invalid[e.Row] {
+			continue
+		}
+		current, err := campaigns.LockCampaign(tx, e.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			current, err = nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg, err := check(tx, e, current, opts); err != nil {
+			return nil, err
+		} else if msg != "" {
+			report.Errors = append(report.Errors, RowError{Row: e.Row, CID: e.ID, Error: msg})
+			continue
+		}
+
+		v, err := campaigns.RecordChange(tx, e.ID, opts.Actor, ChangeImport, func() error {
+			if err := campaigns.UpsertCampaign(tx, e.Campaign, e.Omitted, e.Rules); err != nil {
+				return err
+			}
+			if current != nil {
+				return nil
+			}
+			return campaigns.RecordImportedStatus(tx, e.ID, e.Status, opts.Actor)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("campaign %s: %w", e.ID, err)
+		}
+		switch {
+		case current == nil:
+			report.Created++
+		case v == nil:
+			report.Unchanged++
+		default:
+			report.Updated++
+		}
+	}
+
+	if len(report.Errors) > 0 || opts.DryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	report.Applied = true
+	return report, nil
+}
+
+// check applies the rules that need the database to an entry, returning a
+// row error message or "". current is nil for a new campaign.
+func check(tx *sql.Tx, e *Entry, current *models.Campaign, opts Options) (string, error) {
+	if opts.Advertiser != "" {
+		if current != nil && current.AdvertiserID != opts.Advertiser {
+			return "campaign belongs to another advertiser", nil
+		}
+		if e.AdvertiserID == "" {
+			e.AdvertiserID = opts.Advertiser
+			e.Omitted = slices.DeleteFunc(e.Omitted, func(f campaigns.Field) bool { return f == campaigns.FieldAdvertiser })
+		}
+		if e.AdvertiserID != opts.Advertiser {
+			return "advertiser_id must be " + opts.Advertiser, nil
+		}
+		if current == nil && e.Status != "" && e.Status != lifecycle.StatusDraft {
+			return "new campaigns must start in " + lifecycle.StatusDraft, nil
+		}
+	}
+	if current != nil && e.Status != "" && e.Status != current.Status {
+		return fmt.Sprintf("status is %s; use lifecycle actions to change it", current.Status), nil
+	}
+
+	if e.AdvertiserID != "" {
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM advertisers WHERE id = $1)`, e.AdvertiserID).Scan(&exists)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "unknown advertiser " + e.AdvertiserID, nil
+		}
+	}
+	return "", nil
+}
+
+// Export returns every campaign with its rules, or only an advertiser's
+// if advertiserID is not empty.
+func Export(ctx context.Context, db *sql.DB, advertiserID string) ([]Entry, error) {
+	list, err := campaigns.ListCampaigns(ctx, db, advertiserID)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := campaigns.ListTargetingRules(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return Entries(list, rules), nil
+}
@@ -1,70 +1,223 @@
 package campaigns
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
 	"strings"
-	"time"
 
-	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/lib/pq"
+
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
 )
 
-func GetMatchingCampaigns(db *sql.DB, app, country, os string) ([]models.Campaign, error) {
+// ErrCampaignExists is returned by CreateCampaign for a cid already in use.
+var ErrCampaignExists = errors.New("campaign already exists")
+
+// appPatternSQL turns a glob pattern held in column p into a LIKE pattern:
+// LIKE metacharacters are escaped, then '*' becomes '%' and '?' becomes '_'.
+const appPatternSQL = `replace(replace(replace(replace(replace(lower(p), '\', '\\'), '%', '\%'), '_', '\_'), '*', '%'), '?', '_')`
+
+func GetMatchingCampaigns(ctx context.Context, db *sql.DB, app, country, os string) ([]models.Campaign, error) {
+	return MatchCampaigns(ctx, db, nil, models.DeliveryRequest{App: app, Country: country, OS: os})
+}
+
+// MatchCampaigns returns the active campaigns matching req. Array rules and
+// tenant block lists are evaluated in SQL; geofences and audience segments
+// are checked in Go on the candidate rows, with segments read through segs
+// (nil loads them on every call).
+func MatchCampaigns(ctx context.Context, db *sql.DB, segs *segments.Cache, req models.DeliveryRequest) ([]models.Campaign, error) {
 	// Convert to lowercase for case-insensitive matching
-	app = strings.ToLower(app)
-	country = strings.ToLower(country)
-	os = strings.ToLower(os)
+	app := strings.ToLower(req.App)
+	country := strings.ToLower(req.Country)
+	os := strings.ToLower(req.OS)
+	region := strings.ToLower(req.Region)
+	city := strings.ToLower(req.City)
+	placement := strings.ToLower(req.PlacementID)
 
 	query := `
-	SELECT DISTINCT c.cid, c.name, c.img, c.cta, c.status
+	SELECT ` + campaignColumns + `, tr.include_geofence, tr.exclude_geofence,
+	       tr.include_segment, tr.exclude_segment
 	FROM campaigns c
 	JOIN targeting_rules tr ON c.cid = tr.cid
+	LEFT JOIN advertisers adv ON adv.id = c.advertiser_id
+	LEFT JOIN publisher_apps pa ON pa.app = $1
+	LEFT JOIN publishers pub ON pub.id = pa.publisher_id
 	WHERE c.status = 'ACTIVE'
 	  AND (
 		-- Check include rules
 		(tr.include_country IS NULL OR $2 = ANY(tr.include_country))
 		AND (tr.include_os IS NULL OR $3 = ANY(tr.include_os))
-		AND (tr.include_app IS NULL OR $1 = ANY(tr.include_app))
+		AND (tr.include_app IS NULL OR EXISTS (
+			SELECT 1 FROM unnest(tr.include_app) AS p WHERE $1 LIKE ` + appPatternSQL + `))
+		AND (tr.include_region IS NULL OR $4 = ANY(tr.include_region))
+		AND (tr.include_city IS NULL OR $5 = ANY(tr.include_city))
+		AND (tr.include_placement IS NULL OR $7 = ANY(tr.include_placement))
 		-- Check exclude rules
 		AND (tr.exclude_country IS NULL OR NOT ($2 = ANY(tr.exclude_country)))
 		AND (tr.exclude_os IS NULL OR NOT ($3 = ANY(tr.exclude_os)))
-		AND (tr.exclude_app IS NULL OR NOT ($1 = ANY(tr.exclude_app)))
+		AND (tr.exclude_app IS NULL OR NOT EXISTS (
+			SELECT 1 FROM unnest(tr.exclude_app) AS p WHERE $1 LIKE ` + appPatternSQL + `))
+		AND (tr.exclude_region IS NULL OR NOT ($4 = ANY(tr.exclude_region)))
+		AND (tr.exclude_city IS NULL OR NOT ($5 = ANY(tr.exclude_city)))
+		AND (tr.exclude_placement IS NULL OR NOT ($7 = ANY(tr.exclude_placement)))
+		AND (tr.include_segment IS NULL OR $6 <> '')
+		-- Check tenant block lists
+		AND (pub.blocked_advertisers IS NULL OR c.advertiser_id IS NULL
+			OR NOT (c.advertiser_id = ANY(pub.blocked_advertisers)))
+		AND (pub.blocked_categories IS NULL OR c.categories IS NULL OR NOT EXISTS (
+			SELECT 1 FROM unnest(c.categories) AS cat, unnest(pub.blocked_categories) AS b
+			WHERE lower(cat) = lower(b) OR lower(cat) LIKE lower(b) || '-%'))
+		AND (adv.blocked_apps IS NULL OR NOT EXISTS (
+			SELECT 1 FROM unnest(adv.blocked_apps) AS p WHERE $1 LIKE ` + appPatternSQL + `))
 	  )
 	ORDER BY c.cid
 	`
 
-	start := time.Now()
-	rows, err := db.Query(query, app, country, os)
+	rows, err := db.QueryContext(ctx, query, app, country, os, region, city, req.DeviceID, placement)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	metrics.ObserveDBQuery(time.Since(start).Seconds())
 
-	var campaigns []models.Campaign
+	type candidate struct {
+		campaign                       models.Campaign
+		includeFence, excludeFence     []byte
+		includeSegment, excludeSegment []string
+	}
 
+	var candidates []candidate
+	var segmentNames []string
 	for rows.Next() {
-		var c models.Campaign
-		if err := rows.Scan(&c.ID, &c.Name, &c.Img, &c.CTA, &c.Status); err != nil {
+		var c candidate
+		dest := append(campaignDest(&c.campaign), &c.includeFence, &c.excludeFence,
+			(*pq.StringArray)(&c.includeSegment), (*pq.StringArray)(&c.excludeSegment))
+		if err := rows.Scan(dest...); err != nil {
 			return nil, err
 		}
-		campaigns = append(campaigns, c)
+		candidates = append(candidates, c)
+		segmentNames = append(segmentNames, c.includeSegment...)
+		segmentNames = append(segmentNames, c.excludeSegment...)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
+	var audience map[string]*segments.Segment
+	if req.DeviceID != "" && len(segmentNames) > 0 {
+		if audience, err = segs.Get(ctx, db, segmentNames); err != nil {
+			return nil, err
+		}
+	}
+
+	var campaigns []models.Campaign
+	for _, c := range candidates {
+		// Rows are ordered by cid, so a campaign matched by an earlier rule
+		// is the last one appended
+		if n := len(campaigns); n > 0 && campaigns[n-1].ID == c.campaign.ID {
+			continue
+		}
+		ok, err := geofencesMatch(c.includeFence, c.excludeFence, req)
+		if err != nil {
+			return nil, err
+		}
+		if ok && segmentsMatch(c.includeSegment, c.excludeSegment, audience, req.DeviceID) {
+			campaigns = append(campaigns, c.campaign)
+		}
+	}
+
 	return campaigns, nil
 }
 
+// segmentsMatch applies a rule's segment lists: the device must be in one of
+// the include segments (if any) and in none of the exclude segments.
+func segmentsMatch(include, exclude []string, audience map[string]*segments.Segment, deviceID string) bool {
+	in := func(names []string) bool {
+		for _, name := range names {
+			if audience[name].Contains(deviceID) {
+				return true
+			}
+		}
+		return false
+	}
+	if include != nil && !in(include) {
+		return false
+	}
+	return !in(exclude)
+}
+
+// geofencesMatch applies a rule's JSONB geofence columns to req. A rule with
+// include fences needs a location inside one of them; exclude fences only
+// reject requests that carry a location.
+func geofencesMatch(includeJSON, excludeJSON []byte, req models.DeliveryRequest) (bool, error) {
+	include, err := decodeGeofences(includeJSON)
+	if err != nil {
+		return false, err
+	}
+	exclude, err := decodeGeofences(excludeJSON)
+	if err != nil {
+		return false, err
+	}
+
+	hasLocation := req.Lat != nil && req.Lon != nil
+	if include != nil && (!hasLocation || !targeting.InAnyGeofence(include, *req.Lat, *req.Lon)) {
+		return false, nil
+	}
+	if hasLocation && targeting.InAnyGeofence(exclude, *req.Lat, *req.Lon) {
+		return false, nil
+	}
+	return true, nil
+}
+
+func decodeGeofences(raw []byte) ([]models.Geofence, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var fences []models.Geofence
+	if err := json.Unmarshal(raw, &fences); err != nil {
+		return nil, err
+	}
+	return fences, nil
+}
+
+const campaignColumns = `c.cid, c.name, c.img, c.cta, c.status, COALESCE(c.advertiser_id, ''),
+	       c.categories, COALESCE(c.creative_rotation, ''), c.bid_cpm`
+
+// campaignDest returns the scan destinations for campaignColumns.
+func campaignDest(c *models.Campaign) []interface{} {
+	return []interface{}{&c.ID, &c.Name, &c.Img, &c.CTA, &c.Status,
+		&c.AdvertiserID, (*pq.StringArray)(&c.Categories), &c.CreativeRotation, &c.BidCPM}
+}
+
 // GetCampaignByID retrieves a single campaign by ID
 func GetCampaignByID(db *sql.DB, campaignID string) (*models.Campaign, error) {
-	query := `SELECT cid, name, img, cta, status FROM campaigns WHERE cid = $1`
+	return getCampaign(db, campaignID, "")
+}
+
+// LockCampaign loads a campaign inside tx and locks its row until tx ends.
+// It returns sql.ErrNoRows if the campaign does not exist.
+func LockCampaign(tx *sql.Tx, campaignID string) (*models.Campaign, error) {
+	return getCampaign(tx, campaignID, "FOR UPDATE")
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// getCampaign loads one campaign; suffix may add a locking clause such as
+// "FOR UPDATE".
+func getCampaign(q queryer, campaignID, suffix string) (*models.Campaign, error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns c WHERE c.cid = $1 ` + suffix
 
 	var c models.Campaign
-	err := db.QueryRow(query, campaignID).Scan(&c.ID, &c.Name, &c.Img, &c.CTA, &c.Status)
-	if err != nil {
+	if err := q.QueryRow(query, campaignID).Scan(campaignDest(&c)...); err != nil {
 		return nil, err
 	}
 
@@ -72,10 +225,141 @@ func GetCampaignByID(db *sql.DB, campaignID string) (*models.Campaign, error) {
 }
 
 // GetAllActiveCampaigns retrieves all active campaigns
-func GetAllActiveCampaigns(db *sql.DB) ([]models.Campaign, error) {
-	query := `SELECT cid, name, img, cta, status FROM campaigns WHERE status = 'ACTIVE' ORDER BY cid`
+func GetAllActiveCampaigns(ctx context.Context, db *sql.DB) ([]models.Campaign, error) {
+	return listCampaigns(ctx, db, `WHERE c.status = 'ACTIVE'`)
+}
+
+// ListCampaigns retrieves every campaign of an advertiser, whatever its
+// status. An empty advertiserID lists all campaigns.
+func ListCampaigns(ctx context.Context, db *sql.DB, advertiserID string) ([]models.Campaign, error) {
+	if advertiserID == "" {
+		return listCampaigns(ctx, db, "")
+	}
+	return listCampaigns(ctx, db, `WHERE c.advertiser_id = $1`, advertiserID)
+}
+
+// UpdateCampaignOwnership sets a campaign's advertiser and categories. It
+// returns sql.ErrNoRows if the campaign does not exist.
+func UpdateCampaignOwnership(db *sql.DB, campaignID, advertiserID string, categories []string, actor string) error {
+	return updateCampaign(db, campaignID, actor, "campaign.ownership",
+		`UPDATE campaigns SET advertiser_id = NULLIF($2, ''), categories = $3 WHERE cid = $1`,
+		advertiserID, textArray(categories))
+}
+
+// SetCampaignBid sets a campaign's auction bid. It returns sql.ErrNoRows if
+// the campaign does not exist.
+func SetCampaignBid(db *sql.DB, campaignID string, bidCPM float64, actor string) error {
+	return updateCampaign(db, campaignID, actor, "campaign.bid",
+		`UPDATE campaigns SET bid_cpm = $2 WHERE cid = $1`, bidCPM)
+}
+
+// SetCreativeRotation sets a campaign's creative rotation ("" or
+// creatives.RotationWeighted). It returns sql.ErrNoRows if the campaign
+// does not exist.
+func SetCreativeRotation(db *sql.DB, campaignID, rotation, actor string) error {
+	return updateCampaign(db, campaignID, actor, "campaign.creative_rotation",
+		`UPDATE campaigns SET creative_rotation = NULLIF($2, '') WHERE cid = $1`, rotation)
+}
+
+// CreateCampaign inserts a new campaign in c.Status, or DRAFT if it is
+// empty, and records it in the campaign's history. It returns
+// ErrCampaignExists if the cid is taken.
+func CreateCampaign(db *sql.DB, c models.Campaign, actor string) error {
+	query := `
+	INSERT INTO campaigns (cid, name, img, cta, status, advertiser_id, categories,
+		creative_rotation, bid_cpm)
+	VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'DRAFT'), NULLIF($6, ''), $7,
+		NULLIF($8, ''), $9)
+	ON CONFLICT (cid) DO NOTHING
+	`
+	_, err := versioned(db, c.ID, actor, "campaign.create", func(tx *sql.Tx) error {
+		res, err := tx.Exec(query, c.ID, c.Name, c.Img, c.CTA, c.Status, c.AdvertiserID,
+			textArray(c.Categories), c.CreativeRotation, c.BidCPM)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrCampaignExists
+		}
+		return nil
+	})
+	return err
+}
+
+// Field is an optional campaign column, which an import may leave out.
+type Field string
+
+const (
+	FieldAdvertiser       Field = "advertiser_id"
+	FieldCategories       Field = "categories"
+	FieldCreativeRotation Field = "creative_rotation"
+	FieldBidCPM           Field = "bid_cpm"
+)
+
+// UpsertCampaign creates c or updates it in place, then replaces its
+// targeting rules unless rules is nil, inside tx. Omitted fields keep their
+// current value on update and take the column default on insert. A new
+// campaign takes c.Status, or DRAFT if it is empty; an existing one keeps
+// its status, which only lifecycle actions change. Callers record the
+// change with RecordChange, and a new campaign's status with
+// RecordImportedStatus.
+func UpsertCampaign(tx *sql.Tx, c models.Campaign, omitted []Field, rules []models.TargetingRule) error {
+	// NULL stands for an omitted field; an empty value clears it
+	query := `
+	INSERT INTO campaigns (cid, name, img, cta, status, advertiser_id, categories,
+		creative_rotation, bid_cpm)
+	VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'DRAFT'), NULLIF($6::text, ''),
+		NULLIF($7::text[], '{}'), NULLIF($8::text, ''), COALESCE($9::numeric, 0))
+	ON CONFLICT (cid) DO UPDATE SET
+		name = EXCLUDED.name,
+		img = EXCLUDED.img,
+		cta = EXCLUDED.cta,
+		advertiser_id = NULLIF(COALESCE($6::text, campaigns.advertiser_id), ''),
+		categories = NULLIF(COALESCE($7::text[], campaigns.categories), '{}'),
+		creative_rotation = NULLIF(COALESCE($8::text, campaigns.creative_rotation), ''),
+		bid_cpm = COALESCE($9::numeric, campaigns.bid_cpm)
+	`
+	categories := pq.StringArray(c.Categories)
+	if categories == nil {
+		categories = pq.StringArray{}
+	}
+	omit := func(f Field, v interface{}) interface{} {
+		if slices.Contains(omitted, f) {
+			return nil
+		}
+		return v
+	}
+	_, err := tx.Exec(query, c.ID, c.Name, c.Img, c.CTA, c.Status,
+		omit(FieldAdvertiser, c.AdvertiserID),
+		omit(FieldCategories, categories),
+		omit(FieldCreativeRotation, c.CreativeRotation),
+		omit(FieldBidCPM, c.BidCPM))
+	if err != nil || rules == nil {
+		return err
+	}
+	return replaceTargetingRules(tx, c.ID, rules)
+}
+
+// updateCampaign runs an UPDATE of one campaign ($1 is its cid) and records
+// it in the campaign's history.
+func updateCampaign(db *sql.DB, campaignID, actor, change, query string, args ...interface{}) error {
+	_, err := versioned(db, campaignID, actor, change, func(tx *sql.Tx) error {
+		res, err := tx.Exec(query, append([]interface{}{campaignID}, args...)...)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	return err
+}
+
+func listCampaigns(ctx context.Context, db *sql.DB, where string, args ...interface{}) ([]models.Campaign, error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns c ` + where + ` ORDER BY c.cid`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +368,7 @@ func GetAllActiveCampaigns(db *sql.DB) ([]models.Campaign, error) {
 	var campaigns []models.Campaign
 	for rows.Next() {
 		var c models.Campaign
-		if err := rows.Scan(&c.ID, &c.Name, &c.Img, &c.CTA, &c.Status); err != nil {
+		if err := rows.Scan(campaignDest(&c)...); err != nil {
 			return nil, err
 		}
 		campaigns = append(campaigns, c)
@@ -0,0 +1,192 @@
+package campaigns
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/history"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// ErrNoRulesInVersion is returned by RestoreRules for a version recording
+// the campaign's deletion.
+var ErrNoRulesInVersion = errors.New("version has no rules to restore")
+
+// RecordChange runs apply inside tx and appends a version of the campaign
+// to its history, with actor and a before/after diff. The campaign row is
+// locked first so its versions are numbered in order. Nothing is appended
+// when apply changed nothing, and the returned version is nil. It returns
+// sql.ErrNoRows if the campaign exists neither before nor after apply.
+func RecordChange(tx *sql.Tx, campaignID, actor, change string, apply func() error) (*history.Version, error) {
+	before, err := loadDocument(tx, campaignID, "FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(); err != nil {
+		return nil, err
+	}
+	after, err := loadDocument(tx, campaignID, "")
+	if err != nil {
+		return nil, err
+	}
+	if before == nil && after == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	diff, err := history.Diff(before, after)
+	if err != nil || len(diff) == 0 {
+		return nil, err
+	}
+	v := history.Version{CampaignID: campaignID, Actor: actor, Change: change, Diff: diff, Before: before, After: after}
+
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return nil, err
+	}
+	beforeJSON, err := encodeDocument(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := encodeDocument(after)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	INSERT INTO campaign_versions (cid, version, actor, change, diff, before, after)
+	SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6
+	FROM campaign_versions WHERE cid = $1
+	RETURNING version, created_at
+	`
+	if err := tx.QueryRow(query, campaignID, actor, change, diffJSON, beforeJSON, afterJSON).Scan(&v.Version, &v.At); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// versioned runs apply in a new transaction through RecordChange.
+func versioned(db *sql.DB, campaignID, actor, change string, apply func(tx *sql.Tx) error) (*history.Version, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	v, err := RecordChange(tx, campaignID, actor, change, func() error { return apply(tx) })
+	if err != nil {
+		return nil, err
+	}
+	return v, tx.Commit()
+}
+
+// ListVersions returns a campaign's history, newest first, without the
+// before/after documents.
+func ListVersions(db *sql.DB, campaignID string) ([]history.Version, error) {
+	query := `
+	SELECT cid, version, actor, change, diff, created_at
+	FROM campaign_versions
+	WHERE cid = $1
+	ORDER BY version DESC
+	`
+	rows, err := db.Query(query, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []history.Version
+	for rows.Next() {
+		var v history.Version
+		var diff []byte
+		if err := rows.Scan(&v.CampaignID, &v.Version, &v.Actor, &v.Change, &diff, &v.At); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(diff, &v.Diff); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetVersion returns one version of a campaign with its before/after
+// documents. It returns sql.ErrNoRows if it does not exist.
+func GetVersion(db *sql.DB, campaignID string, version int) (*history.Version, error) {
+	query := `
+	SELECT cid, version, actor, change, diff, before, after, created_at
+	FROM campaign_versions
+	WHERE cid = $1 AND version = $2
+	`
+	var v history.Version
+	var diff, before, after []byte
+	err := db.QueryRow(query, campaignID, version).Scan(&v.CampaignID, &v.Version, &v.Actor, &v.Change, &diff, &before, &after, &v.At)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(diff, &v.Diff); err != nil {
+		return nil, err
+	}
+	if v.Before, err = decodeDocument(before); err != nil {
+		return nil, err
+	}
+	if v.After, err = decodeDocument(after); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// RestoreRules atomically replaces a campaign's targeting rules with those
+// it had after the given version, recording the restore as a new version.
+// It returns sql.ErrNoRows if the version does not exist.
+func RestoreRules(db *sql.DB, campaignID string, version int, actor string) (*history.Version, error) {
+	old, err := GetVersion(db, campaignID, version)
+	if err != nil {
+		return nil, err
+	}
+	if old.After == nil {
+		return nil, ErrNoRulesInVersion
+	}
+	change := fmt.Sprintf("rules.restore:v%d", version)
+	return versioned(db, campaignID, actor, change, func(tx *sql.Tx) error {
+		return replaceTargetingRules(tx, campaignID, old.After.Rules)
+	})
+}
+
+// loadDocument returns the versioned state of a campaign, or nil if it does
+// not exist.
+func loadDocument(q queryer, campaignID, suffix string) (*history.Document, error) {
+	c, err := getCampaign(q, campaignID, suffix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules, err := getTargetingRules(q, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.TargetingRule{}
+	}
+	return &history.Document{Campaign: models.NewCampaignRecord(*c), Rules: rules}, nil
+}
+
+func encodeDocument(doc *history.Document) (interface{}, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	return json.Marshal(doc)
+}
+
+func decodeDocument(raw []byte) (*history.Document, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var doc history.Document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
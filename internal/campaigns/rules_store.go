@@ -0,0 +1,170 @@
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+const ruleColumns = `tr.cid, tr.include_country, tr.exclude_country, tr.include_os,
+	       tr.exclude_os, tr.include_app, tr.exclude_app, tr.include_region,
+	       tr.exclude_region, tr.include_city, tr.exclude_city,
+	       tr.include_geofence, tr.exclude_geofence, tr.include_segment,
+	       tr.exclude_segment, tr.include_placement, tr.exclude_placement`
+
+// GetActiveTargetingRules retrieves the targeting rules of all active campaigns
+func GetActiveTargetingRules(ctx context.Context, db *sql.DB) ([]models.TargetingRule, error) {
+	query := `
+	SELECT ` + ruleColumns + `
+	FROM targeting_rules tr
+	JOIN campaigns c ON c.cid = tr.cid
+	WHERE c.status = 'ACTIVE'
+	ORDER BY tr.cid, tr.id
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRules(rows)
+}
+
+// ListTargetingRules retrieves the targeting rules of every campaign,
+// whatever its status, ordered by campaign.
+func ListTargetingRules(ctx context.Context, db *sql.DB) ([]models.TargetingRule, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+ruleColumns+` FROM targeting_rules tr ORDER BY tr.cid, tr.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRules(rows)
+}
+
+// GetTargetingRules retrieves the targeting rules of one campaign
+func GetTargetingRules(db *sql.DB, campaignID string) ([]models.TargetingRule, error) {
+	return getTargetingRules(db, campaignID)
+}
+
+func getTargetingRules(q queryer, campaignID string) ([]models.TargetingRule, error) {
+	query := `SELECT ` + ruleColumns + ` FROM targeting_rules tr WHERE tr.cid = $1 ORDER BY tr.id`
+
+	rows, err := q.Query(query, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRules(rows)
+}
+
+// ReplaceTargetingRules atomically replaces all rules of a campaign and
+// records the change in its history. Rules should already be normalised
+// with targeting.NormalizeRule.
+func ReplaceTargetingRules(db *sql.DB, campaignID string, rules []models.TargetingRule, actor string) error {
+	_, err := versioned(db, campaignID, actor, "rules.replace", func(tx *sql.Tx) error {
+		return replaceTargetingRules(tx, campaignID, rules)
+	})
+	return err
+}
+
+func replaceTargetingRules(tx *sql.Tx, campaignID string, rules []models.TargetingRule) error {
+	if _, err := tx.Exec(`DELETE FROM targeting_rules WHERE cid = $1`, campaignID); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO targeting_rules (cid, include_country, exclude_country, include_os,
+		exclude_os, include_app, exclude_app, include_region, exclude_region,
+		include_city, exclude_city, include_geofence, exclude_geofence,
+		include_segment, exclude_segment, include_placement, exclude_placement)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+	`
+	for _, r := range rules {
+		includeFence, err := encodeGeofences(r.IncludeGeofence)
+		if err != nil {
+			return err
+		}
+		excludeFence, err := encodeGeofences(r.ExcludeGeofence)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, campaignID,
+			textArray(r.IncludeCountry), textArray(r.ExcludeCountry),
+			textArray(r.IncludeOS), textArray(r.ExcludeOS),
+			textArray(r.IncludeApp), textArray(r.ExcludeApp),
+			textArray(r.IncludeRegion), textArray(r.ExcludeRegion),
+			textArray(r.IncludeCity), textArray(r.ExcludeCity),
+			includeFence, excludeFence,
+			textArray(r.IncludeSegment), textArray(r.ExcludeSegment),
+			textArray(r.IncludePlacement), textArray(r.ExcludePlacement),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// textArray keeps nil slices as SQL NULL ("no constraint") rather than '{}'.
+func textArray(values []string) interface{} {
+	if values == nil {
+		return nil
+	}
+	return pq.StringArray(values)
+}
+
+func encodeGeofences(fences []models.Geofence) (interface{}, error) {
+	if fences == nil {
+		return nil, nil
+	}
+	return json.Marshal(fences)
+}
+
+func scanRules(rows *sql.Rows) ([]models.TargetingRule, error) {
+	var rules []models.TargetingRule
+	for rows.Next() {
+		var r models.TargetingRule
+		var includeFence, excludeFence []byte
+		err := rows.Scan(
+			&r.CampaignID,
+			(*pq.StringArray)(&r.IncludeCountry),
+			(*pq.StringArray)(&r.ExcludeCountry),
+			(*pq.StringArray)(&r.IncludeOS),
+			(*pq.StringArray)(&r.ExcludeOS),
+			(*pq.StringArray)(&r.IncludeApp),
+			(*pq.StringArray)(&r.ExcludeApp),
+			(*pq.StringArray)(&r.IncludeRegion),
+			(*pq.StringArray)(&r.ExcludeRegion),
+			(*pq.StringArray)(&r.IncludeCity),
+			(*pq.StringArray)(&r.ExcludeCity),
+			&includeFence,
+			&excludeFence,
+			(*pq.StringArray)(&r.IncludeSegment),
+			(*pq.StringArray)(&r.ExcludeSegment),
+			(*pq.StringArray)(&r.IncludePlacement),
+			(*pq.StringArray)(&r.ExcludePlacement),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if r.IncludeGeofence, err = decodeGeofences(includeFence); err != nil {
+			return nil, err
+		}
+		if r.ExcludeGeofence, err = decodeGeofences(excludeFence); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
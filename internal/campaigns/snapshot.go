@@ -0,0 +1,195 @@
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// Snapshot is an immutable, in-memory view of the active campaign catalogue.
+type Snapshot struct {
+	Matcher *targeting.Matcher
+	// Creatives holds the creatives of each campaign
+	Creatives map[string][]models.Creative
+	// Placements indexes the placement registry by ID
+	Placements placements.Index
+	// AppFloors holds the auction floor of apps that have one
+	AppFloors map[string]float64
+	LoadedAt  time.Time
+}
+
+// SnapshotStore keeps the most recently loaded Snapshot and swaps it
+// atomically on refresh, so readers never block on the database. With a
+// persist path it also writes every loaded catalogue to disk, so a server
+// restarting while the database is down can serve the last one.
+type SnapshotStore struct {
+	db          *sql.DB
+	metrics     *metrics.Registry
+	segments    *segments.Cache
+	persistPath string
+	current     atomic.Pointer[Snapshot]
+	// stale is set while refreshes fail or the snapshot came from disk
+	stale atomic.Bool
+}
+
+// SnapshotOption configures a SnapshotStore.
+type SnapshotOption func(*SnapshotStore)
+
+// WithPersistPath saves each loaded catalogue to path and lets LoadFile
+// restore it.
+func WithPersistPath(path string) SnapshotOption {
+	return func(s *SnapshotStore) {
+		s.persistPath = path
+	}
+}
+
+// WithSegmentCache reads audience segments through c, e.g. one shared with
+// per-request matching, instead of a cache of the store's own.
+func WithSegmentCache(c *segments.Cache) SnapshotOption {
+	return func(s *SnapshotStore) {
+		s.segments = c
+	}
+}
+
+// NewSnapshotStore creates a store backed by db that records its refreshes
+// and database queries in reg. Call Refresh or Run before serving; Current
+// returns nil until the first successful load.
+func NewSnapshotStore(db *sql.DB, reg *metrics.Registry, opts ...SnapshotOption) *SnapshotStore {
+	s := &SnapshotStore{db: db, metrics: reg, segments: segments.NewCache(reg)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Current returns the latest snapshot, or nil if none has been loaded.
+func (s *SnapshotStore) Current() *Snapshot {
+	return s.current.Load()
+}
+
+// Stale reports whether the current snapshot may be out of date: the last
+// refresh failed, or it was restored from disk and has not been refreshed
+// since.
+func (s *SnapshotStore) Stale() bool {
+	return s.stale.Load()
+}
+
+// Set replaces the current snapshot, e.g. with one built from a fixture
+// rather than the database.
+func (s *SnapshotStore) Set(snap *Snapshot) {
+	s.current.Store(snap)
+	s.stale.Store(false)
+	s.observeSnapshot(snap, false)
+}
+
+// Refresh loads the active catalogue from the database and replaces the
+// current snapshot. On error, including ctx ending, the previous snapshot
+// is kept and marked stale.
+func (s *SnapshotStore) Refresh(ctx context.Context) error {
+	start := time.Now()
+	cat, err := s.loadCatalogue(ctx)
+	s.metrics.ObserveSnapshotRefresh(time.Since(start).Seconds(), err)
+	if err != nil {
+		s.stale.Store(true)
+		s.metrics.SetSnapshotStale(true)
+		return err
+	}
+	s.Set(cat.build())
+	if s.persistPath != "" {
+		if err := cat.save(s.persistPath); err != nil {
+			slog.Error("failed to persist campaign snapshot", "path", s.persistPath, logging.Err(err))
+		}
+	}
+	return nil
+}
+
+// LoadFile restores the snapshot last persisted to disk and marks it stale
+// until the next successful Refresh.
+func (s *SnapshotStore) LoadFile() error {
+	if s.persistPath == "" {
+		return errors.New("no snapshot persist path configured")
+	}
+	cat, err := readCatalogue(s.persistPath)
+	if err != nil {
+		return err
+	}
+	snap := cat.build()
+	s.current.Store(snap)
+	s.stale.Store(true)
+	s.observeSnapshot(snap, true)
+	return nil
+}
+
+// observeSnapshot publishes the metrics describing the snapshot now served.
+func (s *SnapshotStore) observeSnapshot(snap *Snapshot, stale bool) {
+	var n int
+	if snap.Matcher != nil {
+		n = snap.Matcher.Len()
+	}
+	s.metrics.SetSnapshot(snap.LoadedAt, n, stale)
+}
+
+// loadCatalogue reads everything a snapshot is built from. The queries of
+// rules, creatives and segments, the large ones, are timed; the creative and
+// segment stores time their own.
+func (s *SnapshotStore) loadCatalogue(ctx context.Context) (*catalogue, error) {
+	var cat catalogue
+	var err error
+	if cat.Campaigns, err = GetAllActiveCampaigns(ctx, s.db); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	cat.Rules, err = GetActiveTargetingRules(ctx, s.db)
+	s.metrics.ObserveDBQuery(time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+	if cat.Advertisers, err = GetAdvertisers(ctx, s.db); err != nil {
+		return nil, err
+	}
+	if cat.Publishers, err = GetPublishers(ctx, s.db); err != nil {
+		return nil, err
+	}
+	if cat.Creatives, err = creatives.ListByCampaign(ctx, s.db, s.metrics, nil); err != nil {
+		return nil, err
+	}
+	if cat.Placements, err = placements.List(ctx, s.db); err != nil {
+		return nil, err
+	}
+	if cat.AppFloors, err = placements.ListAppFloors(ctx, s.db); err != nil {
+		return nil, err
+	}
+	if cat.Segments, err = s.segments.Get(ctx, s.db, nil); err != nil {
+		return nil, err
+	}
+	cat.LoadedAt = time.Now()
+	return &cat, nil
+}
+
+// Run refreshes the snapshot every interval until ctx is cancelled.
+func (s *SnapshotStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				slog.Error("failed to refresh campaign snapshot", logging.Err(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package campaigns
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// catalogueFormat versions the persisted snapshot file. Files written in
+// another format are rejected rather than misread.
+const catalogueFormat = 1
+
+// catalogue is everything a Snapshot is built from, in a form that can be
+// written to disk.
+type catalogue struct {
+	Campaigns   []models.Campaign
+	Rules       []models.TargetingRule
+	Advertisers []models.Advertiser
+	Publishers  []models.Publisher
+	Creatives   map[string][]models.Creative
+	Placements  []models.Placement
+	AppFloors   map[string]float64
+	Segments    map[string]*segments.Segment
+	LoadedAt    time.Time
+}
+
+// build indexes the catalogue into a Snapshot.
+func (c *catalogue) build() *Snapshot {
+	sets := make(map[string]targeting.SegmentSet, len(c.Segments))
+	for name, seg := range c.Segments {
+		sets[name] = seg
+	}
+	matcher := targeting.NewMatcher(c.Campaigns, c.Rules,
+		targeting.WithSegments(sets),
+		targeting.WithTenants(c.Advertisers, c.Publishers))
+
+	return &Snapshot{
+		Matcher:    matcher,
+		Creatives:  c.Creatives,
+		Placements: placements.NewIndex(c.Placements),
+		AppFloors:  c.AppFloors,
+		LoadedAt:   c.LoadedAt,
+	}
+}
+
+// catalogueFile is the on-disk form of a catalogue. Segment members are
+// stored in their database encoding.
+type catalogueFile struct {
+	Format      int                          `json:"format"`
+	LoadedAt    time.Time                    `json:"loaded_at"`
+	Campaigns   []models.CampaignRecord      `json:"campaigns"`
+	Rules       []models.TargetingRule       `json:"rules"`
+	Advertisers []models.Advertiser          `json:"advertisers"`
+	Publishers  []models.Publisher           `json:"publishers"`
+	Creatives   map[string][]models.Creative `json:"creatives"`
+	Placements  []models.Placement           `json:"placements"`
+	AppFloors   map[string]float64           `json:"app_floors"`
+	Segments    []segmentFile                `json:"segments"`
+}
+
+type segmentFile struct {
+	segments.Segment
+	Members []byte `json:"members"`
+}
+
+// save writes the catalogue to path atomically: readers see either the old
+// file or the complete new one.
+func (c *catalogue) save(path string) error {
+	f := catalogueFile{
+		Format:      catalogueFormat,
+		LoadedAt:    c.LoadedAt,
+		Campaigns:   models.NewCampaignRecords(c.Campaigns),
+		Rules:       c.Rules,
+		Advertisers: c.Advertisers,
+		Publishers:  c.Publishers,
+		Creatives:   c.Creatives,
+		Placements:  c.Placements,
+		AppFloors:   c.AppFloors,
+	}
+	for _, seg := range c.Segments {
+		f.Segments = append(f.Segments, segmentFile{Segment: *seg, Members: seg.Members()})
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(f); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readCatalogue reads a catalogue written by save.
+func readCatalogue(path string) (*catalogue, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f catalogueFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if f.Format != catalogueFormat {
+		return nil, fmt.Errorf("%s: unsupported snapshot format %d", path, f.Format)
+	}
+
+	cat := &catalogue{
+		Campaigns:   make([]models.Campaign, len(f.Campaigns)),
+		Rules:       f.Rules,
+		Advertisers: f.Advertisers,
+		Publishers:  f.Publishers,
+		Creatives:   f.Creatives,
+		Placements:  f.Placements,
+		AppFloors:   f.AppFloors,
+		Segments:    make(map[string]*segments.Segment, len(f.Segments)),
+		LoadedAt:    f.LoadedAt,
+	}
+	for i, rec := range f.Campaigns {
+		cat.Campaigns[i] = rec.Model()
+	}
+	for _, sf := range f.Segments {
+		seg := sf.Segment
+		if err := seg.SetMembers(sf.Members); err != nil {
+			return nil, fmt.Errorf("%s: segment %s: %w", path, seg.Name, err)
+		}
+		cat.Segments[seg.Name] = &seg
+	}
+	return cat, nil
+}
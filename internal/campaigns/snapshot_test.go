@@ -0,0 +1,101 @@
+package campaigns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+)
+
+func TestCatalogueFileRoundTrip(t *testing.T) {
+	buyers, err := segments.Build("buyers", []string{"device-1"}, segments.Options{Kind: segments.KindBloom})
+	require.NoError(t, err)
+
+	loadedAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	cat := &catalogue{
+		Campaigns: []models.Campaign{
+			{ID: "spotify", Name: "Spotify", Status: "ACTIVE", BidCPM: 2.5},
+			{ID: "duolingo", Name: "Duolingo", Status: "ACTIVE"},
+		},
+		Rules: []models.TargetingRule{
+			{CampaignID: "spotify", IncludeCountry: []string{"us"}},
+			{CampaignID: "duolingo", IncludeSegment: []string{"buyers"}},
+		},
+		Creatives: map[string][]models.Creative{
+			"spotify": {{ID: "spotify-banner", CampaignID: "spotify", Format: "banner", Status: "ACTIVE", Weight: 1,
+				Assets: map[string]string{"img": "https://img", "cta": "Listen"}}},
+		},
+		Placements: []models.Placement{{ID: "home", App: "com.test", Formats: []string{"banner"}, FloorCPM: 0.5}},
+		AppFloors:  map[string]float64{"com.test": 0.2},
+		Segments:   map[string]*segments.Segment{"buyers": buyers},
+		LoadedAt:   loadedAt,
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	require.NoError(t, cat.save(path))
+
+	reg := metrics.New()
+	store := NewSnapshotStore(nil, reg, WithPersistPath(path))
+	require.NoError(t, store.LoadFile())
+	assert.True(t, store.Stale(), "restored snapshot is stale until refreshed")
+	assert.Equal(t, 1.0, testutil.ToFloat64(reg.SnapshotStale))
+	assert.Equal(t, 2.0, testutil.ToFloat64(reg.SnapshotCampaigns))
+	assert.Equal(t, float64(loadedAt.Unix()), testutil.ToFloat64(reg.SnapshotLoadedAt))
+
+	snap := store.Current()
+	require.NotNil(t, snap)
+	assert.True(t, snap.LoadedAt.Equal(loadedAt))
+	assert.Equal(t, 0.2, snap.AppFloors["com.test"])
+	assert.Equal(t, "Listen", snap.Creatives["spotify"][0].Assets["cta"])
+	require.Contains(t, snap.Placements, "home")
+	assert.Equal(t, 0.5, snap.Placements["home"].FloorCPM)
+
+	match := func(req models.DeliveryRequest) []string {
+		var ids []string
+		for _, c := range snap.Matcher.Match(req) {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"spotify"}, match(models.DeliveryRequest{App: "com.test", Country: "us", OS: "android"}))
+	assert.Equal(t, []string{"duolingo"}, match(models.DeliveryRequest{App: "com.test", Country: "de", OS: "android", DeviceID: "device-1"}),
+		"segment members survive the round trip")
+
+	store.Set(snap)
+	assert.False(t, store.Stale())
+	assert.Zero(t, testutil.ToFloat64(reg.SnapshotStale))
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	wrongFormat := filepath.Join(dir, "v0.json")
+	require.NoError(t, os.WriteFile(wrongFormat, []byte(`{"format":0}`), 0o600))
+
+	tests := []struct {
+		name   string
+		path   string
+		errMsg string
+	}{
+		{"no path", "", "no snapshot persist path"},
+		{"missing file", filepath.Join(dir, "missing.json"), "no such file"},
+		{"unknown format", wrongFormat, "unsupported snapshot format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewSnapshotStore(nil, nil, WithPersistPath(tt.path))
+			err := store.LoadFile()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Nil(t, store.Current())
+		})
+	}
+}
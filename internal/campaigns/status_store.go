@@ -0,0 +1,87 @@
+package campaigns
+
+import (
+	"database/sql"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/lifecycle"
+)
+
+// TransitionCampaign applies a lifecycle action to a campaign and records it
+// with actor and reason, atomically, in both its status history and its
+// version history. It returns sql.ErrNoRows if the campaign does not exist
+// and lifecycle.ErrInvalidTransition if the action is not allowed from its
+// current status.
+func TransitionCampaign(db *sql.DB, campaignID string, action lifecycle.Action, actor, reason string) (*lifecycle.Transition, error) {
+	t := lifecycle.Transition{CampaignID: campaignID, Action: action, Actor: actor, Reason: reason}
+	_, err := versioned(db, campaignID, actor, "status."+string(action), func(tx *sql.Tx) error {
+		if err := tx.QueryRow(`SELECT status FROM campaigns WHERE cid = $1`, campaignID).Scan(&t.From); err != nil {
+			return err
+		}
+		var err error
+		if t.To, err = lifecycle.Next(t.From, action); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE campaigns SET status = $2 WHERE cid = $1`, campaignID, t.To); err != nil {
+			return err
+		}
+		return insertTransition(tx, &t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RecordImportedStatus records in a new campaign's status history, inside
+// tx, that an import created it in status instead of DRAFT. Nothing is
+// recorded for DRAFT, where every campaign starts.
+func RecordImportedStatus(tx *sql.Tx, campaignID, status, actor string) error {
+	if status == "" || status == lifecycle.StatusDraft {
+		return nil
+	}
+	return insertTransition(tx, &lifecycle.Transition{
+		CampaignID: campaignID,
+		From:       lifecycle.StatusDraft,
+		To:         status,
+		Action:     lifecycle.ActionImport,
+		Actor:      actor,
+		Reason:     "created by bulk import",
+	})
+}
+
+// insertTransition appends t to its campaign's status history and sets
+// t.At.
+func insertTransition(tx *sql.Tx, t *lifecycle.Transition) error {
+	query := `
+	INSERT INTO campaign_status_history (cid, from_status, to_status, action, actor, reason)
+	VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	RETURNING created_at
+	`
+	return tx.QueryRow(query, t.CampaignID, t.From, t.To, string(t.Action), t.Actor, t.Reason).Scan(&t.At)
+}
+
+// GetStatusHistory returns a campaign's status transitions, oldest first.
+func GetStatusHistory(db *sql.DB, campaignID string) ([]lifecycle.Transition, error) {
+	query := `
+	SELECT cid, from_status, to_status, action, actor, COALESCE(reason, ''), created_at
+	FROM campaign_status_history
+	WHERE cid = $1
+	ORDER BY id
+	`
+	rows, err := db.Query(query, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []lifecycle.Transition
+	for rows.Next() {
+		var t lifecycle.Transition
+		if err := rows.Scan(&t.CampaignID, &t.From, &t.To, &t.Action, &t.Actor, &t.Reason, &t.At); err != nil {
+			return nil, err
+		}
+		history = append(history, t)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,133 @@
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// ErrAppClaimed is returned by SavePublisher when an app already belongs to
+// another publisher.
+var ErrAppClaimed = errors.New("app already belongs to another publisher")
+
+// GetAdvertisers retrieves every advertiser
+func GetAdvertisers(ctx context.Context, db *sql.DB) ([]models.Advertiser, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, name, blocked_apps FROM advertisers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var advertisers []models.Advertiser
+	for rows.Next() {
+		var a models.Advertiser
+		if err := rows.Scan(&a.ID, &a.Name, (*pq.StringArray)(&a.BlockedApps)); err != nil {
+			return nil, err
+		}
+		advertisers = append(advertisers, a)
+	}
+	return advertisers, rows.Err()
+}
+
+// GetAdvertiser retrieves a single advertiser by ID
+func GetAdvertiser(db *sql.DB, id string) (*models.Advertiser, error) {
+	var a models.Advertiser
+	err := db.QueryRow(`SELECT id, name, blocked_apps FROM advertisers WHERE id = $1`, id).
+		Scan(&a.ID, &a.Name, (*pq.StringArray)(&a.BlockedApps))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SaveAdvertiser creates or updates an advertiser. Blocked apps should
+// already be normalised with targeting.NormalizeApp.
+func SaveAdvertiser(db *sql.DB, a models.Advertiser) error {
+	query := `
+	INSERT INTO advertisers (id, name, blocked_apps) VALUES ($1, $2, $3)
+	ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, blocked_apps = EXCLUDED.blocked_apps
+	`
+	_, err := db.Exec(query, a.ID, a.Name, textArray(a.BlockedApps))
+	return err
+}
+
+const publisherQuery = `
+	SELECT p.id, p.name, p.blocked_advertisers, p.blocked_categories,
+	       ARRAY(SELECT pa.app FROM publisher_apps pa WHERE pa.publisher_id = p.id ORDER BY pa.app)
+	FROM publishers p
+	`
+
+// GetPublishers retrieves every publisher with its apps
+func GetPublishers(ctx context.Context, db *sql.DB) ([]models.Publisher, error) {
+	rows, err := db.QueryContext(ctx, publisherQuery+` ORDER BY p.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var publishers []models.Publisher
+	for rows.Next() {
+		p, err := scanPublisher(rows)
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, *p)
+	}
+	return publishers, rows.Err()
+}
+
+// GetPublisher retrieves a single publisher with its apps
+func GetPublisher(db *sql.DB, id string) (*models.Publisher, error) {
+	return scanPublisher(db.QueryRow(publisherQuery+` WHERE p.id = $1`, id))
+}
+
+func scanPublisher(row interface{ Scan(...interface{}) error }) (*models.Publisher, error) {
+	var p models.Publisher
+	err := row.Scan(&p.ID, &p.Name,
+		(*pq.StringArray)(&p.BlockedAdvertisers),
+		(*pq.StringArray)(&p.BlockedCategories),
+		(*pq.StringArray)(&p.Apps))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SavePublisher creates or updates a publisher and replaces the set of apps
+// it owns. Apps should already be normalised with targeting.NormalizeApp.
+func SavePublisher(db *sql.DB, p models.Publisher) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO publishers (id, name, blocked_advertisers, blocked_categories) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name,
+		blocked_advertisers = EXCLUDED.blocked_advertisers,
+		blocked_categories = EXCLUDED.blocked_categories
+	`
+	if _, err := tx.Exec(query, p.ID, p.Name, textArray(p.BlockedAdvertisers), textArray(p.BlockedCategories)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM publisher_apps WHERE publisher_id = $1`, p.ID); err != nil {
+		return err
+	}
+	for _, app := range p.Apps {
+		_, err := tx.Exec(`INSERT INTO publisher_apps (app, publisher_id) VALUES ($1, $2)`, app, p.ID)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("%w: %s", ErrAppClaimed, app)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,359 @@
+// Package config loads the server configuration. Values come from, in
+// increasing order of precedence: built-in defaults, a YAML file, environment
+// variables and command-line flags.
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/geo"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+)
+
+// Config is the complete server configuration. Each leaf field has a YAML
+// key (its path in the file), an environment variable and a flag named after
+// the YAML path, e.g. db.max_open_conns, DB_MAX_OPEN_CONNS and
+// -db.max_open_conns.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	DB        DB        `yaml:"db"`
+	Snapshot  Snapshot  `yaml:"snapshot"`
+	Auth      Auth      `yaml:"auth"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Auction   Auction   `yaml:"auction"`
+	Tracking  Tracking  `yaml:"tracking"`
+	Geo       Geo       `yaml:"geo"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Log       Log       `yaml:"log"`
+	Metrics   Metrics   `yaml:"metrics"`
+	Capture   Capture   `yaml:"capture"`
+	// MigrateOnStart applies pending schema migrations before serving.
+	MigrateOnStart bool `yaml:"migrate_on_start" env:"MIGRATE_ON_START" desc:"apply pending schema migrations before serving"`
+}
+
+// Server holds the HTTP server settings.
+type Server struct {
+	Addr            string   `yaml:"addr" env:"HTTP_ADDR" desc:"address to listen on"`
+	ReadTimeout     Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" desc:"maximum time to read a request"`
+	WriteTimeout    Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" desc:"maximum time to write a response"`
+	IdleTimeout     Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" desc:"keep-alive idle timeout"`
+	RequestTimeout  Duration `yaml:"request_timeout" env:"HTTP_REQUEST_TIMEOUT" desc:"deadline for handling a request"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" desc:"time allowed for graceful shutdown"`
+	// DrainDelay is how long /readyz fails before shutdown starts, so load
+	// balancers stop sending traffic first.
+	DrainDelay         Duration `yaml:"drain_delay" env:"HTTP_DRAIN_DELAY" desc:"how long readiness fails before shutdown starts (0: none)"`
+	HealthCheckTimeout Duration `yaml:"health_check_timeout" env:"HTTP_HEALTH_CHECK_TIMEOUT" desc:"deadline for each readiness check"`
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For/X-Real-IP,
+	// which clients can forge unless a proxy in front overwrites them.
+	TrustProxyHeaders bool `yaml:"trust_proxy_headers" env:"HTTP_TRUST_PROXY_HEADERS" desc:"take the client IP from X-Forwarded-For/X-Real-IP; only behind a proxy that sets them"`
+}
+
+// DB holds the PostgreSQL connection and pool settings.
+type DB struct {
+	Host     string `yaml:"host" env:"DB_HOST" desc:"database host"`
+	Port     int    `yaml:"port" env:"DB_PORT" desc:"database port"`
+	Name     string `yaml:"name" env:"DB_NAME" desc:"database name"`
+	User     string `yaml:"user" env:"DB_USER" desc:"database user"`
+	Password Secret `yaml:"password" env:"DB_PASSWORD" desc:"database password"`
+	SSLMode  string `yaml:"ssl_mode" env:"DB_SSL_MODE" desc:"disable, require, verify-ca or verify-full"`
+	// Pool settings; zero means unlimited, as in database/sql.
+	MaxOpenConns    int      `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" desc:"maximum open connections (0: unlimited)"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" desc:"maximum idle connections"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" desc:"close connections older than this (0: never)"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" desc:"close connections idle for longer than this (0: never)"`
+	// lib/pq ignores the context while connecting, so new connections
+	// are bounded by ConnectTimeout rather than QueryTimeout.
+	ConnectTimeout Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" desc:"give up opening a connection after this, rounded up to seconds (0: never)"`
+	// Deadline shared by the queries of one v1 delivery request, kept
+	// shorter than server.request_timeout.
+	QueryTimeout Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" desc:"deadline for the queries of one delivery request (0: none)"`
+	// Circuit breaker guarding per-request v1 queries.
+	BreakerFailures int      `yaml:"breaker_failures" env:"DB_BREAKER_FAILURES" desc:"consecutive query failures that open the circuit breaker"`
+	BreakerCooldown Duration `yaml:"breaker_cooldown" env:"DB_BREAKER_COOLDOWN" desc:"how long the open breaker waits before probing the database"`
+}
+
+// Snapshot holds the campaign snapshot settings.
+type Snapshot struct {
+	RefreshInterval Duration `yaml:"refresh_interval" env:"SNAPSHOT_REFRESH_INTERVAL" desc:"how often the campaign snapshot is reloaded"`
+	MaxAge          Duration `yaml:"max_age" env:"SNAPSHOT_MAX_AGE" desc:"readiness fails when the snapshot is older than this"`
+	// ServeStale keeps delivery answering from the last loaded snapshot
+	// while the database is down.
+	ServeStale  bool   `yaml:"serve_stale" env:"SNAPSHOT_SERVE_STALE" desc:"serve from the last loaded snapshot while the database is down"`
+	PersistPath string `yaml:"persist_path" env:"SNAPSHOT_PERSIST_PATH" desc:"file the snapshot is saved to, for cold starts during an outage (empty: off)"`
+}
+
+// Auth holds the API key settings.
+type Auth struct {
+	Enabled         bool     `yaml:"enabled" env:"AUTH_ENABLED" desc:"require API keys on delivery, admin and metrics routes; false, for local development only, also unmounts the admin API"`
+	AdminAPIKey     Secret   `yaml:"admin_api_key" env:"ADMIN_API_KEY" desc:"bootstrap token with every permission"`
+	RefreshInterval Duration `yaml:"refresh_interval" env:"AUTH_REFRESH_INTERVAL" desc:"how often API keys are reloaded"`
+}
+
+// RateLimit points to the rate limit tiers.
+type RateLimit struct {
+	ConfigPath string `yaml:"config" env:"RATE_LIMIT_CONFIG" desc:"JSON file with rate limit tiers (empty: no limits)"`
+}
+
+// Auction holds the second-price auction settings.
+type Auction struct {
+	Enabled      bool    `yaml:"enabled" env:"AUCTION_ENABLED" desc:"run a second-price auction and return only the winner"`
+	IncrementCPM float64 `yaml:"increment_cpm" env:"AUCTION_INCREMENT_CPM" desc:"added to the floor when the winner has no competitor"`
+}
+
+// Tracking holds the impression and click tracking settings.
+type Tracking struct {
+	// Every replica must share the secret to accept the others' URLs.
+	Secret  Secret   `yaml:"secret" env:"TRACKING_SECRET" desc:"key tracking URLs are encrypted with (empty: no tracking URLs)"`
+	BaseURL string   `yaml:"base_url" env:"TRACKING_BASE_URL" desc:"scheme and host tracking URLs point to (empty: relative URLs)"`
+	MaxAge  Duration `yaml:"max_age" env:"TRACKING_MAX_AGE" desc:"how long after delivery tracking URLs are accepted"`
+}
+
+// Geo holds the IP geolocation settings.
+type Geo struct {
+	DBPath     string `yaml:"db_path" env:"GEOIP_DB_PATH" desc:"MaxMind .mmdb or .csv IP range file"`
+	Precedence string `yaml:"precedence" env:"GEOIP_PRECEDENCE" desc:"client or ip: which country wins when both are known"`
+}
+
+// Log holds the logging settings.
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" desc:"debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" desc:"json or text"`
+	// DeliverySampleRate keeps a fraction of the per-request delivery
+	// logs; server errors are always logged.
+	DeliverySampleRate float64 `yaml:"delivery_sample_rate" env:"LOG_DELIVERY_SAMPLE_RATE" desc:"fraction of successful delivery requests logged (debug level logs all)"`
+}
+
+// Tracing holds the OpenTelemetry settings.
+type Tracing struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER" desc:"none, stdout or otlp"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" desc:"OTLP/HTTP collector URL (empty: OTEL_EXPORTER_OTLP_* variables)"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" desc:"fraction of new traces sampled; incoming trace context decides for the rest"`
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" desc:"service.name resource attribute"`
+}
+
+// Metrics holds the Prometheus label limits. Values past a limit are
+// reported as "other", bounding the number of series.
+type Metrics struct {
+	MaxCampaigns int `yaml:"max_campaigns" env:"METRICS_MAX_CAMPAIGNS" desc:"campaigns labelled individually in delivery_served_total (0: no limit)"`
+	MaxCountries int `yaml:"max_countries" env:"METRICS_MAX_COUNTRIES" desc:"countries labelled individually in delivery_fill_total (0: no limit)"`
+	MaxOS        int `yaml:"max_os" env:"METRICS_MAX_OS" desc:"operating systems labelled individually in delivery_fill_total (0: no limit)"`
+}
+
+// Capture holds the delivery request capture settings, for replaying
+// traffic with targetingctl replay.
+type Capture struct {
+	Path       string  `yaml:"path" env:"CAPTURE_PATH" desc:"NDJSON file sampled delivery requests are appended to (empty: off)"`
+	SampleRate float64 `yaml:"sample_rate" env:"CAPTURE_SAMPLE_RATE" desc:"fraction of delivery requests captured"`
+	// Device IDs are hashed and coordinates rounded unless these are set
+	RawDeviceIDs    bool `yaml:"raw_device_ids" env:"CAPTURE_RAW_DEVICE_IDS" desc:"capture device IDs as received instead of a per-run keyed hash"`
+	PreciseLocation bool `yaml:"precise_location" env:"CAPTURE_PRECISE_LOCATION" desc:"capture lat/lon as received instead of rounded to 2 decimals"`
+}
+
+// Default returns the built-in configuration. The database password has no
+// default and must be configured unless the server trusts local connections.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:               ":8080",
+			ReadTimeout:        Duration(15 * time.Second),
+			WriteTimeout:       Duration(15 * time.Second),
+			IdleTimeout:        Duration(60 * time.Second),
+			RequestTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout:    Duration(30 * time.Second),
+			DrainDelay:         Duration(5 * time.Second),
+			HealthCheckTimeout: Duration(2 * time.Second),
+		},
+		DB: DB{
+			Host:            "localhost",
+			Port:            5432,
+			Name:            "targeting_db",
+			User:            "postgres",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+			ConnectTimeout:  Duration(5 * time.Second),
+			QueryTimeout:    Duration(2 * time.Second),
+			BreakerFailures: 5,
+			BreakerCooldown: Duration(5 * time.Second),
+		},
+		Snapshot: Snapshot{
+			RefreshInterval: Duration(10 * time.Second),
+			MaxAge:          Duration(time.Minute),
+			ServeStale:      true,
+		},
+		Auth:     Auth{Enabled: true, RefreshInterval: Duration(30 * time.Second)},
+		Auction:  Auction{IncrementCPM: auction.DefaultIncrementCPM},
+		Tracking: Tracking{MaxAge: Duration(24 * time.Hour)},
+		Geo:      Geo{Precedence: string(geo.PreferClient)},
+		Tracing:  Tracing{Exporter: "none", SampleRatio: 1, ServiceName: "targeting-engine"},
+		Log:      Log{Level: "info", Format: "json", DeliverySampleRate: 0.01},
+		Capture:  Capture{SampleRate: 0.001},
+		Metrics:  Metrics{MaxCampaigns: metrics.DefaultMaxCampaigns, MaxCountries: metrics.DefaultMaxCountries, MaxOS: metrics.DefaultMaxOS},
+	}
+}
+
+// Validate reports every invalid setting.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Addr == "" {
+		fail("server.addr must not be empty")
+	}
+	for _, d := range []struct {
+		name  string
+		value Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.request_timeout", c.Server.RequestTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"server.health_check_timeout", c.Server.HealthCheckTimeout},
+		{"db.breaker_cooldown", c.DB.BreakerCooldown},
+		{"snapshot.refresh_interval", c.Snapshot.RefreshInterval},
+		{"auth.refresh_interval", c.Auth.RefreshInterval},
+		{"tracking.max_age", c.Tracking.MaxAge},
+	} {
+		if d.value <= 0 {
+			fail("%s must be positive", d.name)
+		}
+	}
+	if c.Server.DrainDelay < 0 {
+		fail("server.drain_delay must not be negative")
+	}
+	if c.Snapshot.MaxAge < c.Snapshot.RefreshInterval {
+		fail("snapshot.max_age must be at least snapshot.refresh_interval")
+	}
+
+	if c.DB.Host == "" {
+		fail("db.host must not be empty")
+	}
+	if c.DB.Port < 1 || c.DB.Port > 65535 {
+		fail("db.port must be between 1 and 65535")
+	}
+	if c.DB.Name == "" {
+		fail("db.name must not be empty")
+	}
+	if c.DB.User == "" {
+		fail("db.user must not be empty")
+	}
+	switch c.DB.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		fail("db.ssl_mode must be disable, require, verify-ca or verify-full")
+	}
+	if c.DB.MaxOpenConns < 0 {
+		fail("db.max_open_conns must not be negative")
+	}
+	if c.DB.MaxIdleConns < 0 {
+		fail("db.max_idle_conns must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		fail("db.max_idle_conns must not exceed db.max_open_conns")
+	}
+	if c.DB.ConnMaxLifetime < 0 || c.DB.ConnMaxIdleTime < 0 {
+		fail("db.conn_max_lifetime and db.conn_max_idle_time must not be negative")
+	}
+	if c.DB.ConnectTimeout < 0 || c.DB.QueryTimeout < 0 {
+		fail("db.connect_timeout and db.query_timeout must not be negative")
+	}
+	if c.DB.QueryTimeout >= c.Server.RequestTimeout && c.Server.RequestTimeout > 0 {
+		fail("db.query_timeout must be shorter than server.request_timeout")
+	}
+	if c.DB.BreakerFailures < 1 {
+		fail("db.breaker_failures must be at least 1")
+	}
+
+	if c.Auction.IncrementCPM < 0 {
+		fail("auction.increment_cpm must not be negative")
+	}
+	if c.Tracking.BaseURL != "" {
+		if u, err := url.Parse(c.Tracking.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("tracking.base_url must be an http or https URL")
+		}
+	}
+	if _, err := geo.ParsePrecedence(c.Geo.Precedence); err != nil {
+		fail("geo.precedence: %v", err)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		fail("log.level must be debug, info, warn or error")
+	}
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		fail("log.format must be json or text")
+	}
+	if c.Log.DeliverySampleRate < 0 || c.Log.DeliverySampleRate > 1 {
+		fail("log.delivery_sample_rate must be between 0 and 1")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		fail("tracing.exporter must be none, stdout or otlp")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio must be between 0 and 1")
+	}
+	if c.Tracing.ServiceName == "" {
+		fail("tracing.service_name must not be empty")
+	}
+
+	if c.Capture.SampleRate < 0 || c.Capture.SampleRate > 1 {
+		fail("capture.sample_rate must be between 0 and 1")
+	}
+	if c.Metrics.MaxCampaigns < 0 || c.Metrics.MaxCountries < 0 || c.Metrics.MaxOS < 0 {
+		fail("metrics.max_campaigns, metrics.max_countries and metrics.max_os must not be negative")
+	}
+	return errors.Join(errs...)
+}
+
+// DSN returns the lib/pq connection URL.
+func (d DB) DSN() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password.Value()),
+		Host:     fmt.Sprintf("%s:%d", d.Host, d.Port),
+		Path:     "/" + d.Name,
+		RawQuery: "sslmode=" + url.QueryEscape(d.SSLMode),
+	}
+	if d.ConnectTimeout > 0 {
+		seconds := (time.Duration(d.ConnectTimeout) + time.Second - 1) / time.Second
+		u.RawQuery += fmt.Sprintf("&connect_timeout=%d", seconds)
+	}
+	return u.String()
+}
+
+// Open opens the database with the configured pool settings. Like
+// sql.Open it does not connect.
+func (d DB) Open() (*sql.DB, error) {
+	db, err := sql.Open("postgres", d.DSN())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(d.MaxOpenConns)
+	db.SetMaxIdleConns(d.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(d.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(d.ConnMaxIdleTime))
+	return db, nil
+}
+
+// String describes where the server connects, without the password.
+func (d DB) String() string {
+	return fmt.Sprintf("%s@%s:%d/%s", d.User, d.Host, d.Port, d.Name)
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable that may point to the config file
+// when no -config flag is given.
+const FileEnv = "CONFIG_FILE"
+
+// Load builds the configuration from defaults, the YAML file named by
+// -config or CONFIG_FILE, the environment and the flags in args, then
+// validates it. It registers its flags on fs, so callers may add their own
+// before calling it.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	path := fs.String("config", "", "YAML config file (env "+FileEnv+")")
+	overrides := make(map[string]string)
+	for _, f := range fields(&cfg) {
+		fs.Var(flagValue{name: f.name, overrides: overrides, isBool: f.isBool}, f.name, f.desc+" (env "+f.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	file := *path
+	if file == "" {
+		file = os.Getenv(FileEnv)
+	}
+	if err := apply(&cfg, file, os.LookupEnv, overrides); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadEnv builds the configuration from defaults, CONFIG_FILE and the
+// environment, for tools that only need the database settings and have
+// flags of their own.
+func LoadEnv() (*Config, error) {
+	cfg := Default()
+	if err := apply(&cfg, os.Getenv(FileEnv), os.LookupEnv, nil); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// flagValue records a flag in overrides for apply. Bool flags may be given
+// without a value, as in -auction.enabled.
+type flagValue struct {
+	name      string
+	overrides map[string]string
+	isBool    bool
+}
+
+func (v flagValue) String() string { return "" }
+
+func (v flagValue) Set(s string) error {
+	v.overrides[v.name] = s
+	return nil
+}
+
+func (v flagValue) IsBoolFlag() bool { return v.isBool }
+
+// apply layers the file, environment and flag overrides onto cfg in that
+// order and validates the result. A variable set to "" overrides too, e.g.
+// GEOIP_DB_PATH= turns off a file's geolocation database; for a field that
+// is not a string it is an error.
+func apply(cfg *Config, file string, lookupEnv func(string) (string, bool), overrides map[string]string) error {
+	if file != "" {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := decodeYAML(raw, cfg); err != nil {
+			return fmt.Errorf("parse %s: %w", file, err)
+		}
+	}
+
+	for _, f := range fields(cfg) {
+		if v, ok := lookupEnv(f.env); ok {
+			if err := f.set(v); err != nil {
+				return fmt.Errorf("invalid %s: %w", f.env, err)
+			}
+		}
+		if v, ok := overrides[f.name]; ok {
+			if err := f.set(v); err != nil {
+				return fmt.Errorf("invalid -%s: %w", f.name, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// decodeYAML reads raw into cfg, rejecting unknown keys. An empty file
+// changes nothing.
+func decodeYAML(raw []byte, cfg *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Print writes cfg as YAML with secrets redacted.
+func Print(w io.Writer, cfg *Config) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// field is one settable leaf of Config.
+type field struct {
+	name string // YAML path, also the flag name
+	env  string
+	desc string
+	set  func(string) error
+	// isBool is set for bool fields, whose flags need no value
+	isBool bool
+}
+
+// fields lists the leaves of cfg in declaration order.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			fv := v.Field(i)
+			if sf.Tag.Get("env") == "" {
+				walk(fv, name)
+				continue
+			}
+			out = append(out, field{name: name, env: sf.Tag.Get("env"), desc: sf.Tag.Get("desc"), set: setter(fv),
+				isBool: fv.Kind() == reflect.Bool})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// setter returns a function parsing a string into v.
+func setter(v reflect.Value) func(string) error {
+	if d, ok := v.Addr().Interface().(*Duration); ok {
+		return d.Set
+	}
+	return func(s string) error {
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(s)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(n))
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+		default:
+			return fmt.Errorf("unsupported config type %s", v.Type())
+		}
+		return nil
+	}
+}
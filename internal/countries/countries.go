@@ -0,0 +1,134 @@
+// Package countries normalises country identifiers to lower-case ISO 3166-1
+// alpha-2 codes, the form used throughout targeting rules and requests.
+package countries
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Country is an ISO 3166-1 entry.
+type Country struct {
+	Alpha2     string
+	Alpha3     string
+	Name       string
+	OtherNames []string
+}
+
+// aliases are colloquial names and codes that are not part of ISO 3166-1
+// but show up in SDK integrations and hand-written rules.
+var aliases = map[string]string{
+	"uk":            "gb",
+	"great britain": "gb",
+	"britain":       "gb",
+	"england":       "gb",
+	"scotland":      "gb",
+	"wales":         "gb",
+	"usa":           "us",
+	"america":       "us",
+	"uae":           "ae",
+	"emirates":      "ae",
+	"holland":       "nl",
+	"korea":         "kr",
+	"russia":        "ru",
+	"turkey":        "tr",
+	"ivory coast":   "ci",
+	"burma":         "mm",
+	"swaziland":     "sz",
+	"macedonia":     "mk",
+	"cape verde":    "cv",
+	"vatican":       "va",
+	"vatican city":  "va",
+	"palestine":     "ps",
+	"micronesia":    "fm",
+}
+
+var (
+	byAlpha2 = make(map[string]*Country, len(iso3166))
+	byKey    = make(map[string]string, 4*len(iso3166))
+)
+
+func init() {
+	for i := range iso3166 {
+		c := &iso3166[i]
+		byAlpha2[c.Alpha2] = c
+		byKey[c.Alpha2] = c.Alpha2
+		byKey[c.Alpha3] = c.Alpha2
+		byKey[key(c.Name)] = c.Alpha2
+		for _, n := range c.OtherNames {
+			byKey[key(n)] = c.Alpha2
+		}
+	}
+	for alias, code := range aliases {
+		if _, ok := byKey[alias]; !ok {
+			byKey[alias] = code
+		}
+	}
+}
+
+var foldAccents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "å", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "í", "i", "ï", "i",
+	"ó", "o", "ô", "o", "ö", "o", "ú", "u", "ü", "u", "ç", "c", "ñ", "n",
+	"’", "'",
+)
+
+// key canonicalises a code or name for lookup.
+func key(s string) string {
+	s = foldAccents.Replace(strings.ToLower(strings.TrimSpace(s)))
+	s = strings.TrimPrefix(s, "the ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Normalize resolves an alpha-2 or alpha-3 code, ISO name or common alias to
+// its lower-case alpha-2 code.
+func Normalize(s string) (string, bool) {
+	code, ok := byKey[key(s)]
+	return code, ok
+}
+
+// Lookup returns the country for a lower- or upper-case alpha-2 code.
+func Lookup(alpha2 string) (Country, bool) {
+	c, ok := byAlpha2[strings.ToLower(alpha2)]
+	if !ok {
+		return Country{}, false
+	}
+	return *c, true
+}
+
+// UnknownError lists values that could not be normalised.
+type UnknownError struct {
+	Values []string
+}
+
+func (e *UnknownError) Error() string {
+	return fmt.Sprintf("unknown country code(s): %s", strings.Join(e.Values, ", "))
+}
+
+// NormalizeList normalises every value, dropping duplicates while keeping
+// order. A nil list stays nil ("no constraint"). If any value is unknown an
+// *UnknownError is returned alongside the values that did resolve.
+func NormalizeList(values []string) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+
+	out := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	var unknown []string
+	for _, v := range values {
+		code, ok := Normalize(v)
+		if !ok {
+			unknown = append(unknown, v)
+			continue
+		}
+		if !seen[code] {
+			seen[code] = true
+			out = append(out, code)
+		}
+	}
+	if unknown != nil {
+		return out, &UnknownError{Values: unknown}
+	}
+	return out, nil
+}
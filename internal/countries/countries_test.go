@@ -0,0 +1,84 @@
+package countries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"us", "us", true},
+		{"US", "us", true},
+		{"usa", "us", true},
+		{"United States", "us", true},
+		{"canada", "ca", true},
+		{"CAN", "ca", true},
+		{"uk", "gb", true},
+		{"gbr", "gb", true},
+		{"germany", "de", true},
+		{"deu", "de", true},
+		{"  South Korea ", "kr", true},
+		{"Korea, Republic of", "kr", true},
+		{"cote d'ivoire", "ci", true},
+		{"Côte d’Ivoire", "ci", true},
+		{"turkey", "tr", true},
+		{"Türkiye", "tr", true},
+		{"the netherlands", "nl", true},
+		{"el", "", false}, // a language code, not a country
+		{"atlantis", "", false},
+		{"", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, ok := Normalize(tc.input)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRegistryIsConsistent(t *testing.T) {
+	require.Len(t, iso3166, 249)
+	for _, c := range iso3166 {
+		got, ok := Normalize(c.Alpha3)
+		assert.True(t, ok, c.Alpha3)
+		assert.Equal(t, c.Alpha2, got, "alpha-3 %s", c.Alpha3)
+
+		got, ok = Normalize(c.Name)
+		assert.True(t, ok, c.Name)
+		assert.Equal(t, c.Alpha2, got, "name %s", c.Name)
+	}
+}
+
+func TestNormalizeList(t *testing.T) {
+	got, err := NormalizeList([]string{"us", "USA", "canada"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us", "ca"}, got)
+
+	got, err = NormalizeList(nil)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = NormalizeList([]string{"de", "narnia", "mordor"})
+	var unknown *UnknownError
+	require.ErrorAs(t, err, &unknown)
+	assert.Equal(t, []string{"narnia", "mordor"}, unknown.Values)
+	assert.Equal(t, []string{"de"}, got)
+}
+
+func TestLookup(t *testing.T) {
+	c, ok := Lookup("IN")
+	require.True(t, ok)
+	assert.Equal(t, "ind", c.Alpha3)
+	assert.Equal(t, "India", c.Name)
+
+	_, ok = Lookup("zz")
+	assert.False(t, ok)
+}
@@ -0,0 +1,271 @@
+// Package creatives manages the ad creatives of a campaign and picks the one
+// to serve for a delivery request.
+package creatives
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Creative formats.
+const (
+	FormatBanner       = "banner"
+	FormatInterstitial = "interstitial"
+	FormatNative       = "native"
+	FormatVideo        = "video"
+)
+
+// Creative statuses. Only active creatives are served.
+const (
+	StatusActive = "ACTIVE"
+	StatusPaused = "PAUSED"
+)
+
+// RotationWeighted rotates between a campaign's compatible creatives in
+// proportion to their weights. Requests with a device ID always get the same
+// creative for a given set of candidates.
+const RotationWeighted = "weighted"
+
+// requiredAssets lists the assets each format cannot be rendered without.
+var requiredAssets = map[string][]string{
+	FormatBanner:       {"img"},
+	FormatInterstitial: {"img"},
+	FormatNative:       {"title"},
+	FormatVideo:        {"video_url"},
+}
+
+// ParseFormat validates a creative format name.
+func ParseFormat(s string) (string, error) {
+	f := strings.ToLower(strings.TrimSpace(s))
+	if _, ok := requiredAssets[f]; !ok {
+		return "", fmt.Errorf("unknown creative format %q", s)
+	}
+	return f, nil
+}
+
+// ParseSize validates a "WxH" slot size and returns it in canonical form.
+func ParseSize(s string) (string, error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if !ok {
+		return "", fmt.Errorf("invalid size %q: want WxH", s)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width <= 0 {
+		return "", fmt.Errorf("invalid size %q: want WxH", s)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height <= 0 {
+		return "", fmt.Errorf("invalid size %q: want WxH", s)
+	}
+	return strconv.Itoa(width) + "x" + strconv.Itoa(height), nil
+}
+
+// ParseRotation validates a campaign's creative rotation setting.
+func ParseRotation(s string) (string, error) {
+	switch r := strings.ToLower(strings.TrimSpace(s)); r {
+	case "", "none":
+		return "", nil
+	case RotationWeighted:
+		return r, nil
+	default:
+		return "", fmt.Errorf("unknown creative rotation %q", s)
+	}
+}
+
+// Normalize validates c and canonicalises its format, sizes, status and
+// weight in place.
+func Normalize(c *models.Creative) error {
+	if strings.TrimSpace(c.ID) == "" {
+		return fmt.Errorf("missing creative id")
+	}
+	format, err := ParseFormat(c.Format)
+	if err != nil {
+		return err
+	}
+	c.Format = format
+
+	for i, s := range c.Sizes {
+		if c.Sizes[i], err = ParseSize(s); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range requiredAssets[format] {
+		if strings.TrimSpace(c.Assets[name]) == "" {
+			return fmt.Errorf("%s creative needs a %q asset", format, name)
+		}
+	}
+
+	switch c.Status = strings.ToUpper(strings.TrimSpace(c.Status)); c.Status {
+	case "":
+		c.Status = StatusActive
+	case StatusActive, StatusPaused:
+	default:
+		return fmt.Errorf("unknown creative status %q", c.Status)
+	}
+
+	if c.Weight == 0 {
+		c.Weight = 1
+	}
+	if c.Weight < 0 {
+		return fmt.Errorf("creative weight must be positive")
+	}
+	return nil
+}
+
+// Slot describes what a delivery request can display. A nil list accepts
+// anything; an empty non-nil list accepts nothing.
+type Slot struct {
+	Formats []string
+	Sizes   []string
+}
+
+// SlotFor combines the request's format and size with the placement's
+// allowed formats and sizes. p may be nil.
+func SlotFor(req models.DeliveryRequest, p *models.Placement) Slot {
+	var slot Slot
+	if p != nil {
+		slot.Formats = p.Formats
+		if len(p.Sizes) > 0 {
+			slot.Sizes = p.Sizes
+		}
+	}
+	slot.Formats = narrow(slot.Formats, req.Format)
+	slot.Sizes = narrow(slot.Sizes, req.Size)
+	return slot
+}
+
+// narrow restricts allowed to value, if one was requested.
+func narrow(allowed []string, value string) []string {
+	if value == "" {
+		return allowed
+	}
+	if allowed == nil || contains(allowed, value) {
+		return []string{value}
+	}
+	return []string{}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Compatible reports whether c is active and can fill slot. A creative
+// without sizes fits any size.
+func Compatible(c *models.Creative, slot Slot) bool {
+	if c.Status != StatusActive {
+		return false
+	}
+	if slot.Formats != nil && !contains(slot.Formats, c.Format) {
+		return false
+	}
+	if slot.Sizes == nil || len(c.Sizes) == 0 {
+		return true
+	}
+	for _, s := range c.Sizes {
+		if contains(slot.Sizes, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select picks the creative of a campaign to serve in slot from its
+// creatives. Without rotation the heaviest compatible creative wins (ties by
+// ID); with RotationWeighted one is drawn by weight, sticky per deviceID
+// when one is given. ok is false when none is compatible.
+func Select(campaign models.Campaign, list []models.Creative, slot Slot, deviceID string) (*models.Creative, bool) {
+	var candidates []*models.Creative
+	for i := range list {
+		if Compatible(&list[i], slot) {
+			candidates = append(candidates, &list[i])
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Weight != candidates[j].Weight {
+			return candidates[i].Weight > candidates[j].Weight
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	if campaign.CreativeRotation != RotationWeighted || len(candidates) == 1 {
+		return candidates[0], true
+	}
+
+	total := 0
+	for _, c := range candidates {
+		total += c.Weight
+	}
+	var n int
+	if deviceID != "" {
+		n = int(xxhash.Sum64String(deviceID+"|"+campaign.ID) % uint64(total))
+	} else {
+		n = rand.IntN(total)
+	}
+	for _, c := range candidates {
+		if n < c.Weight {
+			return c, true
+		}
+		n -= c.Weight
+	}
+	return candidates[len(candidates)-1], true
+}
+
+// Fits reports whether a campaign with the given creatives can fill slot.
+// A campaign without creatives acts as a banner of any size built from its
+// own img and cta.
+func Fits(list []models.Creative, slot Slot) bool {
+	if len(list) == 0 {
+		return slot.Formats == nil || contains(slot.Formats, FormatBanner)
+	}
+	for i := range list {
+		if Compatible(&list[i], slot) {
+			return true
+		}
+	}
+	return false
+}
+
+// Assign picks a creative for every matched campaign, copying its img and
+// cta assets onto the campaign for clients that predate creatives.
+// Campaigns that cannot fill slot (see Fits) are dropped.
+func Assign(matched []models.Campaign, byCampaign map[string][]models.Creative, slot Slot, deviceID string) []models.Campaign {
+	out := make([]models.Campaign, 0, len(matched))
+	for _, c := range matched {
+		list := byCampaign[c.ID]
+		if len(list) == 0 {
+			if Fits(list, slot) {
+				out = append(out, c)
+			}
+			continue
+		}
+		creative, ok := Select(c, list, slot, deviceID)
+		if !ok {
+			continue
+		}
+		chosen := *creative
+		c.Creative = &chosen
+		if img := chosen.Assets["img"]; img != "" {
+			c.Img = img
+		}
+		if cta := chosen.Assets["cta"]; cta != "" {
+			c.CTA = cta
+		}
+		out = append(out, c)
+	}
+	return out
+}
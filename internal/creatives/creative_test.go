@@ -0,0 +1,199 @@
+package creatives
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+		hasError bool
+	}{
+		{"320x50", "320x50", false},
+		{" 728X90 ", "728x90", false},
+		{"0320x050", "320x50", false},
+		{"320", "", true},
+		{"0x50", "", true},
+		{"axb", "", true},
+		{"320x-50", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSize(tt.in)
+			if tt.hasError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	c := models.Creative{ID: "b1", Format: "Banner", Sizes: []string{"320X50"}, Assets: map[string]string{"img": "https://x"}}
+	require.NoError(t, Normalize(&c))
+	assert.Equal(t, "banner", c.Format)
+	assert.Equal(t, []string{"320x50"}, c.Sizes)
+	assert.Equal(t, StatusActive, c.Status)
+	assert.Equal(t, 1, c.Weight)
+
+	tests := []struct {
+		name     string
+		creative models.Creative
+		errorMsg string
+	}{
+		{"Missing id", models.Creative{Format: "banner"}, "missing creative id"},
+		{"Unknown format", models.Creative{ID: "x", Format: "billboard"}, `unknown creative format "billboard"`},
+		{"Missing asset", models.Creative{ID: "x", Format: "video"}, `video creative needs a "video_url" asset`},
+		{"Bad size", models.Creative{ID: "x", Format: "native", Sizes: []string{"big"}, Assets: map[string]string{"title": "t"}}, "invalid size"},
+		{"Bad status", models.Creative{ID: "x", Format: "native", Status: "live", Assets: map[string]string{"title": "t"}}, `unknown creative status "LIVE"`},
+		{"Negative weight", models.Creative{ID: "x", Format: "native", Weight: -1, Assets: map[string]string{"title": "t"}}, "creative weight must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Normalize(&tt.creative)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func catalogue() ([]models.Campaign, map[string][]models.Creative) {
+	matched := []models.Campaign{
+		{ID: "spotify", Img: "legacy", CTA: "Download"},
+		{ID: "legacy", Img: "legacy-img", CTA: "Play"},
+		{ID: "paused", Img: "paused-img"},
+	}
+	byCampaign := map[string][]models.Creative{
+		"spotify": {
+			{ID: "banner-small", Format: FormatBanner, Sizes: []string{"320x50"}, Assets: map[string]string{"img": "small"}, Status: StatusActive, Weight: 1},
+			{ID: "banner-big", Format: FormatBanner, Sizes: []string{"728x90", "320x50"}, Assets: map[string]string{"img": "big", "cta": "Listen"}, Status: StatusActive, Weight: 3},
+			{ID: "video", Format: FormatVideo, Assets: map[string]string{"video_url": "v"}, Status: StatusActive, Weight: 1},
+		},
+		"paused": {
+			{ID: "off", Format: FormatBanner, Assets: map[string]string{"img": "off"}, Status: StatusPaused, Weight: 1},
+		},
+	}
+	return matched, byCampaign
+}
+
+func TestAssign(t *testing.T) {
+	matched, byCampaign := catalogue()
+
+	tests := []struct {
+		name      string
+		slot      Slot
+		expected  []string // campaign IDs
+		creatives []string // chosen creative per campaign, "" for none
+	}{
+		{"No placement prefers heaviest", Slot{}, []string{"spotify", "legacy"}, []string{"banner-big", ""}},
+		{"Size filters creatives", Slot{Formats: []string{FormatBanner}, Sizes: []string{"728x90"}}, []string{"spotify", "legacy"}, []string{"banner-big", ""}},
+		{"Video excludes legacy campaigns", Slot{Formats: []string{FormatVideo}, Sizes: []string{"320x480"}}, []string{"spotify"}, []string{"video"}},
+		{"Placement allows several formats", Slot{Formats: []string{FormatVideo, FormatInterstitial}}, []string{"spotify"}, []string{"video"}},
+		{"No compatible creative", Slot{Formats: []string{FormatNative}}, []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := Assign(matched, byCampaign, tt.slot, "")
+			ids, chosen := []string{}, []string{}
+			for _, c := range out {
+				ids = append(ids, c.ID)
+				if c.Creative != nil {
+					chosen = append(chosen, c.Creative.ID)
+				} else {
+					chosen = append(chosen, "")
+				}
+			}
+			assert.Equal(t, tt.expected, ids)
+			assert.Equal(t, tt.creatives, chosen)
+		})
+	}
+
+	// Assets override the legacy img and cta; the input is left untouched
+	out := Assign(matched, byCampaign, Slot{Sizes: []string{"320x50"}}, "")
+	assert.Equal(t, "big", out[0].Img)
+	assert.Equal(t, "Listen", out[0].CTA)
+	assert.Equal(t, "legacy", matched[0].Img)
+	assert.Nil(t, matched[0].Creative)
+}
+
+func TestSlotFor(t *testing.T) {
+	placement := &models.Placement{ID: "home", Formats: []string{FormatBanner, FormatNative}, Sizes: []string{"320x50"}}
+
+	tests := []struct {
+		name      string
+		req       models.DeliveryRequest
+		placement *models.Placement
+		expected  Slot
+	}{
+		{"No placement or format", models.DeliveryRequest{}, nil, Slot{}},
+		{"Request only", models.DeliveryRequest{Format: FormatVideo, Size: "320x480"}, nil, Slot{Formats: []string{FormatVideo}, Sizes: []string{"320x480"}}},
+		{"Placement only", models.DeliveryRequest{}, placement, Slot{Formats: []string{FormatBanner, FormatNative}, Sizes: []string{"320x50"}}},
+		{"Request narrows placement", models.DeliveryRequest{Format: FormatNative}, placement, Slot{Formats: []string{FormatNative}, Sizes: []string{"320x50"}}},
+		{"Request outside placement", models.DeliveryRequest{Format: FormatVideo, Size: "728x90"}, placement, Slot{Formats: []string{}, Sizes: []string{}}},
+		{"Placement without sizes", models.DeliveryRequest{}, &models.Placement{Formats: []string{FormatBanner}, Sizes: []string{}}, Slot{Formats: []string{FormatBanner}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, SlotFor(tt.req, tt.placement))
+		})
+	}
+
+	// An unsatisfiable slot only fits nothing, not even legacy campaigns
+	assert.False(t, Fits(nil, Slot{Formats: []string{}}))
+	assert.True(t, Fits(nil, Slot{}))
+}
+
+func TestSelectWeightedRotation(t *testing.T) {
+	campaign := models.Campaign{ID: "spotify", CreativeRotation: RotationWeighted}
+	list := []models.Creative{
+		{ID: "a", Format: FormatBanner, Status: StatusActive, Weight: 3},
+		{ID: "b", Format: FormatBanner, Status: StatusActive, Weight: 1},
+	}
+
+	// Sticky per device
+	first, ok := Select(campaign, list, Slot{}, "device-1")
+	require.True(t, ok)
+	for i := 0; i < 10; i++ {
+		again, _ := Select(campaign, list, Slot{}, "device-1")
+		assert.Equal(t, first.ID, again.ID)
+	}
+
+	// Distribution roughly follows weights across devices
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		c, _ := Select(campaign, list, Slot{}, fmt.Sprintf("device-%d", i))
+		counts[c.ID]++
+	}
+	assert.InDelta(t, 3000, counts["a"], 250)
+	assert.InDelta(t, 1000, counts["b"], 250)
+
+	// Without rotation the heaviest creative always wins
+	campaign.CreativeRotation = ""
+	for i := 0; i < 10; i++ {
+		c, _ := Select(campaign, list, Slot{}, "")
+		assert.Equal(t, "a", c.ID)
+	}
+}
+
+func TestParseRotation(t *testing.T) {
+	r, err := ParseRotation("Weighted")
+	require.NoError(t, err)
+	assert.Equal(t, RotationWeighted, r)
+
+	r, err = ParseRotation("none")
+	require.NoError(t, err)
+	assert.Empty(t, r)
+
+	_, err = ParseRotation("round-robin")
+	assert.Error(t, err)
+}
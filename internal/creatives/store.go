@@ -0,0 +1,125 @@
+package creatives
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// ErrOtherCampaign is returned by Save when the creative ID is already used
+// by a different campaign.
+var ErrOtherCampaign = errors.New("creative id belongs to another campaign")
+
+const creativeColumns = `cr.id, cr.cid, cr.format, cr.sizes, cr.assets, cr.status, cr.weight`
+
+// List returns a campaign's creatives ordered by ID.
+func List(db *sql.DB, campaignID string) ([]models.Creative, error) {
+	query := `SELECT ` + creativeColumns + ` FROM creatives cr WHERE cr.cid = $1 ORDER BY cr.id`
+
+	rows, err := db.Query(query, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCreatives(rows)
+}
+
+// ListByCampaign returns the creatives of the given campaigns grouped by
+// campaign ID, recording the query time in reg. A nil campaignIDs loads the
+// creatives of every active campaign. Paused creatives are included so that
+// a campaign whose creatives are all paused is not mistaken for one without
+// creatives.
+func ListByCampaign(ctx context.Context, db *sql.DB, reg *metrics.Registry, campaignIDs []string) (map[string][]models.Creative, error) {
+	query := `
+	SELECT ` + creativeColumns + `
+	FROM creatives cr
+	JOIN campaigns c ON c.cid = cr.cid
+	WHERE c.status = 'ACTIVE'
+	  AND ($1::TEXT[] IS NULL OR cr.cid = ANY($1))
+	ORDER BY cr.cid, cr.id
+	`
+	var ids interface{}
+	if campaignIDs != nil {
+		ids = pq.StringArray(campaignIDs)
+	}
+
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reg.ObserveDBQuery(time.Since(start).Seconds())
+
+	list, err := scanCreatives(rows)
+	if err != nil {
+		return nil, err
+	}
+	byCampaign := make(map[string][]models.Creative)
+	for _, c := range list {
+		byCampaign[c.CampaignID] = append(byCampaign[c.CampaignID], c)
+	}
+	return byCampaign, nil
+}
+
+// Save creates or replaces a creative. It should already be normalised with
+// Normalize.
+func Save(db *sql.DB, c models.Creative) error {
+	assets, err := json.Marshal(c.Assets)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO creatives (id, cid, format, sizes, assets, status, weight, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+	ON CONFLICT (id) DO UPDATE SET
+		format = EXCLUDED.format, sizes = EXCLUDED.sizes, assets = EXCLUDED.assets,
+		status = EXCLUDED.status, weight = EXCLUDED.weight, updated_at = now()
+	WHERE creatives.cid = EXCLUDED.cid
+	`
+	res, err := db.Exec(query, c.ID, c.CampaignID, c.Format, pq.StringArray(c.Sizes), assets, c.Status, c.Weight)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOtherCampaign
+	}
+	return nil
+}
+
+// Delete removes a creative of a campaign. It returns sql.ErrNoRows if the
+// campaign has no such creative.
+func Delete(db *sql.DB, campaignID, id string) error {
+	res, err := db.Exec(`DELETE FROM creatives WHERE cid = $1 AND id = $2`, campaignID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanCreatives(rows *sql.Rows) ([]models.Creative, error) {
+	var list []models.Creative
+	for rows.Next() {
+		var c models.Creative
+		var assets []byte
+		if err := rows.Scan(&c.ID, &c.CampaignID, &c.Format, (*pq.StringArray)(&c.Sizes), &assets, &c.Status, &c.Weight); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(assets, &c.Assets); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
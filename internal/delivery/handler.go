@@ -3,19 +3,64 @@ package delivery
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/countries"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/geo"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/replay"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/service"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
 )
 
-func HandleDeliveryRequest(db *sql.DB) http.HandlerFunc {
+// StaleHeader is set to "true" on delivery responses served from a
+// campaign snapshot that may be out of date.
+const StaleHeader = "X-Snapshot-Stale"
+
+// Errors of the debug param, shared by v1 and v2.
+var (
+	ErrInvalidDebug   = errors.New("invalid debug param")
+	ErrDebugForbidden = errors.New("auction diagnostics require a global admin key")
+)
+
+// AuctionDebug reports whether r asks for auction diagnostics with
+// debug=auction. They reveal bids, so asking without being allowed to see
+// bids is ErrDebugForbidden.
+func AuctionDebug(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("debug") {
+	case "":
+		return false, nil
+	case "auction":
+		if !auth.MaySeeBids(r.Context()) {
+			return false, ErrDebugForbidden
+		}
+		return true, nil
+	default:
+		return false, ErrInvalidDebug
+	}
+}
+
+// HandleDeliveryRequest serves /v1/delivery from the database, falling back
+// to the snapshot as cfg.Fallback allows. With auctions enabled only the
+// auction winner is returned, carrying its clearing price, and its
+// diagnostics for debug=auction requests allowed to see bids. The database
+// queries of one request share a deadline of cfg.QueryTimeout and are
+// cancelled when the client goes away or the request times out.
+func HandleDeliveryRequest(db *sql.DB, cfg service.Config) http.HandlerFunc {
+	svc, reg := service.NewDatabase(db, "v1", cfg), cfg.Metrics
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		ctx := r.Context()
+		logging.Sample(ctx)
 
 		// Set response headers
 		w.Header().Set("Content-Type", "application/json")
@@ -23,38 +68,75 @@ func HandleDeliveryRequest(db *sql.DB) http.HandlerFunc {
 		// Validate request parameters
 		req, errMsg := validateParams(r)
 		if errMsg != "" {
-			log.Printf("❌ Invalid request parameters: %s", errMsg)
+			logging.AddAttrs(ctx, slog.String("error", errMsg))
 			w.WriteHeader(http.StatusBadRequest)
-			metrics.ObserveRequest("bad_request", time.Since(start).Seconds())
+			reg.ObserveRequest("v1", metrics.StatusBadRequest, time.Since(start).Seconds())
 			json.NewEncoder(w).Encode(map[string]string{"error": errMsg})
 			return
 		}
+		debug, err := AuctionDebug(r)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrDebugForbidden) {
+				status = http.StatusForbidden
+			}
+			logging.AddAttrs(ctx, logging.Err(err))
+			w.WriteHeader(status)
+			reg.ObserveRequest("v1", metrics.StatusBadRequest, time.Since(start).Seconds())
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		logging.AddAttrs(ctx, service.LogAttrs(req)...)
+		res, err := svc.Deliver(ctx, req)
+
+		// Nobody is waiting for an answer: the client went away or the
+		// timeout middleware has answered 504
+		if reason := service.CancelReason(ctx); reason != "" {
+			logging.AddAttrs(ctx, slog.String("cancelled", reason))
+			reg.ObserveCancelled("v1", reason)
+			reg.ObserveRequest("v1", metrics.StatusCancelled, time.Since(start).Seconds())
+			return
+		}
 
-		// Get matching campaigns
-		matched, err := campaigns.GetMatchingCampaigns(db, req.App, req.Country, req.OS)
+		if errors.Is(err, placements.ErrInvalid) {
+			logging.AddAttrs(ctx, logging.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			reg.ObserveRequest("v1", metrics.StatusBadRequest, time.Since(start).Seconds())
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
 		if err != nil {
-			log.Printf("❌ Database query failed: %v", err)
+			logging.AddAttrs(ctx, logging.Err(err))
 			w.WriteHeader(http.StatusInternalServerError)
-			metrics.ObserveRequest("error", time.Since(start).Seconds())
+			reg.ObserveRequest("v1", metrics.StatusError, time.Since(start).Seconds())
 			json.NewEncoder(w).Encode(map[string]string{"error": "internal server error"})
 			return
 		}
+		if res.Stale {
+			w.Header().Set(StaleHeader, "true")
+		}
 
 		// Log request details
-		log.Printf("📊 Request: app=%s, country=%s, os=%s, matches=%d, duration=%v",
-			req.App, req.Country, req.OS, len(matched), time.Since(start))
+		matched := res.Campaigns
+		logging.AddAttrs(ctx, append(res.Details, slog.Int("matches", len(matched)))...)
+		service.ObserveServed(reg, "v1", req, matched)
+		replay.Capture(ctx, req, matched)
 
 		// Return appropriate response
 		if len(matched) == 0 {
 			w.WriteHeader(http.StatusNoContent)
-			metrics.ObserveRequest("no_content", time.Since(start).Seconds())
+			reg.ObserveRequest("v1", metrics.StatusNoContent, time.Since(start).Seconds())
 			return
 		}
 
+		if debug {
+			matched = models.WithAuctionDiagnostics(matched)
+		}
 		w.WriteHeader(http.StatusOK)
-		metrics.ObserveRequest("ok", time.Since(start).Seconds())
+		reg.ObserveRequest("v1", metrics.StatusOK, time.Since(start).Seconds())
 		if err := json.NewEncoder(w).Encode(matched); err != nil {
-			log.Printf("❌ Failed to encode response: %v", err)
+			slog.WarnContext(ctx, "failed to encode response", logging.Err(err))
 		}
 	}
 }
@@ -62,7 +144,8 @@ func HandleDeliveryRequest(db *sql.DB) http.HandlerFunc {
 // validateParams validates the required query parameters
 func validateParams(r *http.Request) (models.DeliveryRequest, string) {
 	app := strings.TrimSpace(r.URL.Query().Get("app"))
-	country := strings.TrimSpace(r.URL.Query().Get("country"))
+	// Fall back to (or prefer, if so configured) the IP-derived country
+	country := geo.ResolveCountry(r.Context(), strings.TrimSpace(r.URL.Query().Get("country")))
 	os := strings.TrimSpace(r.URL.Query().Get("os"))
 
 	if app == "" {
@@ -71,13 +154,44 @@ func validateParams(r *http.Request) (models.DeliveryRequest, string) {
 	if country == "" {
 		return models.DeliveryRequest{}, "missing country param"
 	}
+	country, ok := countries.Normalize(country)
+	if !ok {
+		return models.DeliveryRequest{}, "invalid country param"
+	}
 	if os == "" {
 		return models.DeliveryRequest{}, "missing os param"
 	}
 
-	return models.DeliveryRequest{
-		App:     app,
-		Country: strings.ToLower(country),
-		OS:      strings.ToLower(os),
-	}, ""
+	lat, lon, err := targeting.ParseCoordinates(r.URL.Query().Get("lat"), r.URL.Query().Get("lon"))
+	if err != nil {
+		return models.DeliveryRequest{}, err.Error()
+	}
+
+	var format, size string
+	if v := r.URL.Query().Get("format"); v != "" {
+		if format, err = creatives.ParseFormat(v); err != nil {
+			return models.DeliveryRequest{}, "invalid format param"
+		}
+	}
+	if v := r.URL.Query().Get("size"); v != "" {
+		if size, err = creatives.ParseSize(v); err != nil {
+			return models.DeliveryRequest{}, "invalid size param"
+		}
+	}
+
+	req := models.DeliveryRequest{
+		App:         app,
+		Country:     country,
+		OS:          strings.ToLower(os),
+		Region:      strings.ToLower(strings.TrimSpace(r.URL.Query().Get("region"))),
+		City:        strings.ToLower(strings.TrimSpace(r.URL.Query().Get("city"))),
+		Lat:         lat,
+		Lon:         lon,
+		DeviceID:    strings.TrimSpace(r.URL.Query().Get("device_id")),
+		Format:      format,
+		Size:        size,
+		PlacementID: placements.NormalizeID(r.URL.Query().Get("placement_id")),
+	}
+	geo.FillLocation(r.Context(), &req)
+	return req, ""
 }
@@ -1,15 +1,29 @@
 package delivery
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/breaker"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/geo"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/service"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/tracking"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -55,6 +69,42 @@ func TestValidateParams(t *testing.T) {
 			expected: models.DeliveryRequest{App: "COM.TEST", Country: "us", OS: "android"},
 			hasError: false,
 		},
+		{
+			name:     "Country name normalised to alpha-2",
+			query:    "?app=com.test&country=Germany&os=android",
+			expected: models.DeliveryRequest{App: "com.test", Country: "de", OS: "android"},
+			hasError: false,
+		},
+		{
+			name:     "Unknown country",
+			query:    "?app=com.test&country=atlantis&os=android",
+			hasError: true,
+			errorMsg: "invalid country param",
+		},
+		{
+			name:     "Placement format and size",
+			query:    "?app=com.test&country=us&os=android&format=Banner&size=320X50",
+			expected: models.DeliveryRequest{App: "com.test", Country: "us", OS: "android", Format: "banner", Size: "320x50"},
+			hasError: false,
+		},
+		{
+			name:     "Placement ID",
+			query:    "?app=com.test&country=us&os=android&placement_id=%20Home-Banner",
+			expected: models.DeliveryRequest{App: "com.test", Country: "us", OS: "android", PlacementID: "home-banner"},
+			hasError: false,
+		},
+		{
+			name:     "Unknown format",
+			query:    "?app=com.test&country=us&os=android&format=billboard",
+			hasError: true,
+			errorMsg: "invalid format param",
+		},
+		{
+			name:     "Malformed size",
+			query:    "?app=com.test&country=us&os=android&size=large",
+			hasError: true,
+			errorMsg: "invalid size param",
+		},
 		{
 			name:     "Empty parameters",
 			query:    "?app=&country=&os=",
@@ -79,6 +129,24 @@ func TestValidateParams(t *testing.T) {
 	}
 }
 
+func TestValidateParams_GeoFallback(t *testing.T) {
+	resolver, err := geo.ParseCSV(strings.NewReader("192.0.2.0,192.0.2.255,US\n"))
+	require.NoError(t, err)
+
+	var result models.DeliveryRequest
+	var errMsg string
+	handler := geo.Middleware(resolver, geo.PreferClient)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, errMsg = validateParams(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/delivery?app=com.test&os=android", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Empty(t, errMsg)
+	assert.Equal(t, models.DeliveryRequest{App: "com.test", Country: "us", OS: "android"}, result)
+}
+
 func TestHandleDeliveryRequest_Integration(t *testing.T) {
 	// Skip if database is not available
 	db, err := sql.Open("postgres", testDBConnStr)
@@ -159,7 +227,7 @@ func TestHandleDeliveryRequest_Integration(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/v1/delivery"+tc.query, nil)
 			w := httptest.NewRecorder()
 
-			handler := HandleDeliveryRequest(db)
+			handler := HandleDeliveryRequest(db, service.Config{})
 			handler(w, req)
 
 			assert.Equal(t, tc.expectedStatus, w.Code)
@@ -189,7 +257,7 @@ func TestHandleDeliveryRequest_ResponseFormat(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/v1/delivery?app=com.gametion.ludokinggame&country=us&os=android", nil)
 	w := httptest.NewRecorder()
 
-	handler := HandleDeliveryRequest(db)
+	handler := HandleDeliveryRequest(db, service.Config{})
 	handler(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -230,7 +298,7 @@ func TestHandleDeliveryRequest_Performance(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/v1/delivery?app=com.gametion.ludokinggame&country=us&os=android", nil)
 			w := httptest.NewRecorder()
 
-			handler := HandleDeliveryRequest(db)
+			handler := HandleDeliveryRequest(db, service.Config{})
 			handler(w, req)
 
 			results <- w.Code
@@ -249,3 +317,239 @@ func TestHandleDeliveryRequest_Performance(t *testing.T) {
 	// All requests should succeed
 	assert.Equal(t, numRequests, successCount)
 }
+
+// fakeSnapshot stands in for the snapshot-backed delivery service.
+type fakeSnapshot struct {
+	campaigns []models.Campaign
+	err       error
+}
+
+func (f fakeSnapshot) Deliver(context.Context, models.DeliveryRequest) ([]models.Campaign, error) {
+	return f.campaigns, f.err
+}
+
+func (f fakeSnapshot) Explain(context.Context, models.DeliveryRequest) ([]targeting.Explanation, error) {
+	return nil, f.err
+}
+
+func TestHandleDeliveryRequest_StaleFallback(t *testing.T) {
+	// Nothing listens on port 1, so every query fails
+	db, err := sql.Open("postgres", "postgres://postgres@127.0.0.1:1/targeting_db?sslmode=disable&connect_timeout=1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	spotify := []models.Campaign{{ID: "spotify", Name: "Spotify", Status: "ACTIVE"}}
+
+	tests := []struct {
+		name           string
+		fallback       service.Fallback
+		query          string
+		expectedStatus int
+		expectedStale  bool
+		expectedBody   string
+	}{
+		{
+			name:           "no fallback",
+			fallback:       service.Fallback{},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"internal server error"}`,
+		},
+		{
+			name:           "served from snapshot",
+			fallback:       service.Fallback{Snapshot: fakeSnapshot{campaigns: spotify}},
+			expectedStatus: http.StatusOK,
+			expectedStale:  true,
+		},
+		{
+			name:           "no match in snapshot",
+			fallback:       service.Fallback{Snapshot: fakeSnapshot{}},
+			expectedStatus: http.StatusNoContent,
+			expectedStale:  true,
+		},
+		{
+			name:           "invalid placement in snapshot",
+			fallback:       service.Fallback{Snapshot: fakeSnapshot{err: fmt.Errorf("%w: unknown placement_id", placements.ErrInvalid)}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "snapshot unavailable",
+			fallback:       service.Fallback{Snapshot: fakeSnapshot{err: errors.New("campaign snapshot not loaded")}},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			query := tc.query
+			if query == "" {
+				query = "?app=com.test&country=us&os=android"
+			}
+			w := httptest.NewRecorder()
+			HandleDeliveryRequest(db, service.Config{Fallback: tc.fallback})(w, httptest.NewRequest(http.MethodGet, "/v1/delivery"+query, nil))
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStale {
+				assert.Equal(t, "true", w.Header().Get(StaleHeader))
+			} else {
+				assert.Empty(t, w.Header().Get(StaleHeader))
+			}
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("breaker stops querying", func(t *testing.T) {
+		b := breaker.New("test", 2, time.Hour, nil)
+		handler := HandleDeliveryRequest(db, service.Config{Fallback: service.Fallback{Breaker: b, Snapshot: fakeSnapshot{campaigns: spotify}}})
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, "/v1/delivery?app=com.test&country=us&os=android", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "true", w.Header().Get(StaleHeader))
+		}
+		assert.Equal(t, breaker.Open, b.State())
+	})
+}
+
+func TestHandleDeliveryRequest_AuctionDiagnostics(t *testing.T) {
+	// Nothing listens on port 1: the snapshot answers
+	db, err := sql.Open("postgres", "postgres://postgres@127.0.0.1:1/targeting_db?sslmode=disable&connect_timeout=1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	won := []models.Campaign{{ID: "spotify", Auction: &models.Auction{
+		PriceCPM: 1.8, PriceSource: "second_price", BidCPM: 2.5, SecondCPM: 1.8, FloorCPM: 0.3, Bidders: 2,
+	}}}
+	issuer, err := tracking.NewIssuer("secret", "", time.Hour)
+	require.NoError(t, err)
+	handler := HandleDeliveryRequest(db, service.Config{
+		Fallback: service.Fallback{Snapshot: fakeSnapshot{campaigns: won}},
+		Tracking: issuer,
+	})
+	admin := &auth.Key{ID: "admin", Permissions: []auth.Permission{auth.PermDelivery, auth.PermAdmin}}
+	publisher := &auth.Key{ID: "gametion", Publisher: "gametion", Permissions: []auth.Permission{auth.PermDelivery, auth.PermAdmin}}
+
+	tests := []struct {
+		name      string
+		query     string
+		key       *auth.Key
+		wantCode  int
+		wantError string
+		wantDebug bool
+	}{
+		{name: "no debug", key: admin, wantCode: http.StatusOK},
+		{name: "global admin key", query: "&debug=auction", key: admin, wantCode: http.StatusOK, wantDebug: true},
+		{name: "publisher key", query: "&debug=auction", key: publisher, wantCode: http.StatusForbidden, wantError: ErrDebugForbidden.Error()},
+		{name: "no key", query: "&debug=auction", wantCode: http.StatusForbidden, wantError: ErrDebugForbidden.Error()},
+		{name: "unknown debug", query: "&debug=bids", key: admin, wantCode: http.StatusBadRequest, wantError: ErrInvalidDebug.Error()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/delivery?app=com.test&country=us&os=android"+tc.query, nil)
+			if tc.key != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), tc.key))
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			assert.Equal(t, tc.wantCode, w.Code)
+			if tc.wantError != "" {
+				assert.JSONEq(t, `{"error":"`+tc.wantError+`"}`, w.Body.String())
+				return
+			}
+			var got []models.Campaign
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			require.Len(t, got, 1)
+			assert.Equal(t, 1.8, got[0].Auction.PriceCPM)
+			assert.Equal(t, tc.wantDebug, got[0].Auction.Debug != nil)
+			assert.Equal(t, tc.wantDebug, strings.Contains(w.Body.String(), `"bid_cpm":2.5`))
+
+			// Tracking URLs carry the auction, readable only by the issuer
+			require.NotNil(t, got[0].Tracking)
+			assert.True(t, strings.HasPrefix(got[0].Tracking.ImpressionURL, "/v1/track/impression?t="))
+			e, err := issuer.Open(strings.TrimPrefix(got[0].Tracking.ImpressionURL, "/v1/track/impression?t="))
+			require.NoError(t, err)
+			assert.Equal(t, "com.test", e.App)
+			assert.Equal(t, 1.8, e.PriceCPM)
+			require.NotNil(t, e.Auction)
+			assert.Equal(t, 2.5, e.Auction.BidCPM)
+		})
+	}
+}
+
+func TestHandleDeliveryRequest_Cancelled(t *testing.T) {
+	db, err := sql.Open("postgres", "postgres://postgres@127.0.0.1:1/targeting_db?sslmode=disable&connect_timeout=1")
+	require.NoError(t, err)
+	defer db.Close()
+
+	spotify := []models.Campaign{{ID: "spotify", Name: "Spotify", Status: "ACTIVE"}}
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	closed, cancelClosed := context.WithCancel(context.Background())
+	cancelClosed()
+
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		queryTimeout   time.Duration
+		reason         string
+		expectedStatus int
+		expectedStale  bool
+		expectedState  breaker.State
+	}{
+		{
+			name:           "query deadline falls back to snapshot",
+			ctx:            context.Background(),
+			queryTimeout:   time.Nanosecond,
+			reason:         service.CancelQueryTimeout,
+			expectedStatus: http.StatusOK,
+			expectedStale:  true,
+			expectedState:  breaker.Open,
+		},
+		{
+			name:          "client closed",
+			ctx:           closed,
+			reason:        service.CancelClientClosed,
+			expectedState: breaker.Closed,
+		},
+		{
+			name:          "request timed out",
+			ctx:           expired,
+			reason:        service.CancelRequestTimeout,
+			expectedState: breaker.Closed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := metrics.New()
+			b := breaker.New("test", 1, time.Hour, reg)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/delivery?app=com.test&country=us&os=android", nil)
+			HandleDeliveryRequest(db, service.Config{
+				QueryTimeout: tc.queryTimeout,
+				Fallback:     service.Fallback{Breaker: b, Snapshot: fakeSnapshot{campaigns: spotify}},
+				Metrics:      reg,
+			})(w, r.WithContext(tc.ctx))
+
+			assert.Equal(t, 1.0, testutil.ToFloat64(reg.CancelledCount.WithLabelValues("v1", tc.reason)))
+			assert.Equal(t, tc.expectedState, b.State())
+			if tc.expectedStale {
+				assert.Equal(t, tc.expectedStatus, w.Code)
+				assert.Equal(t, "true", w.Header().Get(StaleHeader))
+				assert.Equal(t, 1.0, testutil.ToFloat64(reg.RequestCount.WithLabelValues("v1", metrics.StatusOK)))
+				assert.Equal(t, 1.0, testutil.ToFloat64(reg.FillCount.WithLabelValues("v1", "us", "android", "true")))
+				assert.Equal(t, 1.0, testutil.ToFloat64(reg.ServedCount.WithLabelValues("v1", "spotify")))
+			} else {
+				// Nothing is written: the client is gone or the timeout
+				// middleware answers
+				assert.Empty(t, w.Body.String())
+				assert.Equal(t, 1.0, testutil.ToFloat64(reg.RequestCount.WithLabelValues("v1", metrics.StatusCancelled)))
+				assert.Zero(t, testutil.CollectAndCount(reg.FillCount))
+			}
+		})
+	}
+}
@@ -2,48 +2,101 @@ package endpoints
 
 import (
 	"context"
-	"time"
+	"errors"
+	"log/slog"
 
 	"github.com/go-kit/kit/endpoint"
 
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/replay"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/service"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
 )
 
 // Request and Response models for the endpoint
  type DeliveryRequest struct {
-	App     string `json:"app"`
-	Country string `json:"country"`
-	OS      string `json:"os"`
+	App         string   `json:"app"`
+	Country     string   `json:"country"`
+	OS          string   `json:"os"`
+	Region      string   `json:"region,omitempty"`
+	City        string   `json:"city,omitempty"`
+	Lat         *float64 `json:"lat,omitempty"`
+	Lon         *float64 `json:"lon,omitempty"`
+	DeviceID    string   `json:"device_id,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Size        string   `json:"size,omitempty"`
+	PlacementID string   `json:"placement_id,omitempty"`
  }
 
+func (r DeliveryRequest) model() models.DeliveryRequest {
+	return models.DeliveryRequest(r)
+}
+
  type DeliveryResponse struct {
 	Campaigns []models.Campaign `json:"campaigns,omitempty"`
 	Err       string            `json:"error,omitempty"`
+	// Stale is set when the campaigns came from the snapshot because the
+	// database could not be queried
+	Stale bool `json:"-"`
  }
 
+type ExplainResponse struct {
+	Explanations []targeting.Explanation `json:"explanations"`
+	Err          string                  `json:"error,omitempty"`
+}
+
  type Endpoints struct {
 	Delivery endpoint.Endpoint
+	Explain  endpoint.Endpoint
  }
 
- func MakeDeliveryEndpoint(svc service.DeliveryService) endpoint.Endpoint {
+// MakeDeliveryEndpoint serves delivery from the database through svc,
+// recording cancelled and served requests in reg.
+ func MakeDeliveryEndpoint(svc *service.Database, reg *metrics.Registry) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		start := time.Now()
 		req := request.(DeliveryRequest)
-		campaigns, err := svc.Deliver(req.App, req.Country, req.OS)
-		status := "ok"
-		if err != nil {
-			status = "error"
+		res, err := svc.Deliver(ctx, req.model())
+		campaigns := res.Campaigns
+		logging.AddAttrs(ctx, service.LogAttrs(req.model())...)
+		logging.AddAttrs(ctx, append(res.Details, slog.Int("matches", len(campaigns)))...)
+		// The transport counts the request once its response is written
+		if reason := service.CancelReason(ctx); reason != "" {
+			reg.ObserveCancelled("v2", reason)
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, placements.ErrInvalid) {
+			return nil, err
 		}
-		metrics.ObserveRequest(status, time.Since(start).Seconds())
-
 		if err != nil {
+			logging.AddAttrs(ctx, logging.Err(err))
 			return DeliveryResponse{Err: "internal server error"}, nil
 		}
+		service.ObserveServed(reg, "v2", req.model(), campaigns)
+		replay.Capture(ctx, req.model(), campaigns)
 		if len(campaigns) == 0 {
-			return DeliveryResponse{Campaigns: []models.Campaign{}}, nil
+			return DeliveryResponse{Campaigns: []models.Campaign{}, Stale: res.Stale}, nil
+		}
+		return DeliveryResponse{Campaigns: campaigns, Stale: res.Stale}, nil
+	}
+ }
+
+// MakeExplainEndpoint reports, per campaign, which targeting rules matched a
+// request and why the others did not.
+func MakeExplainEndpoint(svc service.DeliveryService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeliveryRequest)
+		explanations, err := svc.Explain(ctx, req.model())
+		logging.AddAttrs(ctx, service.LogAttrs(req.model())...)
+		if errors.Is(err, placements.ErrInvalid) {
+			return nil, err
+		}
+		if err != nil {
+			logging.AddAttrs(ctx, logging.Err(err))
+			return ExplainResponse{Err: "internal server error"}, nil
 		}
-		return DeliveryResponse{Campaigns: campaigns}, nil
+		return ExplainResponse{Explanations: explanations}, nil
 	}
- }
\ No newline at end of file
+}
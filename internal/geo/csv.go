@@ -0,0 +1,108 @@
+package geo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// RangeResolver resolves locations from a sorted table of IP ranges.
+type RangeResolver struct {
+	ranges []ipRange
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	location   Location
+}
+
+// LoadCSV reads an IP range table from path. See ParseCSV for the format.
+func LoadCSV(path string) (*RangeResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCSV(f)
+}
+
+// ParseCSV reads rows of the form
+//
+//	start_ip,end_ip,country[,region[,city]]
+//
+// with inclusive IPv4 or IPv6 bounds. Lines starting with '#' and a header
+// row whose first field is not an IP are skipped. Ranges must not overlap.
+func ParseCSV(r io.Reader) (*RangeResolver, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var ranges []ipRange
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, err := netip.ParseAddr(strings.TrimSpace(rec[0]))
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: invalid start ip: %w", line, err)
+		}
+		if len(rec) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least start_ip,end_ip,country", line)
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid end ip: %w", line, err)
+		}
+		start, end = start.Unmap(), end.Unmap()
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, start, end)
+		}
+
+		loc := Location{Country: field(rec, 2), Region: field(rec, 3), City: field(rec, 4)}
+		ranges = append(ranges, ipRange{start: start, end: end, location: loc})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	for i := 1; i < len(ranges); i++ {
+		if !ranges[i-1].end.Less(ranges[i].start) {
+			return nil, fmt.Errorf("overlapping ranges starting at %s and %s", ranges[i-1].start, ranges[i].start)
+		}
+	}
+
+	return &RangeResolver{ranges: ranges}, nil
+}
+
+func field(rec []string, i int) string {
+	if i >= len(rec) {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(rec[i]))
+}
+
+// Lookup implements Resolver with a binary search over the ranges.
+func (rr *RangeResolver) Lookup(ip netip.Addr) (Location, bool) {
+	ip = ip.Unmap()
+	// First range starting after ip; the candidate is the one before it
+	i := sort.Search(len(rr.ranges), func(i int) bool { return ip.Less(rr.ranges[i].start) })
+	if i == 0 {
+		return Location{}, false
+	}
+	r := rr.ranges[i-1]
+	if r.end.Less(ip) || r.start.Is4() != ip.Is4() {
+		return Location{}, false
+	}
+	return r.location, true
+}
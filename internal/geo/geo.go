@@ -0,0 +1,137 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Location is the geography resolved for a client IP. Values are lower-case
+// to match the rest of the targeting model: Country is ISO 3166-1 alpha-2,
+// Region the ISO 3166-2 subdivision suffix and City the English name.
+type Location struct {
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+// Resolver looks up the location of an IP address.
+type Resolver interface {
+	Lookup(ip netip.Addr) (Location, bool)
+}
+
+// Precedence decides which country wins when a request supplies one and the
+// client IP also resolves to one.
+type Precedence string
+
+const (
+	// PreferClient keeps the client-supplied country and only falls back to
+	// the IP-derived one when the request omits it.
+	PreferClient Precedence = "client"
+	// PreferIP uses the IP-derived country whenever the IP resolves.
+	PreferIP Precedence = "ip"
+)
+
+// ParsePrecedence validates a precedence name.
+func ParsePrecedence(s string) (Precedence, error) {
+	switch p := Precedence(strings.ToLower(strings.TrimSpace(s))); p {
+	case PreferClient, PreferIP:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown geo precedence %q (want %q or %q)", s, PreferClient, PreferIP)
+	}
+}
+
+// Open loads a geolocation database from disk. Files ending in .mmdb are read
+// as MaxMind databases, .csv files as IP range tables (see LoadCSV).
+func Open(path string) (Resolver, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		return OpenMMDB(path)
+	case ".csv":
+		return LoadCSV(path)
+	default:
+		return nil, fmt.Errorf("unsupported geo database %q: expected .mmdb or .csv", path)
+	}
+}
+
+type contextKey struct{}
+
+type lookup struct {
+	location   Location
+	found      bool
+	precedence Precedence
+}
+
+// Middleware resolves the client IP of each request and attaches the result
+// to the request context for ResolveCountry and FromContext. Behind a proxy
+// it must run after chi's middleware.RealIP so that RemoteAddr holds the
+// real client IP.
+func Middleware(resolver Resolver, precedence Precedence) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := &lookup{precedence: precedence}
+			if ip, ok := clientIP(r.RemoteAddr); ok {
+				l.location, l.found = resolver.Lookup(ip)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, l)))
+		})
+	}
+}
+
+// FromContext returns the location resolved by Middleware, if any.
+func FromContext(ctx context.Context) (Location, bool) {
+	l, ok := ctx.Value(contextKey{}).(*lookup)
+	if !ok || !l.found {
+		return Location{}, false
+	}
+	return l.location, true
+}
+
+// ResolveCountry returns the country to target given the client-supplied
+// value (possibly empty), applying the precedence configured on Middleware.
+// Without a resolved location, supplied is returned unchanged.
+func ResolveCountry(ctx context.Context, supplied string) string {
+	l, ok := ctx.Value(contextKey{}).(*lookup)
+	if !ok || !l.found || l.location.Country == "" {
+		return supplied
+	}
+	if supplied == "" || l.precedence == PreferIP {
+		return l.location.Country
+	}
+	return supplied
+}
+
+// FillLocation sets req.Region and req.City from the resolved location when
+// the request did not supply them and the IP resolved to the request's
+// country, so a client-supplied country is never paired with a foreign city.
+func FillLocation(ctx context.Context, req *models.DeliveryRequest) {
+	loc, ok := FromContext(ctx)
+	if !ok || !strings.EqualFold(loc.Country, req.Country) {
+		return
+	}
+	if req.Region == "" && req.City == "" {
+		req.Region = loc.Region
+		req.City = loc.City
+	}
+}
+
+// clientIP parses RemoteAddr, which is "ip:port" from net/http or a bare IP
+// once middleware.RealIP has rewritten it.
+func clientIP(remoteAddr string) (netip.Addr, bool) {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
@@ -0,0 +1,97 @@
+package geo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRanges = `start_ip,end_ip,country,region,city
+# documentation ranges
+192.0.2.0,192.0.2.255,US,CA,San Francisco
+198.51.100.0,198.51.100.127,IN,KA,Bengaluru
+2001:db8::,2001:db8::ffff,DE,BE,Berlin
+`
+
+func TestParseCSVLookup(t *testing.T) {
+	rr, err := ParseCSV(strings.NewReader(testRanges))
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip    string
+		want  Location
+		found bool
+	}{
+		{"192.0.2.10", Location{Country: "us", Region: "ca", City: "san francisco"}, true},
+		{"192.0.2.255", Location{Country: "us", Region: "ca", City: "san francisco"}, true},
+		{"::ffff:198.51.100.1", Location{Country: "in", Region: "ka", City: "bengaluru"}, true},
+		{"198.51.100.128", Location{}, false},
+		{"2001:db8::42", Location{Country: "de", Region: "be", City: "berlin"}, true},
+		{"10.0.0.1", Location{}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.ip, func(t *testing.T) {
+			loc, ok := rr.Lookup(netip.MustParseAddr(tc.ip))
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.want, loc)
+		})
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("192.0.2.0,192.0.2.255,us\n192.0.2.128,192.0.3.0,ca\n"))
+	assert.ErrorContains(t, err, "overlapping")
+
+	_, err = ParseCSV(strings.NewReader("192.0.2.9,192.0.2.1,us\n"))
+	assert.ErrorContains(t, err, "invalid range")
+
+	_, err = ParseCSV(strings.NewReader("192.0.2.0,192.0.2.255,us\nnot-an-ip,192.0.2.1,us\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestMiddlewareResolveCountry(t *testing.T) {
+	rr, err := ParseCSV(strings.NewReader(testRanges))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		precedence Precedence
+		remoteAddr string
+		supplied   string
+		want       string
+	}{
+		{"fallback when missing", PreferClient, "192.0.2.1:4321", "", "us"},
+		{"client wins", PreferClient, "192.0.2.1:4321", "gb", "gb"},
+		{"ip wins", PreferIP, "192.0.2.1", "gb", "us"},
+		{"unresolved ip keeps supplied", PreferIP, "10.0.0.1", "gb", "gb"},
+		{"unparseable remote addr", PreferIP, "unknown", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			h := Middleware(rr, tc.precedence)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ResolveCountry(r.Context(), tc.supplied)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/v1/delivery", nil)
+			req.RemoteAddr = tc.remoteAddr
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	p, err := ParsePrecedence(" IP ")
+	require.NoError(t, err)
+	assert.Equal(t, PreferIP, p)
+
+	_, err = ParsePrecedence("header")
+	assert.Error(t, err)
+}
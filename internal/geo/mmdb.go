@@ -0,0 +1,57 @@
+package geo
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MMDBResolver resolves locations from a MaxMind-format (GeoIP2/GeoLite2
+// City or Country) database.
+type MMDBResolver struct {
+	reader *maxminddb.Reader
+}
+
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// OpenMMDB memory-maps the database at path.
+func OpenMMDB(path string) (*MMDBResolver, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MMDBResolver{reader: reader}, nil
+}
+
+// Lookup implements Resolver.
+func (m *MMDBResolver) Lookup(ip netip.Addr) (Location, bool) {
+	var rec mmdbRecord
+	if err := m.reader.Lookup(ip.AsSlice(), &rec); err != nil || rec.Country.ISOCode == "" {
+		return Location{}, false
+	}
+
+	loc := Location{
+		Country: strings.ToLower(rec.Country.ISOCode),
+		City:    strings.ToLower(rec.City.Names["en"]),
+	}
+	if len(rec.Subdivisions) > 0 {
+		loc.Region = strings.ToLower(rec.Subdivisions[0].ISOCode)
+	}
+	return loc, true
+}
+
+// Close unmaps the database.
+func (m *MMDBResolver) Close() error {
+	return m.reader.Close()
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/migrate"
+)
+
+// Database checks that the database answers a ping.
+func Database(db *sql.DB) Check {
+	return Check{Name: "database", Run: func(ctx context.Context) (interface{}, error) {
+		if err := db.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		stats := db.Stats()
+		return map[string]int{"open_connections": stats.OpenConnections, "in_use": stats.InUse}, nil
+	}}
+}
+
+// Snapshot checks that the campaign snapshot has loaded and is no older
+// than maxAge. With serveStale an old snapshot only degrades readiness.
+func Snapshot(store *campaigns.SnapshotStore, maxAge time.Duration, serveStale bool) Check {
+	return Check{Name: "snapshot", Run: func(context.Context) (interface{}, error) {
+		snap := store.Current()
+		detail, err := snapshotAge(snap, maxAge, time.Now())
+		if err != nil && snap != nil && serveStale {
+			err = Degrade(err)
+		}
+		return detail, err
+	}}
+}
+
+func snapshotAge(snap *campaigns.Snapshot, maxAge time.Duration, now time.Time) (interface{}, error) {
+	if snap == nil {
+		return nil, errors.New("campaign snapshot has not loaded")
+	}
+	age := now.Sub(snap.LoadedAt)
+	detail := map[string]interface{}{
+		"loaded_at": snap.LoadedAt.UTC(),
+		"age":       age.Round(time.Millisecond).String(),
+		"max_age":   maxAge.String(),
+	}
+	if age > maxAge {
+		return detail, fmt.Errorf("campaign snapshot is %v old (max %v)", age.Round(time.Second), maxAge)
+	}
+	return detail, nil
+}
+
+// Keys checks that the API keys have been loaded from the database and the
+// last refresh succeeded. Until then the server authenticates with the
+// bootstrap token and any keys restored from disk, so failures only degrade
+// readiness.
+func Keys(keys *auth.Keyring) Check {
+	return Check{Name: "auth", Run: func(context.Context) (interface{}, error) {
+		loadedAt, err := keys.Status()
+		detail := map[string]interface{}{"keys": keys.Len()}
+		if !loadedAt.IsZero() {
+			detail["loaded_at"] = loadedAt.UTC()
+		}
+		switch {
+		case err != nil:
+			return detail, Degrade(fmt.Errorf("API keys not refreshed: %w", err))
+		case loadedAt.IsZero():
+			return detail, Degrade(errors.New("API keys have not loaded"))
+		}
+		return detail, nil
+	}}
+}
+
+// Migrations checks that the database schema is at least at version want,
+// the latest migration this binary knows. A newer schema is fine: it was
+// applied by a newer replica during a rolling deploy. With serveStale an
+// unreachable database only degrades readiness; an old schema always fails.
+func Migrations(db *sql.DB, want int, serveStale bool) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) (interface{}, error) {
+		have, err := migrate.Version(ctx, db)
+		if err != nil {
+			if serveStale {
+				err = Degrade(err)
+			}
+			return nil, err
+		}
+		return schemaVersion(have, want)
+	}}
+}
+
+func schemaVersion(have, want int) (interface{}, error) {
+	detail := map[string]int{"version": have, "expected": want}
+	if have < want {
+		return detail, fmt.Errorf("schema at version %d, binary expects %d", have, want)
+	}
+	return detail, nil
+}
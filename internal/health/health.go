@@ -0,0 +1,171 @@
+// Package health serves the liveness and readiness probes. Liveness only
+// says the process is up; readiness runs dependency checks and fails while
+// the server is draining for shutdown.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShuttingDown is reported by readiness once Drain has been called.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// degradedError marks a check failure the server can serve through.
+type degradedError struct{ error }
+
+func (e degradedError) Unwrap() error { return e.error }
+
+// Degrade marks err as not fatal to readiness: the check reports
+// "degraded" and /readyz still answers 200.
+func Degrade(err error) error {
+	if err == nil {
+		return nil
+	}
+	return degradedError{err}
+}
+
+// Degraded wraps check so that all its failures are degraded, for
+// dependencies the server can serve without.
+func Degraded(check Check) Check {
+	run := check.Run
+	check.Run = func(ctx context.Context) (interface{}, error) {
+		detail, err := run(ctx)
+		return detail, Degrade(err)
+	}
+	return check
+}
+
+// Check is one readiness dependency. Run returns details worth showing in
+// the probe response (may be nil) and an error when the dependency is not
+// usable.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (interface{}, error)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name       string      `json:"name"`
+	Status     string      `json:"status"` // "ok", "degraded" or "fail"
+	DurationMS float64     `json:"duration_ms"`
+	Error      string      `json:"error,omitempty"`
+	Detail     interface{} `json:"detail,omitempty"`
+}
+
+// Report is the readiness response body.
+type Report struct {
+	Status     string    `json:"status"` // "ready", "degraded" or "unavailable"
+	Timestamp  time.Time `json:"timestamp"`
+	DurationMS float64   `json:"duration_ms"`
+	Checks     []Result  `json:"checks"`
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker giving each check at most timeout.
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Drain makes readiness fail from now on, so load balancers stop routing
+// new requests before the server shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs every check concurrently and reports the results in the order
+// the checks were registered.
+func (c *Checker) Ready(ctx context.Context) Report {
+	start := time.Now()
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	if c.draining.Load() {
+		results = append([]Result{{Name: "shutdown", Status: "fail", Error: ErrShuttingDown.Error()}}, results...)
+	}
+
+	report := Report{Status: "ready", Timestamp: start.UTC(), Checks: results}
+	for _, r := range results {
+		switch {
+		case r.Status == "fail":
+			report.Status = "unavailable"
+		case r.Status == "degraded" && report.Status == "ready":
+			report.Status = "degraded"
+		}
+	}
+	report.DurationMS = millis(time.Since(start))
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check.Run(ctx)
+	result := Result{Name: check.Name, Status: "ok", DurationMS: millis(time.Since(start)), Detail: detail}
+	if err != nil {
+		result.Status = "fail"
+		if errors.As(err, new(degradedError)) {
+			result.Status = "degraded"
+		}
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// HandleLive answers the liveness probe. It has no dependencies: a process
+// that can serve it should not be restarted.
+func HandleLive() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":    "alive",
+			"timestamp": time.Now().UTC(),
+		})
+	}
+}
+
+// HandleReady answers the readiness probe: 200 when no check failed
+// (degraded checks allowed), 503 otherwise.
+func (c *Checker) HandleReady() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Ready(r.Context())
+		status := http.StatusOK
+		if report.Status == "unavailable" {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// millis converts d to fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,142 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+)
+
+func okCheck(name string) Check {
+	return Check{Name: name, Run: func(context.Context) (interface{}, error) {
+		return map[string]string{"name": name}, nil
+	}}
+}
+
+func TestHandleReady(t *testing.T) {
+	failing := Check{Name: "database", Run: func(context.Context) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	}}
+	degraded := Degraded(failing)
+	slow := Check{Name: "slow", Run: func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		drain      bool
+		wantStatus int
+		wantReport string
+		wantChecks map[string]string
+	}{
+		{"all pass", []Check{okCheck("database"), okCheck("snapshot")}, false, http.StatusOK, "ready",
+			map[string]string{"database": "ok", "snapshot": "ok"}},
+		{"one fails", []Check{failing, okCheck("snapshot")}, false, http.StatusServiceUnavailable, "unavailable",
+			map[string]string{"database": "fail", "snapshot": "ok"}},
+		{"degraded still ready", []Check{degraded, okCheck("snapshot")}, false, http.StatusOK, "degraded",
+			map[string]string{"database": "degraded", "snapshot": "ok"}},
+		{"failure outranks degraded", []Check{degraded, slow}, false, http.StatusServiceUnavailable, "unavailable",
+			map[string]string{"database": "degraded", "slow": "fail"}},
+		{"check times out", []Check{slow}, false, http.StatusServiceUnavailable, "unavailable",
+			map[string]string{"slow": "fail"}},
+		{"draining", []Check{okCheck("database")}, true, http.StatusServiceUnavailable, "unavailable",
+			map[string]string{"shutdown": "fail", "database": "ok"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(20*time.Millisecond, tt.checks...)
+			if tt.drain {
+				checker.Drain()
+			}
+
+			w := httptest.NewRecorder()
+			checker.HandleReady()(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var report Report
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			got := make(map[string]string, len(report.Checks))
+			for _, r := range report.Checks {
+				got[r.Name] = r.Status
+				assert.GreaterOrEqual(t, r.DurationMS, 0.0)
+				if r.Status != "ok" {
+					assert.NotEmpty(t, r.Error)
+				}
+			}
+			assert.Equal(t, tt.wantChecks, got)
+			assert.Equal(t, tt.wantReport, report.Status)
+		})
+	}
+}
+
+func TestHandleLive(t *testing.T) {
+	w := httptest.NewRecorder()
+	HandleLive()(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"alive"`)
+}
+
+func TestSnapshotAge(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	_, err := snapshotAge(nil, time.Minute, now)
+	assert.ErrorContains(t, err, "not loaded")
+
+	fresh := &campaigns.Snapshot{LoadedAt: now.Add(-10 * time.Second)}
+	detail, err := snapshotAge(fresh, time.Minute, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "10s", detail.(map[string]interface{})["age"])
+
+	stale := &campaigns.Snapshot{LoadedAt: now.Add(-2 * time.Minute)}
+	_, err = snapshotAge(stale, time.Minute, now)
+	assert.ErrorContains(t, err, "2m0s old")
+}
+
+func TestSchemaVersion(t *testing.T) {
+	tests := []struct {
+		have, want int
+		wantErr    bool
+	}{
+		{have: 1, want: 1},
+		{have: 2, want: 1}, // applied by a newer replica
+		{have: 0, want: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := schemaVersion(tt.have, tt.want)
+		assert.Equal(t, tt.wantErr, err != nil, "have %d want %d", tt.have, tt.want)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	require.NoError(t, err)
+	defer db.Close()
+	keys := auth.NewKeyring(db, "bootstrap-secret")
+
+	var degraded degradedError
+	_, err = Keys(keys).Run(context.Background())
+	assert.ErrorContains(t, err, "have not loaded")
+	assert.ErrorAs(t, err, &degraded)
+
+	// A server started with the database down serves degraded
+	require.Error(t, keys.Refresh())
+	report := NewChecker(time.Second, okCheck("snapshot"), Keys(keys)).Ready(context.Background())
+	assert.Equal(t, "degraded", report.Status)
+	assert.Equal(t, "degraded", report.Checks[1].Status)
+	assert.Contains(t, report.Checks[1].Error, "API keys not refreshed")
+}
@@ -0,0 +1,126 @@
+// Package history describes versions of a campaign and its targeting rules
+// and computes the difference between two versions.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Document is the versioned state of a campaign: the campaign row, bid
+// included, and its targeting rules.
+type Document struct {
+	Campaign models.CampaignRecord  `json:"campaign"`
+	Rules    []models.TargetingRule `json:"rules"`
+}
+
+// Version is one entry of a campaign's append-only history. Before is nil
+// when the change created the campaign, After when it deleted it.
+type Version struct {
+	CampaignID string    `json:"cid"`
+	Version    int       `json:"version"`
+	Actor      string    `json:"actor"`
+	Change     string    `json:"change"`
+	Diff       []Change  `json:"diff"`
+	Before     *Document `json:"before,omitempty"`
+	After      *Document `json:"after,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// Change is one field that differs between two documents. Path uses JSON
+// names, e.g. "campaign.bid_cpm" or "rules[0].include_country".
+type Change struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff returns the fields that differ between before and after, sorted by
+// path. A nil before is treated as an empty document. Lists of plain values
+// are compared as a whole; lists of objects (rules, geofences) element by
+// element.
+func Diff(before, after *Document) ([]Change, error) {
+	old, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	for path, v := range cur {
+		if o, ok := old[path]; !ok || !reflect.DeepEqual(o, v) {
+			changes = append(changes, Change{Path: path, Before: o, After: v})
+		}
+	}
+	for path, o := range old {
+		if _, ok := cur[path]; !ok {
+			changes = append(changes, Change{Path: path, Before: o})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// flatten maps each leaf path of doc's JSON form to its value. Null leaves
+// are dropped, so a missing and a null field compare equal.
+func flatten(doc *Document) (map[string]interface{}, error) {
+	leaves := make(map[string]interface{})
+	if doc == nil {
+		return leaves, nil
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	walk("", v, leaves)
+	return leaves, nil
+}
+
+func walk(path string, v interface{}, leaves map[string]interface{}) {
+	switch v := v.(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, child := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			walk(p, child, leaves)
+		}
+	case []interface{}:
+		if !objects(v) {
+			leaves[path] = v
+			return
+		}
+		for i, child := range v {
+			walk(fmt.Sprintf("%s[%d]", path, i), child, leaves)
+		}
+	default:
+		leaves[path] = v
+	}
+}
+
+// objects reports whether list is non-empty and holds only JSON objects.
+func objects(list []interface{}) bool {
+	if len(list) == 0 {
+		return false
+	}
+	for _, v := range list {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func document() *Document {
+	return &Document{
+		Campaign: models.CampaignRecord{
+			Campaign: models.Campaign{ID: "spotify", Name: "Spotify", Status: "ACTIVE"},
+			BidCPM:   2.5,
+		},
+		Rules: []models.TargetingRule{
+			{CampaignID: "spotify", IncludeCountry: []string{"us", "ca"}},
+		},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(d *Document)
+		expected []Change
+	}{
+		{
+			name:     "No change",
+			edit:     func(d *Document) {},
+			expected: []Change{},
+		},
+		{
+			name: "Campaign field",
+			edit: func(d *Document) { d.Campaign.Status = "PAUSED" },
+			expected: []Change{
+				{Path: "campaign.status", Before: "ACTIVE", After: "PAUSED"},
+			},
+		},
+		{
+			name: "Cleared field",
+			edit: func(d *Document) { d.Campaign.BidCPM = 0 },
+			expected: []Change{
+				{Path: "campaign.bid_cpm", Before: 2.5},
+			},
+		},
+		{
+			name: "Rule list compared as a whole",
+			edit: func(d *Document) { d.Rules[0].IncludeCountry = []string{"us"} },
+			expected: []Change{
+				{Path: "rules[0].include_country", Before: []interface{}{"us", "ca"}, After: []interface{}{"us"}},
+			},
+		},
+		{
+			name: "Rule added",
+			edit: func(d *Document) {
+				d.Rules = append(d.Rules, models.TargetingRule{CampaignID: "spotify", IncludeOS: []string{"ios"}})
+			},
+			expected: []Change{
+				{Path: "rules[1].campaign_id", After: "spotify"},
+				{Path: "rules[1].include_os", After: []interface{}{"ios"}},
+			},
+		},
+		{
+			name: "Geofences compared element by element",
+			edit: func(d *Document) {
+				d.Rules[0].IncludeGeofence = []models.Geofence{{Lat: 12.9, Lon: 77.6, RadiusKm: 5}}
+			},
+			expected: []Change{
+				{Path: "rules[0].include_geofence[0].lat", After: 12.9},
+				{Path: "rules[0].include_geofence[0].lon", After: 77.6},
+				{Path: "rules[0].include_geofence[0].radius_km", After: 5.0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := document()
+			tt.edit(after)
+			changes, err := Diff(document(), after)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, changes)
+		})
+	}
+}
+
+func TestDiffCreatedAndDeleted(t *testing.T) {
+	created, err := Diff(nil, document())
+	require.NoError(t, err)
+	paths := []string{}
+	for _, c := range created {
+		assert.Nil(t, c.Before)
+		paths = append(paths, c.Path)
+	}
+	assert.Equal(t, []string{"campaign.bid_cpm", "campaign.cid", "campaign.cta", "campaign.img",
+		"campaign.name", "campaign.status", "rules[0].campaign_id", "rules[0].include_country"}, paths)
+
+	deleted, err := Diff(document(), nil)
+	require.NoError(t, err)
+	assert.Len(t, deleted, len(created))
+	for _, c := range deleted {
+		assert.Nil(t, c.After)
+	}
+}
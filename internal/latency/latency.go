@@ -0,0 +1,118 @@
+// Package latency summarises request latencies as percentiles.
+package latency
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Recorder collects latency samples. It is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+// Observe adds one sample.
+func (r *Recorder) Observe(d time.Duration) {
+	r.mu.Lock()
+	r.samples = append(r.samples, d)
+	r.mu.Unlock()
+}
+
+// Summary returns the percentiles of the samples observed so far.
+func (r *Recorder) Summary() Summary {
+	r.mu.Lock()
+	samples := append([]time.Duration(nil), r.samples...)
+	r.mu.Unlock()
+	return Summarize(samples)
+}
+
+// Summary describes a latency distribution. It is encoded in JSON with
+// every duration in milliseconds.
+type Summary struct {
+	Count int
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Summarize computes the nearest-rank percentiles of samples, which it
+// sorts in place.
+func Summarize(samples []time.Duration) Summary {
+	if len(samples) == 0 {
+		return Summary{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	var total time.Duration
+	for _, d := range samples {
+		total += d
+	}
+	return Summary{
+		Count: len(samples),
+		Mean:  total / time.Duration(len(samples)),
+		P50:   percentile(samples, 50),
+		P90:   percentile(samples, 90),
+		P99:   percentile(samples, 99),
+		Max:   samples[len(samples)-1],
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+type summaryJSON struct {
+	Count  int     `json:"count"`
+	MeanMS float64 `json:"mean_ms"`
+	P50MS  float64 `json:"p50_ms"`
+	P90MS  float64 `json:"p90_ms"`
+	P99MS  float64 `json:"p99_ms"`
+	MaxMS  float64 `json:"max_ms"`
+}
+
+func (s Summary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(summaryJSON{
+		Count:  s.Count,
+		MeanMS: Milliseconds(s.Mean),
+		P50MS:  Milliseconds(s.P50),
+		P90MS:  Milliseconds(s.P90),
+		P99MS:  Milliseconds(s.P99),
+		MaxMS:  Milliseconds(s.Max),
+	})
+}
+
+func (s *Summary) UnmarshalJSON(data []byte) error {
+	var v summaryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Summary{
+		Count: v.Count,
+		Mean:  fromMilliseconds(v.MeanMS),
+		P50:   fromMilliseconds(v.P50MS),
+		P90:   fromMilliseconds(v.P90MS),
+		P99:   fromMilliseconds(v.P99MS),
+		Max:   fromMilliseconds(v.MaxMS),
+	}
+	return nil
+}
+
+// Milliseconds returns d in fractional milliseconds, rounded to the
+// microsecond.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,69 @@
+package latency
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	hundred := make([]time.Duration, 100)
+	for i := range hundred {
+		hundred[i] = ms(100 - i)
+	}
+
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    Summary
+	}{
+		{name: "empty", want: Summary{}},
+		{name: "single sample", samples: []time.Duration{ms(7)},
+			want: Summary{Count: 1, Mean: ms(7), P50: ms(7), P90: ms(7), P99: ms(7), Max: ms(7)}},
+		{name: "unsorted", samples: []time.Duration{ms(4), ms(1), ms(3), ms(2)},
+			want: Summary{Count: 4, Mean: 2500 * time.Microsecond, P50: ms(2), P90: ms(4), P99: ms(4), Max: ms(4)}},
+		{name: "one to a hundred", samples: hundred,
+			want: Summary{Count: 100, Mean: 50500 * time.Microsecond, P50: ms(50), P90: ms(90), P99: ms(99), Max: ms(100)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Summarize(tt.samples))
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var r Recorder
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(d time.Duration) {
+			defer wg.Done()
+			r.Observe(d)
+		}(time.Duration(i) * time.Millisecond)
+	}
+	wg.Wait()
+
+	s := r.Summary()
+	assert.Equal(t, 10, s.Count)
+	assert.Equal(t, 5*time.Millisecond, s.P50)
+	assert.Equal(t, 10*time.Millisecond, s.Max)
+}
+
+func TestSummaryJSON(t *testing.T) {
+	s := Summary{Count: 3, Mean: 1500 * time.Microsecond, P50: time.Millisecond, P90: 2 * time.Millisecond, P99: 2 * time.Millisecond, Max: 2 * time.Millisecond}
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"count":3,"mean_ms":1.5,"p50_ms":1,"p90_ms":2,"p99_ms":2,"max_ms":2}`, string(data))
+
+	var decoded Summary
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, s, decoded)
+}
@@ -0,0 +1,114 @@
+// Package lifecycle defines the campaign status state machine:
+//
+//	DRAFT → PENDING_REVIEW → APPROVED → ACTIVE ↔ PAUSED → COMPLETED → ARCHIVED
+//
+// Only ACTIVE campaigns are eligible for delivery.
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Campaign statuses.
+const (
+	StatusDraft         = "DRAFT"
+	StatusPendingReview = "PENDING_REVIEW"
+	StatusApproved      = "APPROVED"
+	StatusActive        = "ACTIVE"
+	StatusPaused        = "PAUSED"
+	StatusCompleted     = "COMPLETED"
+	StatusArchived      = "ARCHIVED"
+)
+
+// ErrInvalidTransition is returned for an action not allowed from the
+// campaign's current status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// Action is a named transition exposed by the admin API.
+type Action string
+
+const (
+	ActionSubmit   Action = "submit"
+	ActionApprove  Action = "approve"
+	ActionReject   Action = "reject"
+	ActionActivate Action = "activate"
+	ActionPause    Action = "pause"
+	ActionResume   Action = "resume"
+	ActionComplete Action = "complete"
+	ActionArchive  Action = "archive"
+)
+
+// ActionImport is recorded when a bulk import creates a campaign in a
+// status other than DRAFT. It is not an action clients can request.
+const ActionImport Action = "import"
+
+// transitions maps each action to the statuses it may start from and the
+// status it leads to.
+var transitions = map[Action]struct {
+	from []string
+	to   string
+}{
+	ActionSubmit:   {[]string{StatusDraft}, StatusPendingReview},
+	ActionApprove:  {[]string{StatusPendingReview}, StatusApproved},
+	ActionReject:   {[]string{StatusPendingReview}, StatusDraft},
+	ActionActivate: {[]string{StatusApproved}, StatusActive},
+	ActionPause:    {[]string{StatusActive}, StatusPaused},
+	ActionResume:   {[]string{StatusPaused}, StatusActive},
+	ActionComplete: {[]string{StatusActive, StatusPaused}, StatusCompleted},
+	ActionArchive:  {[]string{StatusDraft, StatusApproved, StatusPaused, StatusCompleted}, StatusArchived},
+}
+
+// Transition records one status change of a campaign.
+type Transition struct {
+	CampaignID string    `json:"cid"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Action     Action    `json:"action"`
+	Actor      string    `json:"actor"`
+	Reason     string    `json:"reason,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// ParseStatus validates a status name, ignoring case.
+func ParseStatus(s string) (string, error) {
+	switch st := strings.ToUpper(strings.TrimSpace(s)); st {
+	case StatusDraft, StatusPendingReview, StatusApproved, StatusActive,
+		StatusPaused, StatusCompleted, StatusArchived:
+		return st, nil
+	default:
+		return "", fmt.Errorf("unknown campaign status %q", s)
+	}
+}
+
+// ParseAction validates an action name, ignoring case.
+func ParseAction(s string) (Action, error) {
+	a := Action(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := transitions[a]; !ok {
+		return "", fmt.Errorf("unknown campaign action %q", s)
+	}
+	return a, nil
+}
+
+// NeedsReviewer reports whether a performs the review step, which only
+// global (non-tenant) keys may do.
+func (a Action) NeedsReviewer() bool {
+	return a == ActionApprove || a == ActionReject
+}
+
+// Next returns the status a campaign in status from moves to on action a, or
+// an error if a is not allowed from there.
+func Next(from string, a Action) (string, error) {
+	t, ok := transitions[a]
+	if !ok {
+		return "", fmt.Errorf("unknown campaign action %q", a)
+	}
+	for _, s := range t.from {
+		if s == from {
+			return t.to, nil
+		}
+	}
+	return "", fmt.Errorf("%w: cannot %s a campaign in status %s", ErrInvalidTransition, a, from)
+}
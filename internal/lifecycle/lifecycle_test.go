@@ -0,0 +1,69 @@
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		from     string
+		action   Action
+		expected string // "" when the transition is not allowed
+	}{
+		{StatusDraft, ActionSubmit, StatusPendingReview},
+		{StatusPendingReview, ActionApprove, StatusApproved},
+		{StatusPendingReview, ActionReject, StatusDraft},
+		{StatusApproved, ActionActivate, StatusActive},
+		{StatusActive, ActionPause, StatusPaused},
+		{StatusPaused, ActionResume, StatusActive},
+		{StatusActive, ActionComplete, StatusCompleted},
+		{StatusPaused, ActionComplete, StatusCompleted},
+		{StatusCompleted, ActionArchive, StatusArchived},
+		{StatusDraft, ActionArchive, StatusArchived},
+		{StatusDraft, ActionActivate, ""},
+		{StatusPendingReview, ActionActivate, ""},
+		{StatusActive, ActionApprove, ""},
+		{StatusActive, ActionArchive, ""},
+		{StatusCompleted, ActionResume, ""},
+		{StatusArchived, ActionSubmit, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"/"+string(tt.action), func(t *testing.T) {
+			got, err := Next(tt.from, tt.action)
+			if tt.expected == "" {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	a, err := ParseAction(" Approve ")
+	require.NoError(t, err)
+	assert.Equal(t, ActionApprove, a)
+	assert.True(t, a.NeedsReviewer())
+	assert.False(t, ActionPause.NeedsReviewer())
+
+	_, err = ParseAction("delete")
+	assert.EqualError(t, err, `unknown campaign action "delete"`)
+
+	// Only recorded by imports, never requested
+	_, err = ParseAction("import")
+	assert.EqualError(t, err, `unknown campaign action "import"`)
+}
+
+func TestParseStatus(t *testing.T) {
+	s, err := ParseStatus(" pending_review ")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPendingReview, s)
+
+	_, err = ParseStatus("INACTIVE")
+	assert.EqualError(t, err, `unknown campaign status "INACTIVE"`)
+}
@@ -0,0 +1,79 @@
+package loadgen
+
+import (
+	"fmt"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/latency"
+)
+
+// Delta compares one measure of a run with a baseline run.
+type Delta struct {
+	Metric   string  `json:"metric"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	// Change is relative to the baseline: 0.1 is 10% more
+	Change float64 `json:"change"`
+	// Regressed is set when the measure got worse by more than the
+	// tolerance passed to Compare
+	Regressed bool `json:"regressed"`
+}
+
+// ErrorRate returns the share of requests that failed.
+func (r *Report) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors()) / float64(r.Requests)
+}
+
+// Compare reports how current differs from baseline. A measure regressed
+// when it moved the wrong way by more than tolerance, relative to the
+// baseline (0.1 allows 10%). Error rates regress on any increase above
+// tolerance in absolute terms.
+func Compare(baseline, current *Report, tolerance float64) []Delta {
+	lowerIsBetter := func(metric string, b, c float64) Delta {
+		d := Delta{Metric: metric, Baseline: b, Current: c}
+		if b > 0 {
+			d.Change = (c - b) / b
+			d.Regressed = d.Change > tolerance
+		}
+		return d
+	}
+	ms := latency.Milliseconds
+
+	throughput := Delta{Metric: "throughput_rps", Baseline: baseline.Throughput, Current: current.Throughput}
+	if baseline.Throughput > 0 {
+		throughput.Change = (current.Throughput - baseline.Throughput) / baseline.Throughput
+		throughput.Regressed = -throughput.Change > tolerance
+	}
+	errorRate := Delta{Metric: "error_rate", Baseline: baseline.ErrorRate(), Current: current.ErrorRate()}
+	if errorRate.Baseline > 0 {
+		errorRate.Change = (errorRate.Current - errorRate.Baseline) / errorRate.Baseline
+	}
+	errorRate.Regressed = errorRate.Current-errorRate.Baseline > tolerance
+
+	return []Delta{
+		throughput,
+		lowerIsBetter("mean_ms", ms(baseline.Latency.Mean), ms(current.Latency.Mean)),
+		lowerIsBetter("p50_ms", ms(baseline.Latency.P50), ms(current.Latency.P50)),
+		lowerIsBetter("p90_ms", ms(baseline.Latency.P90), ms(current.Latency.P90)),
+		lowerIsBetter("p99_ms", ms(baseline.Latency.P99), ms(current.Latency.P99)),
+		errorRate,
+	}
+}
+
+// Mismatches lists the settings that differ between two runs, which makes
+// their numbers hard to compare.
+func Mismatches(baseline, current *Report) []string {
+	var out []string
+	check := func(name string, b, c interface{}) {
+		if b != c {
+			out = append(out, fmt.Sprintf("%s: %v → %v", name, b, c))
+		}
+	}
+	check("api", baseline.API, current.API)
+	check("source", baseline.Source, current.Source)
+	check("seed", baseline.Seed, current.Seed)
+	check("concurrency", baseline.Concurrency, current.Concurrency)
+	return out
+}
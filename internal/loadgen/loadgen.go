@@ -0,0 +1,235 @@
+// Package loadgen drives delivery endpoints with concurrent synthetic or
+// recorded traffic and reports throughput and latency percentiles. Reports
+// are JSON so that runs can be kept and compared over time.
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/latency"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/replay"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/synth"
+)
+
+// StatusError is the status of requests that got no HTTP response.
+const StatusError = "error"
+
+// Source creates the request stream of each worker.
+type Source func(worker int) func() models.DeliveryRequest
+
+// Synthetic draws requests from d. Worker w uses seed+w, so a run with the
+// same seed and concurrency sends the same requests.
+func Synthetic(d synth.Distribution, seed uint64) Source {
+	return func(worker int) func() models.DeliveryRequest {
+		return synth.NewGenerator(d, seed+uint64(worker)).Next
+	}
+}
+
+// Recorded cycles through reqs; each worker starts at a different offset.
+func Recorded(reqs []models.DeliveryRequest) Source {
+	return func(worker int) func() models.DeliveryRequest {
+		i := worker * 7919
+		return func() models.DeliveryRequest {
+			req := reqs[i%len(reqs)]
+			i++
+			return req
+		}
+	}
+}
+
+// ReadCapture returns the requests of a capture file written by the
+// server's traffic capture.
+func ReadCapture(r io.Reader) ([]models.DeliveryRequest, error) {
+	records := replay.NewReader(r)
+	var reqs []models.DeliveryRequest
+	for {
+		rec, _, err := records.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, rec.Request)
+	}
+	if len(reqs) == 0 {
+		return nil, errors.New("capture has no records")
+	}
+	return reqs, nil
+}
+
+// Config describes a run.
+type Config struct {
+	// URL is the base URL of the server, e.g. http://localhost:8080
+	URL string
+	// API is the delivery API version, v1 or v2
+	API    string
+	APIKey string
+	// Concurrency is the number of workers, each with one request in flight
+	Concurrency int
+	// Duration bounds the run unless Requests is set
+	Duration time.Duration
+	// Requests is the total number of requests to send, if not 0
+	Requests int
+	// Source is recorded in the report, e.g. "synthetic" or a capture path
+	Source string
+	Seed   uint64
+}
+
+// Validate checks that c describes a run.
+func (c Config) Validate() error {
+	var errs []error
+	if c.URL == "" {
+		errs = append(errs, errors.New("url is required"))
+	}
+	if c.API != "v1" && c.API != "v2" {
+		errs = append(errs, fmt.Errorf("api must be v1 or v2, got %q", c.API))
+	}
+	if c.Concurrency < 1 {
+		errs = append(errs, errors.New("concurrency must be at least 1"))
+	}
+	if c.Requests < 0 {
+		errs = append(errs, errors.New("requests must not be negative"))
+	}
+	if c.Requests == 0 && c.Duration <= 0 {
+		errs = append(errs, errors.New("either requests or a positive duration is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Started     time.Time `json:"started"`
+	URL         string    `json:"url"`
+	API         string    `json:"api"`
+	Source      string    `json:"source"`
+	Seed        uint64    `json:"seed"`
+	Concurrency int       `json:"concurrency"`
+	// Elapsed is the wall time of the run in seconds
+	Elapsed  float64 `json:"elapsed_seconds"`
+	Requests int     `json:"requests"`
+	// Throughput is completed requests per second
+	Throughput float64 `json:"throughput_rps"`
+	// Status counts responses by HTTP status code, and StatusError
+	Status map[string]int `json:"status"`
+	// FillRate is the share of answered requests (200 or 204) that got a
+	// campaign
+	FillRate float64         `json:"fill_rate"`
+	Latency  latency.Summary `json:"latency"`
+}
+
+// Errors returns the number of requests that failed: no response or a
+// status other than 200 and 204.
+func (r *Report) Errors() int {
+	n := r.Requests
+	for _, code := range []int{http.StatusOK, http.StatusNoContent} {
+		n -= r.Status[strconv.Itoa(code)]
+	}
+	return n
+}
+
+// worker holds what one worker observed.
+type worker struct {
+	samples []time.Duration
+	status  map[string]int
+}
+
+// Run sends requests from src to the delivery endpoint of cfg.API until
+// cfg.Requests have been sent, cfg.Duration has passed or ctx ends.
+// Requests cut short by the end of the run are not counted.
+func Run(ctx context.Context, client *http.Client, cfg Config, src Source) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Requests == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+	endpoint := strings.TrimSuffix(cfg.URL, "/") + "/" + cfg.API + "/delivery"
+
+	var sent atomic.Int64
+	workers := make([]worker, cfg.Concurrency)
+	started := time.Now()
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func(w *worker, next func() models.DeliveryRequest) {
+			defer wg.Done()
+			w.status = map[string]int{}
+			for ctx.Err() == nil {
+				if cfg.Requests > 0 && sent.Add(1) > int64(cfg.Requests) {
+					return
+				}
+				start := time.Now()
+				status := send(ctx, client, endpoint, cfg.APIKey, next())
+				if ctx.Err() != nil {
+					return
+				}
+				w.samples = append(w.samples, time.Since(start))
+				w.status[status]++
+			}
+		}(&workers[w], src(w))
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+
+	report := &Report{
+		Started:     started.UTC(),
+		URL:         cfg.URL,
+		API:         cfg.API,
+		Source:      cfg.Source,
+		Seed:        cfg.Seed,
+		Concurrency: cfg.Concurrency,
+		Elapsed:     elapsed.Seconds(),
+		Status:      map[string]int{},
+	}
+	var samples []time.Duration
+	for _, w := range workers {
+		samples = append(samples, w.samples...)
+		for status, n := range w.status {
+			report.Status[status] += n
+		}
+	}
+	report.Requests = len(samples)
+	report.Latency = latency.Summarize(samples)
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+	filled := report.Status[strconv.Itoa(http.StatusOK)]
+	if answered := filled + report.Status[strconv.Itoa(http.StatusNoContent)]; answered > 0 {
+		report.FillRate = float64(filled) / float64(answered)
+	}
+	return report, nil
+}
+
+// send makes one delivery request and returns its status.
+func send(ctx context.Context, client *http.Client, endpoint, apiKey string, req models.DeliveryRequest) string {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+replay.Query(req).Encode(), nil)
+	if err != nil {
+		return StatusError
+	}
+	if apiKey != "" {
+		httpReq.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return StatusError
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection is reused
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return StatusError
+	}
+	return strconv.Itoa(resp.StatusCode)
+}
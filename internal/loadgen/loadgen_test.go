@@ -0,0 +1,187 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/latency"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/replay"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/synth"
+)
+
+// deliveryServer answers us with a campaign, in with no content and
+// anything else with 400, and records the requests it got.
+type deliveryServer struct {
+	mu    sync.Mutex
+	paths map[string]int
+}
+
+func (s *deliveryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.paths[r.URL.Path]++
+	s.mu.Unlock()
+	if r.Header.Get("X-API-Key") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Query().Get("country") {
+	case "us":
+		w.Write([]byte(`[{"cid":"spotify"}]`))
+	case "in":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newDeliveryServer(t *testing.T) (*deliveryServer, *httptest.Server) {
+	s := &deliveryServer{paths: map[string]int{}}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func TestRun(t *testing.T) {
+	s, srv := newDeliveryServer(t)
+	src := Recorded([]models.DeliveryRequest{
+		{App: "com.test", Country: "us", OS: "android"},
+		{App: "com.test", Country: "us", OS: "android"},
+		{App: "com.test", Country: "in", OS: "android"},
+		{App: "com.test", Country: "de", OS: "android"},
+	})
+
+	report, err := Run(context.Background(), srv.Client(), Config{
+		URL:         srv.URL + "/",
+		API:         "v2",
+		APIKey:      "secret",
+		Concurrency: 1,
+		Requests:    8,
+		Source:      "test",
+	}, src)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"/v2/delivery": 8}, s.paths)
+	assert.Equal(t, 8, report.Requests)
+	assert.Equal(t, map[string]int{"200": 4, "204": 2, "400": 2}, report.Status)
+	assert.Equal(t, 2, report.Errors())
+	assert.InDelta(t, 0.25, report.ErrorRate(), 1e-9)
+	assert.InDelta(t, 4.0/6, report.FillRate, 1e-9)
+	assert.Equal(t, 8, report.Latency.Count)
+	assert.Positive(t, report.Throughput)
+	assert.Equal(t, "test", report.Source)
+	assert.Equal(t, "v2", report.API)
+}
+
+func TestRunConcurrentRequestBudget(t *testing.T) {
+	s, srv := newDeliveryServer(t)
+	d := synth.Distribution{
+		Apps:      []synth.Weighted{{Value: "com.test", Weight: 1}},
+		Countries: []synth.Weighted{{Value: "us", Weight: 1}},
+		OSes:      []synth.Weighted{{Value: "ios", Weight: 1}},
+	}
+
+	report, err := Run(context.Background(), srv.Client(), Config{
+		URL: srv.URL, API: "v1", APIKey: "secret", Concurrency: 8, Requests: 100,
+	}, Synthetic(d, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 100, report.Requests)
+	assert.Equal(t, map[string]int{"200": 100}, report.Status)
+	assert.Equal(t, map[string]int{"/v1/delivery": 100}, s.paths)
+}
+
+func TestRunForDuration(t *testing.T) {
+	_, srv := newDeliveryServer(t)
+	src := Recorded([]models.DeliveryRequest{{App: "com.test", Country: "us", OS: "android"}})
+
+	start := time.Now()
+	report, err := Run(context.Background(), srv.Client(), Config{
+		URL: srv.URL, API: "v2", Concurrency: 2, Duration: 100 * time.Millisecond,
+	}, src)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Positive(t, report.Requests)
+	assert.Equal(t, report.Requests, report.Status["401"], "no key, and no requests cut short by the deadline")
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{URL: "http://localhost:8080", API: "v1", Concurrency: 1, Duration: time.Second}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{name: "requests instead of duration", modify: func(c *Config) { c.Duration, c.Requests = 0, 10 }},
+		{name: "no url", modify: func(c *Config) { c.URL = "" }, wantErr: "url is required"},
+		{name: "unknown api", modify: func(c *Config) { c.API = "v3" }, wantErr: "api must be v1 or v2"},
+		{name: "no workers", modify: func(c *Config) { c.Concurrency = 0 }, wantErr: "concurrency"},
+		{name: "unbounded", modify: func(c *Config) { c.Duration = 0 }, wantErr: "either requests or a positive duration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+			err := c.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestReadCapture(t *testing.T) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	us := models.DeliveryRequest{App: "com.test", Country: "us", OS: "android"}
+	in := models.DeliveryRequest{App: "com.test", Country: "in", OS: "ios"}
+	require.NoError(t, enc.Encode(replay.Record{API: "v1", Request: us}))
+	require.NoError(t, enc.Encode(replay.Record{API: "v2", Request: in}))
+
+	reqs, err := ReadCapture(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeliveryRequest{us, in}, reqs)
+
+	_, err = ReadCapture(&bytes.Buffer{})
+	assert.ErrorContains(t, err, "no records")
+}
+
+func TestCompare(t *testing.T) {
+	baseline := &Report{
+		API: "v2", Source: "synthetic", Seed: 1, Concurrency: 8,
+		Requests: 1000, Throughput: 1000, Status: map[string]int{"200": 1000},
+		Latency: latency.Summary{Mean: 4 * time.Millisecond, P50: 3 * time.Millisecond, P90: 8 * time.Millisecond, P99: 20 * time.Millisecond},
+	}
+	current := &Report{
+		API: "v2", Source: "synthetic", Seed: 1, Concurrency: 16,
+		Requests: 1000, Throughput: 850, Status: map[string]int{"200": 990, "500": 10},
+		Latency: latency.Summary{Mean: 4 * time.Millisecond, P50: 3 * time.Millisecond, P90: 8 * time.Millisecond, P99: 30 * time.Millisecond},
+	}
+
+	deltas := map[string]Delta{}
+	for _, d := range Compare(baseline, current, 0.1) {
+		deltas[d.Metric] = d
+	}
+	assert.InDelta(t, -0.15, deltas["throughput_rps"].Change, 1e-9)
+	assert.True(t, deltas["throughput_rps"].Regressed)
+	assert.False(t, deltas["p50_ms"].Regressed)
+	assert.InDelta(t, 0.5, deltas["p99_ms"].Change, 1e-9)
+	assert.True(t, deltas["p99_ms"].Regressed)
+	assert.InDelta(t, 0.01, deltas["error_rate"].Current, 1e-9)
+	assert.False(t, deltas["error_rate"].Regressed, "within the tolerance")
+
+	assert.Equal(t, []string{"concurrency: 8 → 16"}, Mismatches(baseline, current))
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/config"
+)
+
+// records decodes the JSON lines written to buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, config.Log{Level: "warn", Format: "json"})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	logger.InfoContext(ctx, "dropped below level")
+	logger.With("component", "test").WarnContext(ctx, "kept", "key", "value")
+
+	recs := records(t, &buf)
+	require.Len(t, recs, 1)
+	assert.Equal(t, "kept", recs[0]["msg"])
+	assert.Equal(t, "WARN", recs[0]["level"])
+	assert.Equal(t, "req-1", recs[0]["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", recs[0]["trace_id"])
+	assert.Equal(t, "test", recs[0]["component"])
+	assert.Equal(t, "value", recs[0]["key"])
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		sample   bool
+		random   float64
+		status   int
+		wantLogs bool
+	}{
+		{name: "unsampled route always logged", level: "info", random: 0.99, status: http.StatusOK, wantLogs: true},
+		{name: "sampled in", level: "info", sample: true, random: 0.05, status: http.StatusOK, wantLogs: true},
+		{name: "sampled out", level: "info", sample: true, random: 0.5, status: http.StatusNoContent},
+		{name: "bad requests are sampled", level: "info", sample: true, random: 0.5, status: http.StatusBadRequest},
+		{name: "server errors always logged", level: "info", sample: true, random: 0.99, status: http.StatusInternalServerError, wantLogs: true},
+		{name: "debug logs everything", level: "debug", sample: true, random: 0.99, status: http.StatusOK, wantLogs: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(&buf, config.Log{Level: tt.level, Format: "json"})
+
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Use(middlewareWithRand(logger, 0.1, func() float64 { return tt.random }))
+			r.Get("/v1/delivery/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if tt.sample {
+					Sample(r.Context())
+				}
+				AddAttrs(r.Context(), slog.String("app", "com.test"), slog.Int("matches", 2))
+				w.WriteHeader(tt.status)
+				w.Write([]byte("{}"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/delivery/7", nil)
+			req.Header.Set(middleware.RequestIDHeader, "req-1")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			recs := records(t, &buf)
+			if !tt.wantLogs {
+				assert.Empty(t, recs)
+				return
+			}
+			require.Len(t, recs, 1)
+			rec := recs[0]
+			assert.Equal(t, "request", rec["msg"])
+			assert.Equal(t, "req-1", rec["request_id"])
+			assert.Equal(t, "GET", rec["method"])
+			assert.Equal(t, "/v1/delivery/7", rec["path"])
+			assert.Equal(t, "/v1/delivery/{id}", rec["route"])
+			assert.EqualValues(t, tt.status, rec["status"])
+			assert.EqualValues(t, 2, rec["bytes"])
+			assert.Contains(t, rec, "duration_ms")
+			assert.Equal(t, "com.test", rec["app"])
+			assert.EqualValues(t, 2, rec["matches"])
+
+			if tt.status >= http.StatusInternalServerError {
+				assert.Equal(t, "ERROR", rec["level"])
+			}
+			if tt.sample && tt.level == "info" && tt.status < http.StatusInternalServerError {
+				assert.EqualValues(t, 0.1, rec["sample_rate"])
+			} else {
+				assert.NotContains(t, rec, "sample_rate")
+			}
+		})
+	}
+}
+
+func TestAddAttrsOutsideRequest(t *testing.T) {
+	assert.NotPanics(t, func() {
+		AddAttrs(context.Background(), slog.String("app", "x"))
+		Sample(context.Background())
+	})
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type entryKey struct{}
+
+// entry collects the fields handlers add to their request's record.
+type entry struct {
+	mu      sync.Mutex
+	attrs   []slog.Attr
+	sampled bool
+}
+
+// AddAttrs adds fields to the request record written by Middleware. It does
+// nothing outside a request.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.mu.Lock()
+		e.attrs = append(e.attrs, attrs...)
+		e.mu.Unlock()
+	}
+}
+
+// Sample marks the request as high volume: its record is only written for
+// a sampled fraction of requests, unless it failed with a server error.
+func Sample(ctx context.Context) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.mu.Lock()
+		e.sampled = true
+		e.mu.Unlock()
+	}
+}
+
+// Middleware writes one record per request with its method, route, status,
+// size and latency, plus any fields added with AddAttrs. Requests marked
+// with Sample are logged with probability rate, and carry it as
+// sample_rate; at debug level every request is logged. It replaces chi's
+// middleware.Logger and must run after middleware.RequestID.
+func Middleware(logger *slog.Logger, rate float64) func(http.Handler) http.Handler {
+	return middlewareWithRand(logger, rate, rand.Float64)
+}
+
+func middlewareWithRand(logger *slog.Logger, rate float64, random func() float64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			e := &entry{}
+			ctx := context.WithValue(r.Context(), entryKey{}, e)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				e.mu.Lock()
+				defer e.mu.Unlock()
+
+				level := slog.LevelInfo
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+				attrs := []slog.Attr{
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Int("bytes", ww.BytesWritten()),
+					slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+					slog.String("remote_addr", r.RemoteAddr),
+				}
+				if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+					attrs = append(attrs, slog.String("route", rctx.RoutePattern()))
+				}
+				if e.sampled && level < slog.LevelError && !logger.Enabled(ctx, slog.LevelDebug) {
+					if random() >= rate {
+						return
+					}
+					attrs = append(attrs, slog.Float64("sample_rate", rate))
+				}
+				logger.LogAttrs(ctx, level, "request", append(attrs, e.attrs...)...)
+			}()
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+		})
+	}
+}
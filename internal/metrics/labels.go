@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Label values substituted by labelGuard.
+const (
+	OtherLabel   = "other"
+	UnknownLabel = "unknown"
+)
+
+// labelGuard bounds the distinct values of one label: the first max values
+// seen are kept, later ones are reported as OtherLabel. Values are never
+// forgotten, so a series keeps its label for the life of the process.
+type labelGuard struct {
+	name     string
+	max      int
+	overflow *prometheus.CounterVec
+
+	mu   sync.RWMutex
+	seen map[string]struct{}
+}
+
+func newLabelGuard(name string, max int, overflow *prometheus.CounterVec) *labelGuard {
+	return &labelGuard{name: name, max: max, overflow: overflow, seen: make(map[string]struct{})}
+}
+
+// value returns the label value to record v under.
+func (g *labelGuard) value(v string) string {
+	if v == "" {
+		return UnknownLabel
+	}
+	if g.max <= 0 {
+		return v
+	}
+
+	g.mu.RLock()
+	_, ok := g.seen[v]
+	g.mu.RUnlock()
+	if ok {
+		return v
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.seen[v]; ok {
+		return v
+	}
+	if len(g.seen) >= g.max {
+		g.overflow.WithLabelValues(g.name).Inc()
+		return OtherLabel
+	}
+	g.seen[v] = struct{}{}
+	return v
+}
@@ -1,42 +1,395 @@
+// Package metrics defines the Prometheus metrics of the engine. They are
+// held by a Registry, which the server creates, serves on /metrics and
+// passes to the components that record into it.
 package metrics
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	RequestCount = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "delivery_requests_total",
-			Help: "Total number of delivery requests",
-		},
-		[]string{"status"},
-	)
+// Statuses of a delivery request, shared by every API version.
+const (
+	StatusOK         = "ok"
+	StatusNoContent  = "no_content"
+	StatusBadRequest = "bad_request"
+	StatusError      = "error"
+	StatusCancelled  = "cancelled"
+)
 
-	RequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "delivery_request_duration_seconds",
-			Help:    "Duration of delivery request handling in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"status"},
-	)
+// Default label limits; see WithLabelLimits.
+const (
+	DefaultMaxCampaigns = 500
+	DefaultMaxCountries = 50
+	DefaultMaxOS        = 10
+)
+
+// Registry holds the engine's collectors and the Prometheus registry they
+// are registered with. A nil *Registry records nothing.
+type Registry struct {
+	registry *prometheus.Registry
+
+	campaigns *labelGuard
+	countries *labelGuard
+	oses      *labelGuard
+
+	RequestCount       *prometheus.CounterVec
+	RequestDuration    *prometheus.HistogramVec
+	ServedCount        *prometheus.CounterVec
+	FillCount          *prometheus.CounterVec
+	DBQueryDuration    prometheus.Histogram
+	MatchDuration      prometheus.Histogram
+	MatchCandidates    prometheus.Histogram
+	RateLimitedCount   *prometheus.CounterVec
+	AuctionCount       *prometheus.CounterVec
+	ClearingPrice      prometheus.Histogram
+	TrackingEventCount *prometheus.CounterVec
+	StaleResponseCount *prometheus.CounterVec
+	CancelledCount     *prometheus.CounterVec
+	BreakerState       *prometheus.GaugeVec
+	SnapshotRefresh    *prometheus.CounterVec
+	SnapshotDuration   prometheus.Histogram
+	SnapshotLoadedAt   prometheus.Gauge
+	SnapshotCampaigns  prometheus.Gauge
+	SnapshotStale      prometheus.Gauge
+	LabelOverflow      *prometheus.CounterVec
+}
+
+// Option configures a Registry.
+type Option func(*Registry)
+
+// WithLabelLimits caps the distinct campaign, country and OS label values.
+// Values seen after a limit is reached are reported as "other"; a limit of
+// 0 disables it.
+func WithLabelLimits(campaigns, countries, oses int) Option {
+	return func(r *Registry) {
+		r.campaigns.max = campaigns
+		r.countries.max = countries
+		r.oses.max = oses
+	}
+}
+
+// New creates a Registry backed by its own Prometheus registry, which also
+// collects the Go runtime and process metrics.
+func New(opts ...Option) *Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	r := &Registry{
+		registry: reg,
+
+		RequestCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "delivery_requests_total",
+				Help: "Total number of delivery requests by API version and status",
+			},
+			[]string{"api", "status"},
+		),
+
+		RequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "delivery_request_duration_seconds",
+				Help:    "Duration of delivery request handling in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"api", "status"},
+		),
+
+		ServedCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "delivery_served_total",
+				Help: "Total number of times each campaign was returned by delivery",
+			},
+			[]string{"api", "campaign"},
+		),
+
+		FillCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "delivery_fill_total",
+				Help: "Total number of answered delivery requests by country, OS and whether any campaign was returned",
+			},
+			[]string{"api", "country", "os", "filled"},
+		),
+
+		DBQueryDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "db_query_duration_seconds",
+				Help:    "Duration of database queries in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+		),
+
+		MatchDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "matcher_duration_seconds",
+				Help:    "Duration of matching a request against the campaign snapshot in seconds",
+				Buckets: []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01},
+			},
+		),
+
+		MatchCandidates: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "matcher_candidates",
+				Help:    "Number of campaigns matching a request before creative selection and auction",
+				Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100},
+			},
+		),
+
+		RateLimitedCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "rate_limited_requests_total",
+				Help: "Total number of requests rejected by the rate limiter",
+			},
+			[]string{"tier", "subject"},
+		),
+
+		AuctionCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "auctions_total",
+				Help: "Total number of delivery auctions by outcome",
+			},
+			[]string{"outcome"},
+		),
+
+		ClearingPrice: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "auction_clearing_price_cpm",
+				Help:    "Clearing price of won auctions per thousand impressions",
+				Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20},
+			},
+		),
+
+		TrackingEventCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_events_total",
+				Help: "Total number of impression and click tracking calls by event and result",
+			},
+			[]string{"event", "result"},
+		),
+
+		StaleResponseCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "delivery_stale_responses_total",
+				Help: "Total number of delivery responses served from a stale campaign snapshot",
+			},
+			[]string{"api"},
+		),
+
+		CancelledCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "delivery_cancelled_total",
+				Help: "Total number of delivery requests abandoned because their context ended, by reason",
+			},
+			[]string{"api", "reason"},
+		),
+
+		BreakerState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "circuit_breaker_state",
+				Help: "Circuit breaker state: 0 closed, 1 half-open, 2 open",
+			},
+			[]string{"breaker"},
+		),
+
+		SnapshotRefresh: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "snapshot_refreshes_total",
+				Help: "Total number of campaign snapshot refreshes by result",
+			},
+			[]string{"result"},
+		),
+
+		SnapshotDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "snapshot_refresh_duration_seconds",
+				Help:    "Duration of loading the campaign snapshot from the database in seconds",
+				Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+		),
+
+		SnapshotLoadedAt: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "snapshot_loaded_timestamp_seconds",
+				Help: "Unix time the current campaign snapshot was read from the database",
+			},
+		),
 
-	DBQueryDuration = promauto.NewHistogram(
-		prometheus.HistogramOpts{
-			Name:    "db_query_duration_seconds",
-			Help:    "Duration of database queries in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
+		SnapshotCampaigns: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "snapshot_campaigns",
+				Help: "Number of active campaigns in the current snapshot",
+			},
+		),
+
+		SnapshotStale: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "snapshot_stale",
+				Help: "1 while the current campaign snapshot may be out of date, 0 otherwise",
+			},
+		),
+
+		LabelOverflow: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "metrics_label_overflow_total",
+				Help: "Total number of observations whose label value was reported as other because of a cardinality limit",
+			},
+			[]string{"label"},
+		),
+	}
+	r.campaigns = newLabelGuard("campaign", DefaultMaxCampaigns, r.LabelOverflow)
+	r.countries = newLabelGuard("country", DefaultMaxCountries, r.LabelOverflow)
+	r.oses = newLabelGuard("os", DefaultMaxOS, r.LabelOverflow)
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	reg.MustRegister(
+		r.RequestCount, r.RequestDuration, r.ServedCount, r.FillCount,
+		r.DBQueryDuration, r.MatchDuration, r.MatchCandidates,
+		r.RateLimitedCount, r.AuctionCount, r.ClearingPrice, r.TrackingEventCount,
+		r.StaleResponseCount, r.CancelledCount, r.BreakerState,
+		r.SnapshotRefresh, r.SnapshotDuration, r.SnapshotLoadedAt,
+		r.SnapshotCampaigns, r.SnapshotStale, r.LabelOverflow,
 	)
-)
+	return r
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{Registry: r.registry})
+}
+
+// ObserveRequest records a delivery request of API version api (v1, v2)
+// ending with status, one of the Status constants.
+func (r *Registry) ObserveRequest(api, status string, seconds float64) {
+	if r == nil {
+		return
+	}
+	r.RequestCount.WithLabelValues(api, status).Inc()
+	r.RequestDuration.WithLabelValues(api, status).Observe(seconds)
+}
+
+// ObserveServed records an answered delivery request: its fill by country
+// and OS, and one serve of each returned campaign.
+func (r *Registry) ObserveServed(api, country, os string, campaigns []string) {
+	if r == nil {
+		return
+	}
+	filled := "false"
+	if len(campaigns) > 0 {
+		filled = "true"
+	}
+	r.FillCount.WithLabelValues(api, r.countries.value(country), r.oses.value(os), filled).Inc()
+	for _, id := range campaigns {
+		r.ServedCount.WithLabelValues(api, r.campaigns.value(id)).Inc()
+	}
+}
+
+func (r *Registry) ObserveDBQuery(seconds float64) {
+	if r == nil {
+		return
+	}
+	r.DBQueryDuration.Observe(seconds)
+}
+
+// ObserveMatch records a snapshot match and the number of candidates it
+// returned.
+func (r *Registry) ObserveMatch(seconds float64, candidates int) {
+	if r == nil {
+		return
+	}
+	r.MatchDuration.Observe(seconds)
+	r.MatchCandidates.Observe(float64(candidates))
+}
 
-func ObserveRequest(status string, seconds float64) {
-	RequestCount.WithLabelValues(status).Inc()
-	RequestDuration.WithLabelValues(status).Observe(seconds)
+// ObserveAuction records an auction outcome and, for won auctions, the
+// clearing price.
+func (r *Registry) ObserveAuction(outcome string, priceCPM float64) {
+	if r == nil {
+		return
+	}
+	r.AuctionCount.WithLabelValues(outcome).Inc()
+	if outcome == "won" {
+		r.ClearingPrice.Observe(priceCPM)
+	}
 }
 
-func ObserveDBQuery(seconds float64) {
-	DBQueryDuration.Observe(seconds)
-}
\ No newline at end of file
+// ObserveTrackingEvent records a tracking call for event and its result:
+// "ok", "invalid" or "expired".
+func (r *Registry) ObserveTrackingEvent(event, result string) {
+	if r == nil {
+		return
+	}
+	r.TrackingEventCount.WithLabelValues(event, result).Inc()
+}
+
+func (r *Registry) ObserveRateLimited(tier, subject string) {
+	if r == nil {
+		return
+	}
+	r.RateLimitedCount.WithLabelValues(tier, subject).Inc()
+}
+
+// ObserveStale records a delivery response served from a stale snapshot.
+func (r *Registry) ObserveStale(api string) {
+	if r == nil {
+		return
+	}
+	r.StaleResponseCount.WithLabelValues(api).Inc()
+}
+
+// ObserveCancelled records a delivery request whose work was cancelled:
+// the client went away, or the request or query deadline passed.
+func (r *Registry) ObserveCancelled(api, reason string) {
+	if r == nil {
+		return
+	}
+	r.CancelledCount.WithLabelValues(api, reason).Inc()
+}
+
+func (r *Registry) SetBreakerState(name string, state int) {
+	if r == nil {
+		return
+	}
+	r.BreakerState.WithLabelValues(name).Set(float64(state))
+}
+
+// ObserveSnapshotRefresh records a snapshot refresh from the database and
+// whether it failed.
+func (r *Registry) ObserveSnapshotRefresh(seconds float64, err error) {
+	if r == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	r.SnapshotRefresh.WithLabelValues(result).Inc()
+	r.SnapshotDuration.Observe(seconds)
+}
+
+// SetSnapshot describes the snapshot now being served.
+func (r *Registry) SetSnapshot(loadedAt time.Time, campaigns int, stale bool) {
+	if r == nil {
+		return
+	}
+	r.SnapshotLoadedAt.Set(float64(loadedAt.UnixNano()) / 1e9)
+	r.SnapshotCampaigns.Set(float64(campaigns))
+	r.SetSnapshotStale(stale)
+}
+
+// SetSnapshotStale marks the current snapshot as stale or fresh.
+func (r *Registry) SetSnapshotStale(stale bool) {
+	if r == nil {
+		return
+	}
+	v := 0.0
+	if stale {
+		v = 1
+	}
+	r.SnapshotStale.Set(v)
+}
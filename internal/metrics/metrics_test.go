@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveServed(t *testing.T) {
+	r := New(WithLabelLimits(2, 1, 0))
+
+	r.ObserveServed("v1", "us", "android", []string{"spotify", "duolingo"})
+	r.ObserveServed("v1", "us", "ios", []string{"spotify", "subwaysurfer"})
+	r.ObserveServed("v2", "de", "", nil)
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"campaign within limit", []string{"v1", "spotify"}, 2},
+		{"second campaign within limit", []string{"v1", "duolingo"}, 1},
+		{"campaign past limit", []string{"v1", OtherLabel}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, testutil.ToFloat64(r.ServedCount.WithLabelValues(tt.labels...)))
+		})
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.FillCount.WithLabelValues("v1", "us", "android", "true")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.FillCount.WithLabelValues("v1", "us", "ios", "true")), "OS has no limit")
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.FillCount.WithLabelValues("v2", OtherLabel, UnknownLabel, "false")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.LabelOverflow.WithLabelValues("campaign")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.LabelOverflow.WithLabelValues("country")))
+}
+
+func TestSnapshotMetrics(t *testing.T) {
+	r := New()
+	loadedAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	r.SetSnapshot(loadedAt, 42, false)
+	r.ObserveSnapshotRefresh(0.2, nil)
+	r.ObserveSnapshotRefresh(0.1, errors.New("connection refused"))
+	r.SetSnapshotStale(true)
+
+	assert.Equal(t, float64(loadedAt.Unix()), testutil.ToFloat64(r.SnapshotLoadedAt))
+	assert.Equal(t, 42.0, testutil.ToFloat64(r.SnapshotCampaigns))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.SnapshotStale))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.SnapshotRefresh.WithLabelValues("success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.SnapshotRefresh.WithLabelValues("error")))
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	assert.NotPanics(t, func() {
+		r.ObserveRequest("v2", StatusNoContent, 0.01)
+		r.ObserveServed("v2", "us", "android", []string{"spotify"})
+		r.ObserveAuction("won", 1.5)
+		r.SetBreakerState("db", 2)
+		r.SetSnapshot(time.Now(), 3, true)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	r := New()
+	r.ObserveRequest("v1", StatusOK, 0.01)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `delivery_requests_total{api="v1",status="ok"} 1`)
+	assert.Contains(t, string(body), "go_goroutines")
+}
@@ -0,0 +1,186 @@
+// Package migrate applies versioned schema migrations. Migrations are pairs
+// of files named NNNN_name.up.sql and NNNN_name.down.sql; applied versions
+// are recorded with the checksum of their up script in schema_migrations.
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrChecksumMismatch is returned when an applied migration's script has
+// been edited since it ran.
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+	// Checksum is the hex SHA-256 of Up.
+	Checksum string `json:"checksum"`
+}
+
+// Applied is a row of schema_migrations.
+type Applied struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// State is a migration with whether and when it was applied.
+type State struct {
+	Migration
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the root of fsys, sorted by version. Every
+// version needs both an up and a down file; other files are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%s: invalid version", e.Name())
+		}
+		raw, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(raw)
+			mig.Checksum = checksum(raw)
+		} else {
+			mig.Down = string(raw)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func checksum(script []byte) string {
+	sum := sha256.Sum256(script)
+	return hex.EncodeToString(sum[:])
+}
+
+// verify checks that every applied migration this binary knows still has
+// the checksum it was applied with. Versions it does not know, applied by
+// a newer release, are allowed.
+func verify(migrations []Migration, applied []Applied) error {
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	for _, a := range applied {
+		if m, ok := known[a.Version]; ok && m.Checksum != a.Checksum {
+			return fmt.Errorf("%w: %d_%s was applied as %.12s, file is now %.12s",
+				ErrChecksumMismatch, m.Version, m.Name, a.Checksum, m.Checksum)
+		}
+	}
+	return nil
+}
+
+// pending returns the migrations not yet applied, in version order.
+func pending(migrations []Migration, applied []Applied) []Migration {
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		done[a.Version] = true
+	}
+	var out []Migration
+	for _, m := range migrations {
+		if !done[m.Version] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// rollback returns the last steps applied migrations, newest first. It
+// fails if one of them is unknown to this binary.
+func rollback(migrations []Migration, applied []Applied, steps int) ([]Migration, error) {
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	sorted := append([]Applied(nil), applied...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version > sorted[j].Version })
+	if steps > len(sorted) {
+		steps = len(sorted)
+	}
+
+	out := make([]Migration, 0, steps)
+	for _, a := range sorted[:steps] {
+		m, ok := known[a.Version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d_%s is not known to this binary", a.Version, a.Name)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// states merges migrations with what has been applied. Applied versions
+// unknown to this binary are included without scripts.
+func states(migrations []Migration, applied []Applied) []State {
+	byVersion := make(map[int]Applied, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+	out := make([]State, 0, len(migrations))
+	for _, m := range migrations {
+		s := State{Migration: m}
+		if a, ok := byVersion[m.Version]; ok {
+			at := a.AppliedAt
+			s.Applied, s.AppliedAt = true, &at
+			delete(byVersion, m.Version)
+		}
+		out = append(out, s)
+	}
+	for _, a := range byVersion {
+		at := a.AppliedAt
+		out = append(out, State{
+			Migration: Migration{Version: a.Version, Name: a.Name, Checksum: a.Checksum},
+			Applied:   true,
+			AppliedAt: &at,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/db/migrations"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0002_add_bids.up.sql":   {Data: []byte("ALTER TABLE c ADD COLUMN bid INT;")},
+		"0002_add_bids.down.sql": {Data: []byte("ALTER TABLE c DROP COLUMN bid;")},
+		"0001_initial.up.sql":    {Data: []byte("CREATE TABLE c (id INT);")},
+		"0001_initial.down.sql":  {Data: []byte("DROP TABLE c;")},
+		"README.md":              {Data: []byte("ignored")},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	list, err := Load(testFS())
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, 1, list[0].Version)
+	assert.Equal(t, "initial", list[0].Name)
+	assert.Equal(t, "DROP TABLE c;", list[0].Down)
+	assert.Equal(t, 2, list[1].Version)
+	assert.Len(t, list[1].Checksum, 64)
+
+	tests := []struct {
+		name     string
+		fs       fstest.MapFS
+		errorMsg string
+	}{
+		{"Missing down", fstest.MapFS{"0001_a.up.sql": {Data: []byte("x")}}, "needs both an up and a down file"},
+		{"Empty up", fstest.MapFS{"0001_a.up.sql": {}, "0001_a.down.sql": {Data: []byte("x")}}, "needs both an up and a down file"},
+		{"Two names", fstest.MapFS{"0001_a.up.sql": {Data: []byte("x")}, "0001_b.down.sql": {Data: []byte("x")}}, "has two names"},
+		{"Version zero", fstest.MapFS{"0000_a.up.sql": {Data: []byte("x")}}, "invalid version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fs)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+	for i, m := range list {
+		assert.Equal(t, i+1, m.Version, "versions must have no gaps")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	list, err := Load(testFS())
+	require.NoError(t, err)
+	now := time.Now()
+	first := Applied{Version: 1, Name: "initial", Checksum: list[0].Checksum, AppliedAt: now}
+
+	assert.Len(t, pending(list, nil), 2)
+	up := pending(list, []Applied{first})
+	require.Len(t, up, 1)
+	assert.Equal(t, 2, up[0].Version)
+
+	// An edited, already applied migration blocks everything
+	edited := first
+	edited.Checksum = "0000"
+	assert.True(t, errors.Is(verify(list, []Applied{edited}), ErrChecksumMismatch))
+	// Versions applied by a newer release are tolerated
+	assert.NoError(t, verify(list, []Applied{first, {Version: 9, Name: "future", Checksum: "x"}}))
+
+	second := Applied{Version: 2, Name: "add_bids", Checksum: list[1].Checksum, AppliedAt: now}
+	down, err := rollback(list, []Applied{first, second}, 1)
+	require.NoError(t, err)
+	require.Len(t, down, 1)
+	assert.Equal(t, 2, down[0].Version)
+
+	down, err = rollback(list, []Applied{first, second}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, []int{down[0].Version, down[1].Version})
+
+	_, err = rollback(list, []Applied{first, {Version: 9, Name: "future"}}, 1)
+	assert.EqualError(t, err, "migration 9_future is not known to this binary")
+
+	st := states(list, []Applied{first, {Version: 9, Name: "future"}})
+	require.Len(t, st, 3)
+	assert.True(t, st[0].Applied)
+	assert.False(t, st[1].Applied)
+	assert.Equal(t, 9, st[2].Version)
+	assert.True(t, st[2].Applied)
+}
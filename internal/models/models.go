@@ -6,20 +6,211 @@ type Campaign struct {
 	Img    string `json:"img"`
 	CTA    string `json:"cta"`
 	Status string `json:"status"`
+	// AdvertiserID is empty for campaigns not yet assigned to an advertiser.
+	AdvertiserID string `json:"advertiser_id,omitempty"`
+	// Categories are IAB content categories such as "IAB9-30", used by
+	// publisher block lists.
+	Categories []string `json:"categories,omitempty"`
+	// CreativeRotation is "weighted" to rotate between compatible creatives
+	// by weight; empty always serves the best one.
+	CreativeRotation string `json:"creative_rotation,omitempty"`
+	// Creative is the creative picked for a delivery request, if the
+	// campaign has any.
+	Creative *Creative `json:"creative,omitempty"`
+	// BidCPM is what the campaign bids per thousand impressions in
+	// auctions; zero means it does not bid. Delivery responses only show it
+	// in the auction diagnostics of debug requests: the admin API and bulk
+	// files carry it in CampaignRecord.
+	BidCPM float64 `json:"-"`
+	// Auction describes the auction the campaign won, when delivery runs
+	// auctions.
+	Auction *Auction `json:"auction,omitempty"`
+	// Tracking is set when delivery issues tracking URLs.
+	Tracking *Tracking `json:"tracking,omitempty"`
+}
+
+// CampaignRecord is a campaign as the admin API, bulk files and history
+// show it: the campaign with its bid.
+type CampaignRecord struct {
+	Campaign
+	BidCPM float64 `json:"bid_cpm,omitempty"`
+}
+
+// NewCampaignRecord returns the record of c.
+func NewCampaignRecord(c Campaign) CampaignRecord {
+	return CampaignRecord{Campaign: c, BidCPM: c.BidCPM}
+}
+
+// NewCampaignRecords returns the records of list.
+func NewCampaignRecords(list []Campaign) []CampaignRecord {
+	records := make([]CampaignRecord, len(list))
+	for i, c := range list {
+		records[i] = NewCampaignRecord(c)
+	}
+	return records
+}
+
+// Model returns the campaign of r with its bid set.
+func (r CampaignRecord) Model() Campaign {
+	c := r.Campaign
+	c.BidCPM = r.BidCPM
+	return c
+}
+
+// Creative is one ad unit of a campaign. Assets hold the format's
+// components by name: "img" and "cta" for banners and interstitials,
+// "title", "body" and "icon" for native, "video_url" for video.
+type Creative struct {
+	ID         string `json:"id"`
+	CampaignID string `json:"campaign_id"`
+	Format     string `json:"format"`
+	// Sizes are "WxH" slots the creative fits; empty fits any size.
+	Sizes  []string          `json:"sizes,omitempty"`
+	Assets map[string]string `json:"assets"`
+	Status string            `json:"status"`
+	// Weight is the creative's share under weighted rotation.
+	Weight int `json:"weight"`
+}
+
+// Auction holds the clearing price and diagnostics of an auction. Only the
+// clearing price is part of delivery responses by default: the diagnostics
+// would tell a campaign what the others bid, so they are only shown to
+// requests allowed to see bids, through Debug.
+type Auction struct {
+	// PriceCPM is the clearing price the winner pays.
+	PriceCPM float64 `json:"price_cpm"`
+	// PriceSource is "second_price" or "floor".
+	PriceSource string  `json:"-"`
+	BidCPM      float64 `json:"-"`
+	SecondCPM   float64 `json:"-"`
+	FloorCPM    float64 `json:"-"`
+	// Bidders is the number of campaigns that bid at or above the floor.
+	Bidders    int `json:"-"`
+	BelowFloor int `json:"-"`
+	NoBid      int `json:"-"`
+	// Debug is set to the diagnostics in responses to debug requests.
+	Debug *AuctionDiagnostics `json:"diagnostics,omitempty"`
+}
+
+// AuctionDiagnostics explain a clearing price: the bids behind it and the
+// campaigns that did not take part.
+type AuctionDiagnostics struct {
+	PriceSource string  `json:"price_source"`
+	BidCPM      float64 `json:"bid_cpm"`
+	SecondCPM   float64 `json:"second_cpm"`
+	FloorCPM    float64 `json:"floor_cpm"`
+	Bidders     int     `json:"bidders"`
+	BelowFloor  int     `json:"below_floor"`
+	NoBid       int     `json:"no_bid"`
+}
+
+// Diagnostics returns the diagnostics of a.
+func (a Auction) Diagnostics() AuctionDiagnostics {
+	return AuctionDiagnostics{
+		PriceSource: a.PriceSource,
+		BidCPM:      a.BidCPM,
+		SecondCPM:   a.SecondCPM,
+		FloorCPM:    a.FloorCPM,
+		Bidders:     a.Bidders,
+		BelowFloor:  a.BelowFloor,
+		NoBid:       a.NoBid,
+	}
+}
+
+// WithAuctionDiagnostics returns a copy of list whose auctions show their
+// diagnostics.
+func WithAuctionDiagnostics(list []Campaign) []Campaign {
+	out := make([]Campaign, len(list))
+	for i, c := range list {
+		if c.Auction != nil {
+			a := *c.Auction
+			d := a.Diagnostics()
+			a.Debug = &d
+			c.Auction = &a
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// Tracking holds the URLs a client calls when it shows a served campaign
+// and when the user clicks it.
+type Tracking struct {
+	ImpressionURL string `json:"impression_url"`
+	ClickURL      string `json:"click_url"`
+}
+
+// Placement is an ad slot within an app, such as its home-screen banner or
+// level-end interstitial. Creatives must match one of its formats and, if
+// it lists sizes, one of its sizes.
+type Placement struct {
+	ID      string   `json:"id"`
+	App     string   `json:"app"`
+	Name    string   `json:"name"`
+	Formats []string `json:"formats"`
+	Sizes   []string `json:"sizes,omitempty"`
+	// FloorCPM is the minimum price per thousand impressions.
+	FloorCPM float64 `json:"floor_cpm"`
+}
+
+// Advertiser owns campaigns. BlockedApps lists app IDs or glob patterns its
+// campaigns must never serve on.
+type Advertiser struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	BlockedApps []string `json:"blocked_apps"`
+}
+
+// Publisher owns apps and may block advertisers or campaign categories from
+// serving on them. Blocking a parent category such as "IAB9" also blocks
+// its subcategories ("IAB9-30").
+type Publisher struct {
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Apps               []string `json:"apps"`
+	BlockedAdvertisers []string `json:"blocked_advertisers"`
+	BlockedCategories  []string `json:"blocked_categories"`
 }
 
 type TargetingRule struct {
-	CampaignID     string   `json:"campaign_id"`
-	IncludeCountry []string `json:"include_country"`
-	ExcludeCountry []string `json:"exclude_country"`
-	IncludeOS      []string `json:"include_os"`
-	ExcludeOS      []string `json:"exclude_os"`
-	IncludeApp     []string `json:"include_app"`
-	ExcludeApp     []string `json:"exclude_app"`
+	CampaignID       string     `json:"campaign_id"`
+	IncludeCountry   []string   `json:"include_country"`
+	ExcludeCountry   []string   `json:"exclude_country"`
+	IncludeOS        []string   `json:"include_os"`
+	ExcludeOS        []string   `json:"exclude_os"`
+	IncludeApp       []string   `json:"include_app"`
+	ExcludeApp       []string   `json:"exclude_app"`
+	IncludeRegion    []string   `json:"include_region"`
+	ExcludeRegion    []string   `json:"exclude_region"`
+	IncludeCity      []string   `json:"include_city"`
+	ExcludeCity      []string   `json:"exclude_city"`
+	IncludeGeofence  []Geofence `json:"include_geofence"`
+	ExcludeGeofence  []Geofence `json:"exclude_geofence"`
+	IncludeSegment   []string   `json:"include_segment"`
+	ExcludeSegment   []string   `json:"exclude_segment"`
+	IncludePlacement []string   `json:"include_placement"`
+	ExcludePlacement []string   `json:"exclude_placement"`
+}
+
+// Geofence is either a circle (Lat, Lon, RadiusKm) or a polygon given as
+// [lat, lon] vertices.
+type Geofence struct {
+	Lat      float64      `json:"lat,omitempty"`
+	Lon      float64      `json:"lon,omitempty"`
+	RadiusKm float64      `json:"radius_km,omitempty"`
+	Polygon  [][2]float64 `json:"polygon,omitempty"`
 }
 
 type DeliveryRequest struct {
-	App     string `json:"app"`
-	Country string `json:"country"`
-	OS      string `json:"os"`
+	App         string   `json:"app"`
+	Country     string   `json:"country"`
+	OS          string   `json:"os"`
+	Region      string   `json:"region,omitempty"`
+	City        string   `json:"city,omitempty"`
+	Lat         *float64 `json:"lat,omitempty"`
+	Lon         *float64 `json:"lon,omitempty"`
+	DeviceID    string   `json:"device_id,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Size        string   `json:"size,omitempty"`
+	PlacementID string   `json:"placement_id,omitempty"`
 }
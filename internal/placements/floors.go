@@ -0,0 +1,70 @@
+package placements
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// FloorFor returns the auction floor for a request: the placement's floor
+// when it sets one, otherwise the app's. p may be nil.
+func FloorFor(p *models.Placement, appFloor float64) float64 {
+	if p != nil && p.FloorCPM > 0 {
+		return p.FloorCPM
+	}
+	return appFloor
+}
+
+// ListAppFloors returns the floor of every app that has one.
+func ListAppFloors(ctx context.Context, db *sql.DB) (map[string]float64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT app, floor_cpm FROM app_floors`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	floors := make(map[string]float64)
+	for rows.Next() {
+		var app string
+		var floor float64
+		if err := rows.Scan(&app, &floor); err != nil {
+			return nil, err
+		}
+		floors[app] = floor
+	}
+	return floors, rows.Err()
+}
+
+// GetAppFloor returns an app's floor, or 0 if it has none.
+func GetAppFloor(ctx context.Context, db *sql.DB, app string) (float64, error) {
+	var floor float64
+	err := db.QueryRowContext(ctx, `SELECT floor_cpm FROM app_floors WHERE app = $1`, app).Scan(&floor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return floor, err
+}
+
+// SaveAppFloor sets an app's floor.
+func SaveAppFloor(db *sql.DB, app string, floor float64) error {
+	_, err := db.Exec(`
+	INSERT INTO app_floors (app, floor_cpm) VALUES ($1, $2)
+	ON CONFLICT (app) DO UPDATE SET floor_cpm = EXCLUDED.floor_cpm
+	`, app, floor)
+	return err
+}
+
+// DeleteAppFloor removes an app's floor. It returns sql.ErrNoRows if the app
+// has none.
+func DeleteAppFloor(db *sql.DB, app string) error {
+	res, err := db.Exec(`DELETE FROM app_floors WHERE app = $1`, app)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
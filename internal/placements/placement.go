@@ -0,0 +1,96 @@
+// Package placements holds the registry of ad slots (placements) within
+// apps, with the formats, sizes and floor price each one accepts.
+package placements
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// ErrInvalid is returned for a placement_id that is unknown or belongs to
+// a different app than the request.
+var ErrInvalid = errors.New("invalid placement_id param")
+
+// NormalizeID lower-cases and trims a placement ID.
+func NormalizeID(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// Normalize validates p and canonicalises its ID, app, formats and sizes in
+// place.
+func Normalize(p *models.Placement) error {
+	if p.ID = NormalizeID(p.ID); p.ID == "" {
+		return fmt.Errorf("missing placement id")
+	}
+	p.App = targeting.NormalizeApp(p.App)
+	if p.App == "" || targeting.IsPattern(p.App) {
+		return fmt.Errorf("placement app must be an exact app ID")
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if len(p.Formats) == 0 {
+		return fmt.Errorf("placement needs at least one format")
+	}
+
+	var err error
+	for i, f := range p.Formats {
+		if p.Formats[i], err = creatives.ParseFormat(f); err != nil {
+			return err
+		}
+	}
+	for i, s := range p.Sizes {
+		if p.Sizes[i], err = creatives.ParseSize(s); err != nil {
+			return err
+		}
+	}
+	if p.FloorCPM < 0 {
+		return fmt.Errorf("floor_cpm must not be negative")
+	}
+	return nil
+}
+
+// Resolve looks up the request's placement with lookup, which returns
+// sql.ErrNoRows for unknown IDs (as Get does), and checks it belongs to the
+// request's app. It returns nil without error when the request has no
+// placement_id.
+func Resolve(req models.DeliveryRequest, lookup func(id string) (*models.Placement, error)) (*models.Placement, error) {
+	if req.PlacementID == "" {
+		return nil, nil
+	}
+	p, err := lookup(NormalizeID(req.PlacementID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.App != targeting.NormalizeApp(req.App) {
+		return nil, ErrInvalid
+	}
+	return p, nil
+}
+
+// Index is an in-memory placement registry keyed by ID.
+type Index map[string]*models.Placement
+
+// NewIndex indexes list by ID.
+func NewIndex(list []models.Placement) Index {
+	idx := make(Index, len(list))
+	for i := range list {
+		idx[list[i].ID] = &list[i]
+	}
+	return idx
+}
+
+// Get implements the lookup expected by Resolve.
+func (idx Index) Get(id string) (*models.Placement, error) {
+	if p, ok := idx[id]; ok {
+		return p, nil
+	}
+	return nil, sql.ErrNoRows
+}
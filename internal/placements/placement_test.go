@@ -0,0 +1,77 @@
+package placements
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func TestNormalize(t *testing.T) {
+	p := models.Placement{ID: " Ludo-Home ", App: "COM.Gametion.LudoKingGame", Formats: []string{"Banner"}, Sizes: []string{"320X50"}, FloorCPM: 0.5}
+	require.NoError(t, Normalize(&p))
+	assert.Equal(t, "ludo-home", p.ID)
+	assert.Equal(t, "com.gametion.ludokinggame", p.App)
+	assert.Equal(t, []string{"banner"}, p.Formats)
+	assert.Equal(t, []string{"320x50"}, p.Sizes)
+
+	tests := []struct {
+		name      string
+		placement models.Placement
+		errorMsg  string
+	}{
+		{"Missing id", models.Placement{App: "com.test", Formats: []string{"banner"}}, "missing placement id"},
+		{"App pattern", models.Placement{ID: "x", App: "com.test.*", Formats: []string{"banner"}}, "placement app must be an exact app ID"},
+		{"No formats", models.Placement{ID: "x", App: "com.test"}, "placement needs at least one format"},
+		{"Unknown format", models.Placement{ID: "x", App: "com.test", Formats: []string{"billboard"}}, `unknown creative format "billboard"`},
+		{"Bad size", models.Placement{ID: "x", App: "com.test", Formats: []string{"banner"}, Sizes: []string{"big"}}, "invalid size"},
+		{"Negative floor", models.Placement{ID: "x", App: "com.test", Formats: []string{"banner"}, FloorCPM: -1}, "floor_cpm must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Normalize(&tt.placement)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	idx := NewIndex([]models.Placement{
+		{ID: "ludo-home", App: "com.gametion.ludokinggame", Formats: []string{"banner"}},
+	})
+
+	tests := []struct {
+		name     string
+		req      models.DeliveryRequest
+		expected string // placement ID, "" for none
+		err      error
+	}{
+		{"No placement", models.DeliveryRequest{App: "com.test"}, "", nil},
+		{"Known placement", models.DeliveryRequest{App: "com.gametion.ludokinggame", PlacementID: "Ludo-Home"}, "ludo-home", nil},
+		{"Unknown placement", models.DeliveryRequest{App: "com.gametion.ludokinggame", PlacementID: "nope"}, "", ErrInvalid},
+		{"Placement of another app", models.DeliveryRequest{App: "com.test", PlacementID: "ludo-home"}, "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Resolve(tt.req, idx.Get)
+			assert.ErrorIs(t, err, tt.err)
+			if tt.expected == "" {
+				assert.Nil(t, p)
+				return
+			}
+			require.NotNil(t, p)
+			assert.Equal(t, tt.expected, p.ID)
+		})
+	}
+
+	// Lookup failures other than a missing placement are passed through
+	boom := errors.New("connection refused")
+	_, err := Resolve(models.DeliveryRequest{PlacementID: "x"}, func(string) (*models.Placement, error) { return nil, boom })
+	assert.ErrorIs(t, err, boom)
+	assert.NotErrorIs(t, err, sql.ErrNoRows)
+}
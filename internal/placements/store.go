@@ -0,0 +1,70 @@
+package placements
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+const placementColumns = `id, app, name, formats, sizes, floor_cpm`
+
+// List returns every placement ordered by ID.
+func List(ctx context.Context, db *sql.DB) ([]models.Placement, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+placementColumns+` FROM placements ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Placement
+	for rows.Next() {
+		p, err := scanPlacement(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *p)
+	}
+	return list, rows.Err()
+}
+
+// Get returns a single placement. It returns sql.ErrNoRows if it does not
+// exist.
+func Get(ctx context.Context, db *sql.DB, id string) (*models.Placement, error) {
+	return scanPlacement(db.QueryRowContext(ctx, `SELECT `+placementColumns+` FROM placements WHERE id = $1`, NormalizeID(id)))
+}
+
+// Save creates or replaces a placement. It should already be normalised
+// with Normalize.
+func Save(db *sql.DB, p models.Placement) error {
+	query := `
+	INSERT INTO placements (id, app, name, formats, sizes, floor_cpm) VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (id) DO UPDATE SET app = EXCLUDED.app, name = EXCLUDED.name,
+		formats = EXCLUDED.formats, sizes = EXCLUDED.sizes, floor_cpm = EXCLUDED.floor_cpm
+	`
+	_, err := db.Exec(query, p.ID, p.App, p.Name, pq.StringArray(p.Formats), pq.StringArray(p.Sizes), p.FloorCPM)
+	return err
+}
+
+// Delete removes a placement. It returns sql.ErrNoRows if it does not exist.
+func Delete(db *sql.DB, id string) error {
+	res, err := db.Exec(`DELETE FROM placements WHERE id = $1`, NormalizeID(id))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanPlacement(row interface{ Scan(...interface{}) error }) (*models.Placement, error) {
+	var p models.Placement
+	err := row.Scan(&p.ID, &p.App, &p.Name, (*pq.StringArray)(&p.Formats), (*pq.StringArray)(&p.Sizes), &p.FloorCPM)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
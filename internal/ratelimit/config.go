@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Subject is what a request's bucket is keyed by.
+type Subject string
+
+const (
+	// ByAPIKey gives every API key its own bucket.
+	ByAPIKey Subject = "api_key"
+	// ByApp gives every app bundle ID its own bucket.
+	ByApp Subject = "app"
+	// ByIP gives every client IP its own bucket.
+	ByIP Subject = "ip"
+)
+
+// Config holds the tiers and how requests are assigned to them.
+type Config struct {
+	// By lists the subjects to key buckets by, in order of preference. The
+	// first one present on a request is used, so the default
+	// [api_key, app, ip] falls back to the app when auth is disabled.
+	By []Subject `json:"by"`
+	// DefaultTier applies to subjects without an assignment.
+	DefaultTier string `json:"default_tier"`
+	// Tiers maps tier names to limits.
+	Tiers map[string]Limit `json:"tiers"`
+	// Assignments maps "key:<id>", "publisher:<name>", "app:<bundle>" or
+	// "ip:<addr>" to a tier name. They are checked in that order; "ip:" only
+	// applies to requests bucketed by IP.
+	Assignments map[string]string `json:"assignments"`
+}
+
+// LoadConfig reads a JSON config file and validates it.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cfg Config
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks that every referenced tier exists, fills in the default
+// subject order and lower-cases assignment keys.
+func (c *Config) Validate() error {
+	if len(c.By) == 0 {
+		c.By = []Subject{ByAPIKey, ByApp, ByIP}
+	}
+	for _, s := range c.By {
+		switch s {
+		case ByAPIKey, ByApp, ByIP:
+		default:
+			return fmt.Errorf("unknown rate limit subject %q (want %q, %q or %q)", s, ByAPIKey, ByApp, ByIP)
+		}
+	}
+
+	if len(c.Tiers) == 0 {
+		return fmt.Errorf("no rate limit tiers configured")
+	}
+	for name, l := range c.Tiers {
+		if !l.Unlimited() && l.Burst < 1 {
+			return fmt.Errorf("tier %q: burst must be at least 1", name)
+		}
+	}
+	if _, ok := c.Tiers[c.DefaultTier]; !ok {
+		return fmt.Errorf("default_tier %q is not a configured tier", c.DefaultTier)
+	}
+
+	assignments := make(map[string]string, len(c.Assignments))
+	for subject, tier := range c.Assignments {
+		if _, ok := c.Tiers[tier]; !ok {
+			return fmt.Errorf("assignment %q: unknown tier %q", subject, tier)
+		}
+		subject = strings.ToLower(strings.TrimSpace(subject))
+		kind, _, ok := strings.Cut(subject, ":")
+		switch {
+		case !ok:
+			return fmt.Errorf("assignment %q: want <kind>:<value>", subject)
+		case kind != "key" && kind != "publisher" && kind != "app" && kind != "ip":
+			return fmt.Errorf("assignment %q: unknown kind %q", subject, kind)
+		}
+		assignments[subject] = tier
+	}
+	c.Assignments = assignments
+	return nil
+}
+
+// tierFor returns the tier for a request identified by the given subjects,
+// any of which may be empty, and bucketed by subject. Clients can claim any
+// IP through forwarded headers, so IP assignments only apply to requests
+// bucketed by IP and never lift the tier of a key or app.
+func (c *Config) tierFor(subject Subject, keyID, publisher, app, ip string) string {
+	if subject != ByIP {
+		ip = ""
+	}
+	candidates := [...]struct{ kind, value string }{
+		{"key", keyID},
+		{"publisher", publisher},
+		{"app", app},
+		{"ip", ip},
+	}
+	for _, cand := range candidates {
+		if cand.value == "" {
+			continue
+		}
+		if tier, ok := c.Assignments[cand.kind+":"+strings.ToLower(cand.value)]; ok {
+			return tier
+		}
+	}
+	return c.DefaultTier
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// Limiter applies a Config to HTTP requests using a Store.
+type Limiter struct {
+	cfg     *Config
+	store   Store
+	metrics *metrics.Registry
+	now     func() time.Time
+}
+
+// NewLimiter creates a limiter that counts rejected requests in reg. cfg
+// must have been validated.
+func NewLimiter(cfg *Config, store Store, reg *metrics.Registry) *Limiter {
+	return &Limiter{cfg: cfg, store: store, metrics: reg, now: time.Now}
+}
+
+// Middleware rejects requests over their tier's limit with 429 and a
+// Retry-After header. It must run after auth so the API key is known. If
+// the store fails the request is let through: an outage of a shared store
+// should not take delivery down with it.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var keyID, publisher string
+		if key, ok := auth.FromContext(r.Context()); ok {
+			keyID, publisher = key.ID, key.Publisher
+		}
+		app := targeting.NormalizeApp(r.URL.Query().Get("app"))
+		ip := clientIP(r.RemoteAddr)
+
+		subject, bucket := l.bucketKey(keyID, app, ip)
+		if bucket == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tier := l.cfg.tierFor(subject, keyID, publisher, app, ip)
+		limit := l.cfg.Tiers[tier]
+
+		d, err := l.store.Take(tier+"|"+bucket, limit, l.now())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "rate limit store error, allowing request", logging.Err(err))
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !limit.Unlimited() {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		}
+		if !d.Allowed {
+			l.metrics.ObserveRateLimited(tier, string(subject))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bucketKey picks the first configured subject present on the request.
+func (l *Limiter) bucketKey(keyID, app, ip string) (Subject, string) {
+	for _, s := range l.cfg.By {
+		switch {
+		case s == ByAPIKey && keyID != "":
+			return s, "key:" + keyID
+		case s == ByApp && app != "":
+			return s, "app:" + app
+		case s == ByIP && ip != "":
+			return s, "ip:" + ip
+		}
+	}
+	return "", ""
+}
+
+func clientIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
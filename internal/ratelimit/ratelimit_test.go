@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Rate: 2, Burst: 3}
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 3; i++ {
+		d, err := s.Take("k", limit, now)
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 2-i, d.Remaining)
+	}
+
+	d, err := s.Take("k", limit, now)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+	// Other keys have their own bucket
+	d, _ = s.Take("other", limit, now)
+	assert.True(t, d.Allowed)
+
+	// Half a second refills one token
+	d, _ = s.Take("k", limit, now.Add(500*time.Millisecond))
+	assert.True(t, d.Allowed)
+	d, _ = s.Take("k", limit, now.Add(500*time.Millisecond))
+	assert.False(t, d.Allowed)
+
+	// Refill never exceeds the burst
+	d, _ = s.Take("k", limit, now.Add(time.Hour))
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining)
+}
+
+func TestMemoryStoreUnlimited(t *testing.T) {
+	s := NewMemoryStore()
+	for i := 0; i < 100; i++ {
+		d, err := s.Take("k", Limit{}, time.Now())
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+	}
+	assert.Zero(t, s.Len())
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Unix(1_700_000_000, 0)
+
+	s.Take("idle", limit, now)
+	s.Take("busy", limit, now.Add(time.Second))
+	s.Take("busy", limit, now.Add(time.Second))
+	require.Equal(t, 2, s.Len())
+
+	s.Sweep(now.Add(1500 * time.Millisecond))
+	assert.Equal(t, 1, s.Len())
+
+	s.Sweep(now.Add(time.Minute))
+	assert.Zero(t, s.Len())
+}
+
+func TestConfigValidate(t *testing.T) {
+	tiers := map[string]Limit{"standard": {Rate: 10, Burst: 20}, "internal": {}}
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		errorMsg string
+	}{
+		{"Valid", Config{DefaultTier: "standard", Tiers: tiers, Assignments: map[string]string{"App:Com.Foo": "internal"}}, ""},
+		{"No tiers", Config{DefaultTier: "standard"}, "no rate limit tiers configured"},
+		{"Unknown default", Config{DefaultTier: "gold", Tiers: tiers}, `default_tier "gold" is not a configured tier`},
+		{"Zero burst", Config{DefaultTier: "bad", Tiers: map[string]Limit{"bad": {Rate: 1}}}, `tier "bad": burst must be at least 1`},
+		{"Unknown subject", Config{By: []Subject{"device"}, DefaultTier: "standard", Tiers: tiers}, `unknown rate limit subject "device"`},
+		{"Unknown assigned tier", Config{DefaultTier: "standard", Tiers: tiers, Assignments: map[string]string{"app:x": "gold"}}, `assignment "app:x": unknown tier "gold"`},
+		{"Bad assignment kind", Config{DefaultTier: "standard", Tiers: tiers, Assignments: map[string]string{"Device:x": "internal"}}, `assignment "device:x": unknown kind "device"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []Subject{ByAPIKey, ByApp, ByIP}, tt.cfg.By)
+			assert.Equal(t, "internal", tt.cfg.Assignments["app:com.foo"])
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"default_tier":"s","tiers":{"s":{"rate":1,"burst":1}},"unknown":1}`), 0o600))
+	_, err := LoadConfig(path)
+	assert.ErrorContains(t, err, "unknown field")
+
+	_, err = LoadConfig("../../deploy/ratelimit/ratelimit.json")
+	assert.NoError(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg := &Config{
+		DefaultTier: "standard",
+		Tiers: map[string]Limit{
+			"standard": {Rate: 1, Burst: 1},
+			"premium":  {Rate: 1, Burst: 3},
+		},
+		Assignments: map[string]string{"publisher:gametion": "premium"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	now := time.Unix(1_700_000_000, 0)
+	l := NewLimiter(cfg, NewMemoryStore(), nil)
+	l.now = func() time.Time { return now }
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(app, ip string, key *auth.Key) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/delivery?app="+app, nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != nil {
+			req = req.WithContext(auth.NewContext(req.Context(), key))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without a key requests are keyed by app, on the default tier
+	assert.Equal(t, http.StatusOK, do("com.spotify.music", "10.0.0.1", nil).Code)
+	rec := do("COM.SPOTIFY.MUSIC", "10.0.0.2", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Limit"))
+	assert.JSONEq(t, `{"error":"rate limit exceeded"}`, rec.Body.String())
+
+	// Without an app they fall back to the client IP
+	assert.Equal(t, http.StatusOK, do("", "10.0.0.1", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("", "10.0.0.1", nil).Code)
+
+	// A key gets its own bucket, sized by its publisher's tier
+	key := &auth.Key{ID: "k1", Publisher: "gametion"}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do("com.spotify.music", "10.0.0.3", key).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, do("com.spotify.music", "10.0.0.3", key).Code)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, do("com.spotify.music", "10.0.0.3", key).Code)
+}
+
+func TestMiddlewareForgedIP(t *testing.T) {
+	cfg := &Config{
+		DefaultTier: "standard",
+		Tiers: map[string]Limit{
+			"standard": {Rate: 1, Burst: 1},
+			"internal": {},
+		},
+		Assignments: map[string]string{"ip:127.0.0.1": "internal"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	l := NewLimiter(cfg, NewMemoryStore(), nil)
+	l.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	// As behind a trusted proxy, the client IP comes from forwarded headers
+	h := middleware.RealIP(l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	do := func(app string, key *auth.Key) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/delivery?app="+app, nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Set("X-Forwarded-For", "127.0.0.1")
+		if key != nil {
+			req = req.WithContext(auth.NewContext(req.Context(), key))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Key and app buckets keep their own tier whatever IP is claimed
+	key := &auth.Key{ID: "k1"}
+	assert.Equal(t, http.StatusOK, do("com.spotify.music", key))
+	assert.Equal(t, http.StatusTooManyRequests, do("com.spotify.music", key))
+	assert.Equal(t, http.StatusOK, do("com.spotify.music", nil))
+	assert.Equal(t, http.StatusTooManyRequests, do("com.spotify.music", nil))
+
+	// Only IP buckets use IP assignments
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do("", nil))
+	}
+}
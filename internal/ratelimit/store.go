@@ -0,0 +1,158 @@
+// Package ratelimit throttles delivery traffic with per-subject token
+// buckets, so one misbehaving integration cannot saturate the database for
+// everyone else.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: Rate tokens are added per second up to Burst.
+// A Rate of zero or less means unlimited.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Unlimited reports whether the limit never rejects.
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// Decision is the outcome of taking one token.
+type Decision struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left after this request.
+	Remaining int
+	// RetryAfter is how long until a token is available when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store holds limiter state. MemoryStore keeps buckets in process; a shared
+// store (e.g. Redis) can implement the same interface so several servers
+// enforce one budget.
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// MemoryStore is an in-process Store. Buckets are sharded to keep lock
+// contention low under concurrent traffic.
+type MemoryStore struct {
+	shards [shardCount]shard
+}
+
+const shardCount = 64
+
+type shard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// NewMemoryStore creates an empty in-process store.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	for i := range s.shards {
+		s.shards[i].buckets = make(map[string]*bucket)
+	}
+	return s
+}
+
+// Take removes one token from key's bucket, creating a full bucket on first
+// use. A bucket whose limit changed (e.g. after a tier reassignment) keeps
+// its tokens, capped at the new burst.
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (Decision, error) {
+	if limit.Unlimited() {
+		return Decision{Allowed: true, Remaining: limit.Burst}, nil
+	}
+
+	sh := &s.shards[fnv32(key)%shardCount]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	b, ok := sh.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		sh.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return Decision{Allowed: true, Remaining: int(b.tokens)}, nil
+	}
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return Decision{RetryAfter: wait}, nil
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.updated = now
+	}
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+}
+
+// Len returns the number of tracked buckets.
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.buckets)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// Sweep drops buckets that have refilled completely; they are equivalent to
+// a fresh bucket, so forgetting them is invisible to clients.
+func (s *MemoryStore) Sweep(now time.Time) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for key, b := range sh.buckets {
+			b.refill(now)
+			if b.tokens >= float64(b.limit.Burst) {
+				delete(sh.buckets, key)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// Run sweeps idle buckets every interval until ctx is cancelled.
+func (s *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Sweep(now)
+		}
+	}
+}
+
+// fnv32 is FNV-1a, inlined to avoid allocating a hash.Hash per request.
+func fnv32(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
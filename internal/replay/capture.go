@@ -0,0 +1,261 @@
+// Package replay captures delivery requests to NDJSON and replays them
+// against two targets, reporting where their matched campaigns differ.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Record is one captured delivery request, as normalised by the handler,
+// and the campaigns it was answered with.
+type Record struct {
+	Time      time.Time              `json:"time"`
+	API       string                 `json:"api"`
+	Request   models.DeliveryRequest `json:"request"`
+	Status    int                    `json:"status"`
+	Campaigns []string               `json:"campaigns"`
+	LatencyMS float64                `json:"latency_ms"`
+}
+
+// maxPending bounds the records held between flushes; later ones are
+// dropped so a slow disk never slows delivery down.
+const maxPending = 10000
+
+// Recorder captures a sampled fraction of delivery requests and writes
+// them as NDJSON. Records are buffered in memory and written by Flush.
+//
+// Unless configured otherwise, device IDs are replaced by a keyed hash and
+// coordinates are rounded to CoordinatePrecision decimals before a record
+// is kept. The hash key is drawn when the recorder is created, so a device
+// keeps one pseudonym while the server runs and cannot be looked up from
+// the file.
+type Recorder struct {
+	rate   float64
+	random func() float64
+
+	rawDeviceIDs    bool
+	preciseLocation bool
+	deviceKey       []byte
+
+	// wmu serialises flushes
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	mu      sync.Mutex
+	pending []Record
+	dropped int
+}
+
+// CoordinatePrecision is the number of decimals captured coordinates are
+// rounded to, about 1 km.
+const CoordinatePrecision = 2
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithRawDeviceIDs captures device IDs as received instead of hashing
+// them.
+func WithRawDeviceIDs() RecorderOption {
+	return func(r *Recorder) {
+		r.rawDeviceIDs = true
+	}
+}
+
+// WithPreciseLocation captures coordinates as received instead of rounding
+// them.
+func WithPreciseLocation() RecorderOption {
+	return func(r *Recorder) {
+		r.preciseLocation = true
+	}
+}
+
+// NewRecorder creates a recorder writing to w, capturing each request with
+// probability rate.
+func NewRecorder(w io.Writer, rate float64, opts ...RecorderOption) *Recorder {
+	r := &Recorder{w: bufio.NewWriter(w), rate: rate, random: rand.Float64, deviceKey: make([]byte, 32)}
+	// Never fails since Go 1.24
+	crand.Read(r.deviceKey)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// redact returns req without what the recorder is not configured to keep.
+func (r *Recorder) redact(req models.DeliveryRequest) models.DeliveryRequest {
+	if req.DeviceID != "" && !r.rawDeviceIDs {
+		mac := hmac.New(sha256.New, r.deviceKey)
+		mac.Write([]byte(req.DeviceID))
+		req.DeviceID = hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+	if !r.preciseLocation {
+		req.Lat, req.Lon = coarsen(req.Lat), coarsen(req.Lon)
+	}
+	return req
+}
+
+// coarsen rounds a coordinate to CoordinatePrecision decimals.
+func coarsen(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	scale := math.Pow10(CoordinatePrecision)
+	rounded := math.Round(*v*scale) / scale
+	return &rounded
+}
+
+type captureKey struct{}
+
+// capture holds what the handler reports about a sampled request.
+type capture struct {
+	mu        sync.Mutex
+	set       bool
+	req       models.DeliveryRequest
+	campaigns []string
+}
+
+// Capture reports the request being answered and the campaigns served.
+// It does nothing unless the request was sampled by Middleware.
+func Capture(ctx context.Context, req models.DeliveryRequest, served []models.Campaign) {
+	c, ok := ctx.Value(captureKey{}).(*capture)
+	if !ok {
+		return
+	}
+	ids := campaignIDs(served)
+	c.mu.Lock()
+	c.set, c.req, c.campaigns = true, req, ids
+	c.mu.Unlock()
+}
+
+// Middleware samples requests of API version api for capture. A sampled
+// request is recorded once answered, if its handler called Capture.
+func (r *Recorder) Middleware(api string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if r.random() >= r.rate {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			start := time.Now()
+			c := &capture{}
+			ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+			next.ServeHTTP(ww, req.WithContext(context.WithValue(req.Context(), captureKey{}, c)))
+
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if !c.set {
+				return
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			r.add(Record{
+				Time:      start.UTC(),
+				API:       api,
+				Request:   r.redact(c.req),
+				Status:    status,
+				Campaigns: c.campaigns,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			})
+		})
+	}
+}
+
+func (r *Recorder) add(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) >= maxPending {
+		r.dropped++
+		return
+	}
+	r.pending = append(r.pending, rec)
+}
+
+// Flush writes the pending records.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	pending, dropped := r.pending, r.dropped
+	r.pending, r.dropped = nil, 0
+	r.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("dropped captured delivery requests", "dropped", dropped)
+	}
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	enc := json.NewEncoder(r.w)
+	for _, rec := range pending {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return r.w.Flush()
+}
+
+// Run flushes every interval until ctx is cancelled, then flushes once
+// more.
+func (r *Recorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.Flush(); err != nil {
+				slog.Error("failed to write captured delivery requests", logging.Err(err))
+			}
+			return
+		case <-ticker.C:
+			if err := r.Flush(); err != nil {
+				slog.Error("failed to write captured delivery requests", logging.Err(err))
+			}
+		}
+	}
+}
+
+// Reader reads records written by a Recorder.
+type Reader struct {
+	dec  *json.Decoder
+	line int
+}
+
+// NewReader reads NDJSON records from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next record and its 1-based position, or io.EOF after
+// the last one.
+func (r *Reader) Next() (Record, int, error) {
+	var rec Record
+	if err := r.dec.Decode(&rec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Record{}, 0, io.EOF
+		}
+		return Record{}, 0, fmt.Errorf("record %d: %w", r.line+1, err)
+	}
+	r.line++
+	return rec, r.line, nil
+}
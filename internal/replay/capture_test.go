@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func TestRecorder(t *testing.T) {
+	req := models.DeliveryRequest{App: "com.test", Country: "us", OS: "android", DeviceID: "device-1"}
+	spotify := []models.Campaign{{ID: "spotify"}, {ID: "duolingo"}}
+
+	tests := []struct {
+		name    string
+		random  float64
+		capture bool
+		status  int
+		want    bool
+	}{
+		{name: "sampled", random: 0.05, capture: true, status: http.StatusOK, want: true},
+		{name: "not sampled", random: 0.5, capture: true, status: http.StatusOK},
+		{name: "not captured by the handler", random: 0.05, status: http.StatusBadRequest},
+		{name: "no content", random: 0.05, capture: true, status: http.StatusNoContent, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			rec := NewRecorder(&buf, 0.1, WithRawDeviceIDs())
+			rec.random = func() float64 { return tt.random }
+
+			handler := rec.Middleware("v1")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.capture {
+					served := spotify
+					if tt.status == http.StatusNoContent {
+						served = nil
+					}
+					Capture(r.Context(), req, served)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/delivery", nil))
+			require.NoError(t, rec.Flush())
+
+			r := NewReader(&buf)
+			got, n, err := r.Next()
+			if !tt.want {
+				assert.ErrorIs(t, err, io.EOF)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			assert.Equal(t, "v1", got.API)
+			assert.Equal(t, req, got.Request)
+			assert.Equal(t, tt.status, got.Status)
+			assert.False(t, got.Time.IsZero())
+			if tt.status == http.StatusOK {
+				assert.Equal(t, []string{"spotify", "duolingo"}, got.Campaigns)
+			} else {
+				assert.Empty(t, got.Campaigns)
+			}
+			_, _, err = r.Next()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestRecorderRedact(t *testing.T) {
+	lat, lon := 40.712776, -74.005974
+	coarseLat, coarseLon := 40.71, -74.01
+	req := models.DeliveryRequest{App: "com.test", Country: "us", OS: "android", Lat: &lat, Lon: &lon, DeviceID: "device-1"}
+
+	tests := []struct {
+		name      string
+		opts      []RecorderOption
+		wantRawID bool
+		wantLat   float64
+		wantLon   float64
+	}{
+		{name: "default", wantLat: coarseLat, wantLon: coarseLon},
+		{name: "raw device ids", opts: []RecorderOption{WithRawDeviceIDs()}, wantRawID: true, wantLat: coarseLat, wantLon: coarseLon},
+		{name: "precise location", opts: []RecorderOption{WithPreciseLocation()}, wantLat: lat, wantLon: lon},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := NewRecorder(io.Discard, 1, tt.opts...)
+			got := rec.redact(req)
+
+			if tt.wantRawID {
+				assert.Equal(t, "device-1", got.DeviceID)
+			} else {
+				assert.Len(t, got.DeviceID, 32)
+				assert.NotContains(t, got.DeviceID, "device-1")
+				assert.Equal(t, got.DeviceID, rec.redact(req).DeviceID, "stable within a recorder")
+				assert.NotEqual(t, got.DeviceID, NewRecorder(io.Discard, 1).redact(req).DeviceID, "keyed per recorder")
+			}
+			require.NotNil(t, got.Lat)
+			require.NotNil(t, got.Lon)
+			assert.InDelta(t, tt.wantLat, *got.Lat, 1e-9)
+			assert.InDelta(t, tt.wantLon, *got.Lon, 1e-9)
+			assert.Equal(t, 40.712776, lat, "the request is not modified")
+		})
+	}
+
+	noLocation := NewRecorder(io.Discard, 1).redact(models.DeliveryRequest{App: "com.test"})
+	assert.Nil(t, noLocation.Lat)
+	assert.Empty(t, noLocation.DeviceID)
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf, 1)
+	for i := 0; i < maxPending+5; i++ {
+		rec.add(Record{API: "v2"})
+	}
+	assert.Equal(t, 5, rec.dropped)
+	require.NoError(t, rec.Flush())
+	assert.Equal(t, maxPending, bytes.Count(buf.Bytes(), []byte("\n")))
+	assert.Zero(t, rec.dropped)
+}
+
+func TestReaderInvalidRecord(t *testing.T) {
+	r := NewReader(bytes.NewBufferString(`{"api":"v1"}` + "\n" + `{"api":`))
+	_, _, err := r.Next()
+	require.NoError(t, err)
+	_, _, err = r.Next()
+	assert.ErrorContains(t, err, "record 2")
+}
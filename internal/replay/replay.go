@@ -0,0 +1,285 @@
+package replay
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/latency"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// Target answers a delivery request with the IDs of the campaigns it
+// returned.
+type Target interface {
+	Deliver(ctx context.Context, req models.DeliveryRequest) ([]string, error)
+}
+
+// TargetFunc adapts a function to a Target.
+type TargetFunc func(ctx context.Context, req models.DeliveryRequest) ([]string, error)
+
+func (f TargetFunc) Deliver(ctx context.Context, req models.DeliveryRequest) ([]string, error) {
+	return f(ctx, req)
+}
+
+// SQLTarget matches with per-request queries, as /v1 and /v2 delivery do,
+// reading audience segments through segs.
+func SQLTarget(db *sql.DB, segs *segments.Cache) Target {
+	return TargetFunc(func(ctx context.Context, req models.DeliveryRequest) ([]string, error) {
+		matched, err := campaigns.MatchCampaigns(ctx, db, segs, req)
+		return campaignIDs(matched), err
+	})
+}
+
+// MatcherTarget matches against an in-memory matcher, as delivery does
+// from the snapshot while the database is down.
+func MatcherTarget(m *targeting.Matcher) Target {
+	return TargetFunc(func(_ context.Context, req models.DeliveryRequest) ([]string, error) {
+		return campaignIDs(m.Match(req)), nil
+	})
+}
+
+// HTTPTarget sends requests to a delivery endpoint such as
+// http://localhost:8080/v2/delivery, authenticating with apiKey if set.
+// Its answers include creative selection and, if enabled, the auction.
+func HTTPTarget(client *http.Client, endpoint, apiKey string) Target {
+	return TargetFunc(func(ctx context.Context, req models.DeliveryRequest) ([]string, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+Query(req).Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if apiKey != "" {
+			httpReq.Header.Set("X-API-Key", apiKey)
+		}
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+			return nil, nil
+		case http.StatusOK:
+			var matched []models.Campaign
+			if err := json.NewDecoder(resp.Body).Decode(&matched); err != nil {
+				return nil, fmt.Errorf("invalid response: %w", err)
+			}
+			return campaignIDs(matched), nil
+		default:
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return nil, fmt.Errorf("status %d: %s", resp.StatusCode, body)
+		}
+	})
+}
+
+// Query encodes req as delivery query parameters.
+func Query(req models.DeliveryRequest) url.Values {
+	q := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	set("app", req.App)
+	set("country", req.Country)
+	set("os", req.OS)
+	set("region", req.Region)
+	set("city", req.City)
+	if req.Lat != nil && req.Lon != nil {
+		q.Set("lat", strconv.FormatFloat(*req.Lat, 'f', -1, 64))
+		q.Set("lon", strconv.FormatFloat(*req.Lon, 'f', -1, 64))
+	}
+	set("device_id", req.DeviceID)
+	set("format", req.Format)
+	set("size", req.Size)
+	set("placement_id", req.PlacementID)
+	return q
+}
+
+// Diff describes a request the two targets answered differently.
+type Diff struct {
+	Record  int                    `json:"record"`
+	Request models.DeliveryRequest `json:"request"`
+	OnlyA   []string               `json:"only_a,omitempty"`
+	OnlyB   []string               `json:"only_b,omitempty"`
+	ErrA    string                 `json:"error_a,omitempty"`
+	ErrB    string                 `json:"error_b,omitempty"`
+}
+
+// Report summarises a replay. Requests either target failed on count as
+// errors, not as differences.
+type Report struct {
+	Requests  int  `json:"requests"`
+	Identical int  `json:"identical"`
+	Different int  `json:"different"`
+	Errors    int  `json:"errors"`
+	A         Side `json:"a"`
+	B         Side `json:"b"`
+}
+
+// Side summarises the answers of one target.
+type Side struct {
+	Target  string          `json:"target"`
+	Errors  int             `json:"errors"`
+	Latency latency.Summary `json:"latency"`
+	// Only counts, per campaign, the requests where only this target
+	// returned it.
+	Only map[string]int `json:"only"`
+}
+
+// Options controls a replay.
+type Options struct {
+	// Concurrency is the number of requests in flight (default 1).
+	Concurrency int
+	// Limit stops after this many records (0: all).
+	Limit int
+	// OnDiff is called, one call at a time, for each request answered
+	// differently or failed by a target.
+	OnDiff func(Diff)
+}
+
+// Compare sends every record read from records to both a and b and
+// compares the sets of campaigns they return.
+func Compare(ctx context.Context, records *Reader, a, b Target, opts Options) (*Report, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	type job struct {
+		n   int
+		rec Record
+	}
+	jobs := make(chan job)
+	report := &Report{A: Side{Only: map[string]int{}}, B: Side{Only: map[string]int{}}}
+	var latA, latB latency.Recorder
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				idsA, errA := timed(ctx, a, j.rec.Request, &latA)
+				idsB, errB := timed(ctx, b, j.rec.Request, &latB)
+				d := Diff{Record: j.n, Request: j.rec.Request}
+				if errA == nil && errB == nil {
+					d.OnlyA, d.OnlyB = difference(idsA, idsB), difference(idsB, idsA)
+				}
+				if errA != nil {
+					d.ErrA = errA.Error()
+				}
+				if errB != nil {
+					d.ErrB = errB.Error()
+				}
+
+				mu.Lock()
+				report.add(d)
+				if opts.OnDiff != nil && !d.identical() {
+					opts.OnDiff(d)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var readErr error
+	for n := 0; opts.Limit == 0 || n < opts.Limit; n++ {
+		rec, line, err := records.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		select {
+		case jobs <- job{n: line, rec: rec}:
+		case <-ctx.Done():
+			readErr = ctx.Err()
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.A.Latency = latA.Summary()
+	report.B.Latency = latB.Summary()
+	return report, readErr
+}
+
+func (r *Report) add(d Diff) {
+	r.Requests++
+	switch {
+	case d.ErrA != "" || d.ErrB != "":
+		r.Errors++
+		if d.ErrA != "" {
+			r.A.Errors++
+		}
+		if d.ErrB != "" {
+			r.B.Errors++
+		}
+	case d.identical():
+		r.Identical++
+	default:
+		r.Different++
+		for _, id := range d.OnlyA {
+			r.A.Only[id]++
+		}
+		for _, id := range d.OnlyB {
+			r.B.Only[id]++
+		}
+	}
+}
+
+func (d Diff) identical() bool {
+	return len(d.OnlyA) == 0 && len(d.OnlyB) == 0 && d.ErrA == "" && d.ErrB == ""
+}
+
+// timed calls t and records its latency.
+func timed(ctx context.Context, t Target, req models.DeliveryRequest, lat *latency.Recorder) ([]string, error) {
+	start := time.Now()
+	ids, err := t.Deliver(ctx, req)
+	lat.Observe(time.Since(start))
+	return ids, err
+}
+
+// difference returns the sorted IDs in a but not in b.
+func difference(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, id := range b {
+		in[id] = true
+	}
+	var out []string
+	for _, id := range a {
+		if !in[id] {
+			out = append(out, id)
+			in[id] = true
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func campaignIDs(list []models.Campaign) []string {
+	ids := make([]string, len(list))
+	for i, c := range list {
+		ids[i] = c.ID
+	}
+	return ids
+}
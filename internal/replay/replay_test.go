@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// captureOf encodes records as a capture file.
+func captureOf(t *testing.T, reqs ...models.DeliveryRequest) *Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, req := range reqs {
+		require.NoError(t, enc.Encode(Record{API: "v1", Request: req}))
+	}
+	return NewReader(&buf)
+}
+
+func TestCompare(t *testing.T) {
+	us := models.DeliveryRequest{App: "com.test", Country: "us", OS: "android"}
+	de := models.DeliveryRequest{App: "com.test", Country: "de", OS: "android"}
+	in := models.DeliveryRequest{App: "com.test", Country: "in", OS: "ios"}
+
+	a := TargetFunc(func(_ context.Context, req models.DeliveryRequest) ([]string, error) {
+		switch req.Country {
+		case "us":
+			return []string{"spotify", "duolingo"}, nil
+		case "de":
+			return []string{"spotify"}, nil
+		}
+		return nil, nil
+	})
+	b := TargetFunc(func(_ context.Context, req models.DeliveryRequest) ([]string, error) {
+		switch req.Country {
+		case "us":
+			return []string{"duolingo", "spotify"}, nil
+		case "de":
+			return []string{"subwaysurfer"}, nil
+		}
+		return nil, errors.New("status 500")
+	})
+
+	var diffs []Diff
+	report, err := Compare(context.Background(), captureOf(t, us, de, in), a, b, Options{
+		Concurrency: 2,
+		OnDiff:      func(d Diff) { diffs = append(diffs, d) },
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.Requests)
+	assert.Equal(t, 1, report.Identical, "order does not matter")
+	assert.Equal(t, 1, report.Different)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 0, report.A.Errors)
+	assert.Equal(t, 1, report.B.Errors)
+	assert.Equal(t, map[string]int{"spotify": 1}, report.A.Only)
+	assert.Equal(t, map[string]int{"subwaysurfer": 1}, report.B.Only)
+	assert.Equal(t, 3, report.A.Latency.Count)
+	assert.Equal(t, 3, report.B.Latency.Count)
+
+	require.Len(t, diffs, 2)
+	byRecord := map[int]Diff{}
+	for _, d := range diffs {
+		byRecord[d.Record] = d
+	}
+	assert.Equal(t, Diff{Record: 2, Request: de, OnlyA: []string{"spotify"}, OnlyB: []string{"subwaysurfer"}}, byRecord[2])
+	assert.Equal(t, Diff{Record: 3, Request: in, ErrB: "status 500"}, byRecord[3])
+}
+
+func TestCompareLimit(t *testing.T) {
+	req := models.DeliveryRequest{App: "com.test", Country: "us", OS: "android"}
+	none := TargetFunc(func(context.Context, models.DeliveryRequest) ([]string, error) { return nil, nil })
+
+	report, err := Compare(context.Background(), captureOf(t, req, req, req), none, none, Options{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Requests)
+	assert.Equal(t, 2, report.Identical)
+}
+
+func TestMatcherTarget(t *testing.T) {
+	m := targeting.NewMatcher(
+		[]models.Campaign{{ID: "spotify", Status: "ACTIVE"}, {ID: "duolingo", Status: "ACTIVE"}},
+		[]models.TargetingRule{
+			{CampaignID: "spotify", IncludeCountry: []string{"us"}},
+			{CampaignID: "duolingo", IncludeOS: []string{"ios"}},
+		})
+	ids, err := MatcherTarget(m).Deliver(context.Background(), models.DeliveryRequest{App: "com.test", Country: "us", OS: "android"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spotify"}, ids)
+}
+
+func TestHTTPTarget(t *testing.T) {
+	lat, lon := 12.97, 77.59
+	req := models.DeliveryRequest{App: "com.test", Country: "in", OS: "android", Lat: &lat, Lon: &lon, DeviceID: "device-1"}
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    []string
+		wantErr string
+	}{
+		{name: "campaigns", status: http.StatusOK, body: `[{"cid":"spotify"},{"cid":"duolingo"}]`, want: []string{"spotify", "duolingo"}},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "server error", status: http.StatusInternalServerError, body: `{"error":"internal server error"}`, wantErr: "status 500"},
+		{name: "invalid body", status: http.StatusOK, body: `{`, wantErr: "invalid response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v2/delivery", r.URL.Path)
+				assert.Equal(t, "app=com.test&country=in&device_id=device-1&lat=12.97&lon=77.59&os=android", r.URL.RawQuery)
+				assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ids, err := HTTPTarget(srv.Client(), srv.URL+"/v2/delivery", "secret").Deliver(context.Background(), req)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package segments
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// ParseIDs reads device IDs from a plain-text (one per line) or CSV upload.
+// For CSV the first column is used. Blank lines, '#' comments and a leading
+// "device_id"/"id" header are skipped.
+func ParseIDs(r io.Reader) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	var ids []string
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		id := strings.TrimSpace(rec[0])
+		if first && isHeader(id) {
+			continue
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+}
+
+func isHeader(field string) bool {
+	switch strings.ToLower(field) {
+	case "device_id", "deviceid", "id", "ifa", "gaid", "idfa":
+		return true
+	}
+	return false
+}
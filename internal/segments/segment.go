@@ -0,0 +1,192 @@
+package segments
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Kind selects the membership structure backing a segment.
+type Kind string
+
+const (
+	// KindExact stores a sorted set of 64-bit fingerprints (8 bytes per ID).
+	// False positives need a full 64-bit collision and are negligible.
+	KindExact Kind = "exact"
+	// KindBloom stores a bloom filter sized for the segment's FPRate.
+	KindBloom Kind = "bloom"
+)
+
+// HashType is the hash applied to device IDs in an uploaded list. Request
+// device IDs are hashed the same way before the membership check.
+type HashType string
+
+const (
+	HashNone   HashType = "none"
+	HashSHA256 HashType = "sha256"
+	HashMD5    HashType = "md5"
+)
+
+// DefaultFPRate is the bloom filter false-positive rate used when none is given.
+const DefaultFPRate = 0.001
+
+// Segment is a named audience of device IDs.
+type Segment struct {
+	Name      string    `json:"name"`
+	Kind      Kind      `json:"kind"`
+	Hash      HashType  `json:"hash"`
+	FPRate    float64   `json:"fp_rate,omitempty"`
+	Size      int64     `json:"size"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	set memberSet
+}
+
+// memberSet is implemented by the exact set and the bloom filter.
+type memberSet interface {
+	has(fingerprint uint64) bool
+	marshal() []byte
+}
+
+// Options control how Build stores a segment.
+type Options struct {
+	Kind   Kind
+	Hash   HashType
+	FPRate float64
+}
+
+// Validate fills in defaults and rejects unknown kinds, hashes or rates.
+func (o *Options) Validate() error {
+	if o.Kind == "" {
+		o.Kind = KindExact
+	}
+	if o.Hash == "" {
+		o.Hash = HashNone
+	}
+	switch o.Kind {
+	case KindExact:
+		o.FPRate = 0
+	case KindBloom:
+		if o.FPRate == 0 {
+			o.FPRate = DefaultFPRate
+		}
+		if o.FPRate <= 0 || o.FPRate >= 1 || math.IsNaN(o.FPRate) {
+			return fmt.Errorf("fp_rate must be between 0 and 1, got %v", o.FPRate)
+		}
+	default:
+		return fmt.Errorf("unknown segment kind %q", o.Kind)
+	}
+	switch o.Hash {
+	case HashNone, HashSHA256, HashMD5:
+	default:
+		return fmt.Errorf("unknown hash type %q", o.Hash)
+	}
+	return nil
+}
+
+// Build creates a segment from uploaded IDs, which must already be hashed
+// with opts.Hash when it is not HashNone.
+func Build(name string, ids []string, opts Options) (*Segment, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	fingerprints := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		if id = normalizeID(id); id != "" {
+			fingerprints = append(fingerprints, xxhash.Sum64String(id))
+		}
+	}
+
+	s := &Segment{Name: name, Kind: opts.Kind, Hash: opts.Hash, FPRate: opts.FPRate}
+	switch opts.Kind {
+	case KindBloom:
+		s.set = newBloomFilter(fingerprints, opts.FPRate)
+	default:
+		s.set = newExactSet(fingerprints)
+	}
+	s.Size = int64(len(fingerprints))
+	return s, nil
+}
+
+// Contains reports whether the raw device ID belongs to the segment. Raw
+// IDs compare case-insensitively. Hashed lists are digests of IDs in the
+// case the uploader had them, upper-case for IDFAs and lower-case for GAIDs
+// by convention, so the ID is hashed as sent, then upper- and lower-cased.
+func (s *Segment) Contains(deviceID string) bool {
+	if s == nil || s.set == nil {
+		return false
+	}
+	id := strings.TrimSpace(deviceID)
+	if id == "" {
+		return false
+	}
+	if s.Hash == HashNone {
+		return s.set.has(xxhash.Sum64String(normalizeID(id)))
+	}
+	for i, variant := range []string{id, strings.ToUpper(id), strings.ToLower(id)} {
+		if i > 0 && variant == id {
+			continue
+		}
+		if s.set.has(xxhash.Sum64String(s.digest(variant))) {
+			return true
+		}
+	}
+	return false
+}
+
+// digest returns the lower-case hex digest of id under the segment's hash.
+func (s *Segment) digest(id string) string {
+	switch s.Hash {
+	case HashSHA256:
+		sum := sha256.Sum256([]byte(id))
+		return hex.EncodeToString(sum[:])
+	case HashMD5:
+		sum := md5.Sum([]byte(id))
+		return hex.EncodeToString(sum[:])
+	}
+	return id
+}
+
+// normalizeID lower-cases uploaded raw IDs and hex digests, and request
+// IDs checked against raw lists, so that they agree regardless of case.
+// Request IDs are never lower-cased before hashing.
+func normalizeID(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// decode restores the membership structure from its stored bytes.
+func (s *Segment) decode(data []byte) error {
+	var err error
+	switch s.Kind {
+	case KindBloom:
+		s.set, err = unmarshalBloomFilter(data)
+	case KindExact:
+		s.set, err = unmarshalExactSet(data)
+	default:
+		err = fmt.Errorf("unknown segment kind %q", s.Kind)
+	}
+	return err
+}
+
+// Members returns the encoded membership structure, as stored in the
+// database, for persisting the segment elsewhere.
+func (s *Segment) Members() []byte {
+	if s == nil || s.set == nil {
+		return nil
+	}
+	return s.set.marshal()
+}
+
+// SetMembers restores the membership structure from bytes returned by
+// Members. s.Kind must already be set.
+func (s *Segment) SetMembers(data []byte) error {
+	return s.decode(data)
+}
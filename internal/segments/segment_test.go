@@ -0,0 +1,132 @@
+package segments
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"plain text", "a1\n\nB2\n  c3 \n", []string{"a1", "B2", "c3"}},
+		{"csv with header", "device_id,country\nx1,us\nx2,in\n", []string{"x1", "x2"}},
+		{"comments", "# exported 2026-10-01\nid1\n", []string{"id1"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, err := ParseIDs(strings.NewReader(tc.input))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ids)
+		})
+	}
+}
+
+func TestBuildExact(t *testing.T) {
+	seg, err := Build("buyers", []string{"AAAA-1", "bbbb-2", "aaaa-1"}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, KindExact, seg.Kind)
+	assert.Equal(t, HashNone, seg.Hash)
+
+	assert.True(t, seg.Contains("aaaa-1"))
+	assert.True(t, seg.Contains("BBBB-2"))
+	assert.False(t, seg.Contains("cccc-3"))
+	assert.False(t, seg.Contains(""))
+
+	// Round-trip through the stored representation
+	restored := &Segment{Kind: seg.Kind, Hash: seg.Hash}
+	require.NoError(t, restored.decode(seg.set.marshal()))
+	assert.True(t, restored.Contains("aaaa-1"))
+	assert.Len(t, restored.set, 2)
+}
+
+func TestBuildHashed(t *testing.T) {
+	digest := func(hash HashType, id string) string {
+		if hash == HashMD5 {
+			sum := md5.Sum([]byte(id))
+			return hex.EncodeToString(sum[:])
+		}
+		sum := sha256.Sum256([]byte(id))
+		return hex.EncodeToString(sum[:])
+	}
+	const (
+		gaid = "38400000-8cf0-11bd-b23e-10b96e40000d"
+		idfa = "6D92078A-8246-4BA4-AE5B-76104861E7DC"
+	)
+
+	tests := []struct {
+		name    string
+		hash    HashType
+		listed  string
+		request string
+		want    bool
+	}{
+		{name: "lower-case GAID list", hash: HashSHA256, listed: gaid, request: gaid, want: true},
+		{name: "lower-case list, upper-case request", hash: HashSHA256, listed: gaid, request: strings.ToUpper(gaid), want: true},
+		{name: "upper-case IDFA list", hash: HashSHA256, listed: idfa, request: idfa, want: true},
+		{name: "upper-case list, lower-case request", hash: HashSHA256, listed: idfa, request: strings.ToLower(idfa), want: true},
+		{name: "upper-case IDFA list, md5", hash: HashMD5, listed: idfa, request: " " + idfa + " ", want: true},
+		{name: "other device", hash: HashSHA256, listed: idfa, request: "6D92078A-8246-4BA4-AE5B-76104861E7DD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Digests may be uploaded in either case
+			seg, err := Build("hashed", []string{strings.ToUpper(digest(tt.hash, tt.listed))}, Options{Hash: tt.hash})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, seg.Contains(tt.request))
+		})
+	}
+}
+
+func TestBuildBloom(t *testing.T) {
+	const n = 20000
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("device-%d", i)
+	}
+
+	seg, err := Build("bloom", ids, Options{Kind: KindBloom, FPRate: 0.01})
+	require.NoError(t, err)
+
+	for _, id := range ids {
+		require.True(t, seg.Contains(id), "bloom filters have no false negatives")
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if seg.Contains(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, float64(falsePositives)/n, 0.02)
+
+	restored := &Segment{Kind: KindBloom}
+	require.NoError(t, restored.decode(seg.set.marshal()))
+	assert.True(t, restored.Contains("device-42"))
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := Options{Kind: KindBloom}
+	require.NoError(t, opts.Validate())
+	assert.Equal(t, DefaultFPRate, opts.FPRate)
+
+	assert.Error(t, (&Options{Kind: "hll"}).Validate())
+	assert.Error(t, (&Options{Hash: "sha1"}).Validate())
+	assert.Error(t, (&Options{Kind: KindBloom, FPRate: 1.5}).Validate())
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	assert.Error(t, (&Segment{Kind: KindExact}).decode([]byte{1, 2, 3}))
+	assert.Error(t, (&Segment{Kind: KindBloom}).decode([]byte{1, 2, 3}))
+}
@@ -0,0 +1,107 @@
+package segments
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// exactSet is a sorted, de-duplicated slice of fingerprints.
+type exactSet []uint64
+
+func newExactSet(fingerprints []uint64) exactSet {
+	s := slices.Clone(fingerprints)
+	slices.Sort(s)
+	return slices.Compact(s)
+}
+
+func (s exactSet) has(fp uint64) bool {
+	_, ok := slices.BinarySearch(s, fp)
+	return ok
+}
+
+func (s exactSet) marshal() []byte {
+	buf := make([]byte, 8*len(s))
+	for i, fp := range s {
+		binary.LittleEndian.PutUint64(buf[8*i:], fp)
+	}
+	return buf
+}
+
+func unmarshalExactSet(data []byte) (exactSet, error) {
+	if len(data)%8 != 0 {
+		return nil, errors.New("corrupt exact segment data")
+	}
+	s := make(exactSet, len(data)/8)
+	for i := range s {
+		s[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+	return s, nil
+}
+
+// bloomFilter uses Kirsch-Mitzenmacher double hashing over a single 64-bit
+// fingerprint to derive its k probe positions.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint32 // number of probes
+}
+
+func newBloomFilter(fingerprints []uint64, fpRate float64) *bloomFilter {
+	n := float64(max(len(fingerprints), 1))
+	m := uint64(math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := uint32(max(1, math.Round(float64(m)/n*math.Ln2)))
+
+	b := &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+	for _, fp := range fingerprints {
+		h1, h2 := fp, bits.RotateLeft64(fp, 32)|1
+		for i := uint32(0); i < k; i++ {
+			pos := (h1 + uint64(i)*h2) % m
+			b.bits[pos/64] |= 1 << (pos % 64)
+		}
+	}
+	return b
+}
+
+func (b *bloomFilter) has(fp uint64) bool {
+	h1, h2 := fp, bits.RotateLeft64(fp, 32)|1
+	for i := uint32(0); i < b.k; i++ {
+		pos := (h1 + uint64(i)*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// marshal layout: m (uint64) | k (uint32) | bit words (uint64 each).
+func (b *bloomFilter) marshal() []byte {
+	buf := make([]byte, 12+8*len(b.bits))
+	binary.LittleEndian.PutUint64(buf, b.m)
+	binary.LittleEndian.PutUint32(buf[8:], b.k)
+	for i, w := range b.bits {
+		binary.LittleEndian.PutUint64(buf[12+8*i:], w)
+	}
+	return buf
+}
+
+func unmarshalBloomFilter(data []byte) (*bloomFilter, error) {
+	if len(data) < 12 || (len(data)-12)%8 != 0 {
+		return nil, errors.New("corrupt bloom segment data")
+	}
+	b := &bloomFilter{
+		m: binary.LittleEndian.Uint64(data),
+		k: binary.LittleEndian.Uint32(data[8:]),
+	}
+	b.bits = make([]uint64, (len(data)-12)/8)
+	if b.m == 0 || b.k == 0 || uint64(len(b.bits)) != (b.m+63)/64 {
+		return nil, errors.New("corrupt bloom segment data")
+	}
+	for i := range b.bits {
+		b.bits[i] = binary.LittleEndian.Uint64(data[12+8*i:])
+	}
+	return b, nil
+}
@@ -0,0 +1,213 @@
+package segments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+)
+
+const segmentColumns = `name, kind, hash, COALESCE(fp_rate, 0), size, version, updated_at`
+
+// Save creates or replaces a segment, bumping its version.
+func Save(db *sql.DB, s *Segment) error {
+	query := `
+	INSERT INTO segments (name, kind, hash, fp_rate, size, data, version, updated_at)
+	VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, 1, now())
+	ON CONFLICT (name) DO UPDATE SET
+		kind = EXCLUDED.kind, hash = EXCLUDED.hash, fp_rate = EXCLUDED.fp_rate,
+		size = EXCLUDED.size, data = EXCLUDED.data,
+		version = segments.version + 1, updated_at = now()
+	RETURNING version, updated_at
+	`
+	return db.QueryRow(query, s.Name, s.Kind, s.Hash, s.FPRate, s.Size, s.set.marshal()).
+		Scan(&s.Version, &s.UpdatedAt)
+}
+
+// Get returns a segment's metadata without loading its members.
+func Get(db *sql.DB, name string) (*Segment, error) {
+	query := `SELECT ` + segmentColumns + ` FROM segments WHERE name = $1`
+
+	var s Segment
+	err := db.QueryRow(query, name).Scan(&s.Name, &s.Kind, &s.Hash, &s.FPRate, &s.Size, &s.Version, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// List returns the metadata of every segment ordered by name.
+func List(db *sql.DB) ([]Segment, error) {
+	query := `SELECT ` + segmentColumns + ` FROM segments ORDER BY name`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Segment
+	for rows.Next() {
+		var s Segment
+		if err := rows.Scan(&s.Name, &s.Kind, &s.Hash, &s.FPRate, &s.Size, &s.Version, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// Delete removes a segment. It returns sql.ErrNoRows if it did not exist.
+func Delete(db *sql.DB, name string) error {
+	res, err := db.Exec(`DELETE FROM segments WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// load fetches a segment including its members.
+func load(ctx context.Context, db *sql.DB, name string) (*Segment, error) {
+	query := `SELECT ` + segmentColumns + `, data FROM segments WHERE name = $1`
+
+	var s Segment
+	var data []byte
+	err := db.QueryRowContext(ctx, query, name).Scan(&s.Name, &s.Kind, &s.Hash, &s.FPRate, &s.Size, &s.Version, &s.UpdatedAt, &data)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decode(data); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Cache keeps decoded segments in memory and only reloads a segment's
+// members when its version changes, so repeated syncs cost one small query.
+// Loads run outside the cache lock, and concurrent requests for the same
+// segment share one load. A nil *Cache loads every segment it is asked for.
+type Cache struct {
+	metrics *metrics.Registry
+	mu      sync.Mutex
+	entries map[string]*Segment
+	loading map[string]*call
+	// load fetches a segment with its members; tests replace it
+	load func(ctx context.Context, db *sql.DB, name string) (*Segment, error)
+}
+
+// call is a segment load in flight.
+type call struct {
+	done    chan struct{}
+	segment *Segment
+	err     error
+}
+
+// NewCache creates an empty cache that records the time of each segment
+// load in reg.
+func NewCache(reg *metrics.Registry) *Cache {
+	return &Cache{metrics: reg, entries: make(map[string]*Segment), loading: make(map[string]*call), load: load}
+}
+
+// Get returns the named segments, reloading any that changed. Unknown names
+// are omitted from the result. A nil names slice syncs every segment and
+// evicts deleted ones.
+func (c *Cache) Get(ctx context.Context, db *sql.DB, names []string) (map[string]*Segment, error) {
+	if names != nil && len(names) == 0 {
+		return map[string]*Segment{}, nil
+	}
+
+	query := `SELECT name, version FROM segments`
+	var args []interface{}
+	if names != nil {
+		query += ` WHERE name = ANY($1)`
+		args = append(args, pq.Array(names))
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var version int64
+		if err := rows.Scan(&name, &version); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		versions[name] = version
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]*Segment, len(versions))
+	for name, version := range versions {
+		s, err := c.segment(ctx, db, name, version)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // deleted since the version query
+		} else if err != nil {
+			return nil, err
+		}
+		out[name] = s
+	}
+	if names == nil && c != nil {
+		c.mu.Lock()
+		for name := range c.entries {
+			if _, ok := versions[name]; !ok {
+				delete(c.entries, name)
+			}
+		}
+		c.mu.Unlock()
+	}
+	return out, nil
+}
+
+// segment returns the named segment at version, loading it unless it is
+// cached. A caller finding a load of the segment in flight waits for it
+// instead of starting another.
+func (c *Cache) segment(ctx context.Context, db *sql.DB, name string, version int64) (*Segment, error) {
+	if c == nil {
+		return load(ctx, db, name)
+	}
+
+	c.mu.Lock()
+	if s, ok := c.entries[name]; ok && s.Version == version {
+		c.mu.Unlock()
+		return s, nil
+	}
+	if l, ok := c.loading[name]; ok {
+		c.mu.Unlock()
+		select {
+		case <-l.done:
+			return l.segment, l.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	l := &call{done: make(chan struct{})}
+	c.loading[name] = l
+	c.mu.Unlock()
+
+	start := time.Now()
+	l.segment, l.err = c.load(ctx, db, name)
+	c.metrics.ObserveDBQuery(time.Since(start).Seconds())
+
+	c.mu.Lock()
+	delete(c.loading, name)
+	if l.err == nil {
+		c.entries[name] = l.segment
+	}
+	c.mu.Unlock()
+	close(l.done)
+	return l.segment, l.err
+}
@@ -0,0 +1,89 @@
+package segments
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+)
+
+func TestCacheSegment(t *testing.T) {
+	reg := metrics.New()
+	c := NewCache(reg)
+	release := make(chan struct{})
+	var loads atomic.Int32
+	c.load = func(_ context.Context, _ *sql.DB, name string) (*Segment, error) {
+		loads.Add(1)
+		if name == "slow" {
+			<-release
+		}
+		return &Segment{Name: name, Version: 1}, nil
+	}
+
+	// Concurrent requests for a segment share its load
+	var wg sync.WaitGroup
+	got := make([]*Segment, 8)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := c.segment(context.Background(), nil, "slow", 1)
+			assert.NoError(t, err)
+			got[i] = s
+		}(i)
+	}
+
+	// Other segments load while it is in flight
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s, err := c.segment(context.Background(), nil, "fast", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "fast", s.Name)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow segment load blocked another segment")
+	}
+
+	// A waiter whose request ends stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.loading["slow"] != nil
+	}, 5*time.Second, time.Millisecond)
+	_, err := c.segment(ctx, nil, "slow", 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), loads.Load())
+	for _, s := range got {
+		assert.Same(t, got[0], s)
+	}
+
+	// Cached until the version changes
+	_, err = c.segment(context.Background(), nil, "slow", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), loads.Load())
+	_, err = c.segment(context.Background(), nil, "slow", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), loads.Load())
+
+	// Every load is a recorded query
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "db_query_duration_seconds_count 3\n")
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/breaker"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/segments"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/tracing"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/tracking"
+)
+
+// Fallback lets delivery keep serving while the database is down. The
+// breaker stops per-request queries after repeated failures; meanwhile, and
+// whenever a query fails, requests are answered from the snapshot-backed
+// service and marked stale. The zero value disables the fallback.
+type Fallback struct {
+	Breaker  *breaker.Breaker
+	Snapshot DeliveryService
+}
+
+// Config configures a Database. The zero value queries the database
+// without a deadline, runs no auctions and has no fallback.
+type Config struct {
+	// QueryTimeout is the deadline shared by the database queries of one
+	// request; 0 means none
+	QueryTimeout time.Duration
+	Auctions     auction.Config
+	Fallback     Fallback
+	// Segments caches the audience segments of per-request matching; nil
+	// loads them on every request
+	Segments *segments.Cache
+	// Metrics records queries, auctions and stale answers; nil records
+	// nothing
+	Metrics *metrics.Registry
+	// Tracking issues the tracking URLs of served campaigns; nil issues
+	// none
+	Tracking *tracking.Issuer
+}
+
+// Result is the answer to a delivery request.
+type Result struct {
+	Campaigns []models.Campaign
+	// Stale is set when the database could not be queried and the snapshot
+	// answered
+	Stale bool
+	// Details describe the auction and the fallback for the request log
+	Details []slog.Attr
+}
+
+// Database serves delivery with per-request queries, as /v1 and /v2 do.
+type Database struct {
+	db  *sql.DB
+	api string
+	cfg Config
+}
+
+// NewDatabase creates the database-backed delivery of API version api,
+// which labels its metrics.
+func NewDatabase(db *sql.DB, api string, cfg Config) *Database {
+	return &Database{db: db, api: api, cfg: cfg}
+}
+
+// Deliver matches req against the database unless the breaker is open,
+// falling back to the snapshot when the queries fail, and sets the tracking
+// URLs of the campaigns served. It returns ctx's error, without falling
+// back, once the request has ended.
+func (d *Database) Deliver(ctx context.Context, req models.DeliveryRequest) (Result, error) {
+	res, err := d.deliver(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	res.Campaigns, err = d.cfg.Tracking.Annotate(req, res.Campaigns)
+	if err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+func (d *Database) deliver(ctx context.Context, req models.DeliveryRequest) (Result, error) {
+	fallback, reg := d.cfg.Fallback, d.cfg.Metrics
+
+	var res Result
+	err := breaker.ErrOpen
+	if fallback.Breaker.Allow() {
+		queryCtx, cancel := ctx, context.CancelFunc(func() {})
+		if d.cfg.QueryTimeout > 0 {
+			queryCtx, cancel = context.WithTimeout(ctx, d.cfg.QueryTimeout)
+		}
+		res.Campaigns, res.Details, err = deliverFromDB(queryCtx, d.db, d.cfg.Segments, req, d.cfg.Auctions, reg)
+		timedOut := queryCtx.Err() != nil
+		cancel()
+
+		switch {
+		case CancelReason(ctx) != "":
+			// The request ended; that says nothing about the database
+		case err == nil || errors.Is(err, placements.ErrInvalid):
+			fallback.Breaker.Success()
+		default:
+			if timedOut {
+				reg.ObserveCancelled(d.api, CancelQueryTimeout)
+			}
+			fallback.Breaker.Failure()
+		}
+	}
+
+	// Nobody is waiting for an answer: the client went away or the
+	// timeout middleware has answered 504
+	if CancelReason(ctx) != "" {
+		return Result{}, ctx.Err()
+	}
+	if err == nil || errors.Is(err, placements.ErrInvalid) || fallback.Snapshot == nil {
+		return res, err
+	}
+
+	// Fall back to the last loaded snapshot
+	logging.AddAttrs(ctx, slog.String("db_error", err.Error()))
+	matched, err := fallback.Snapshot.Deliver(ctx, req)
+	if err != nil {
+		return Result{}, err
+	}
+	reg.ObserveStale(d.api)
+	return Result{Campaigns: matched, Stale: true, Details: []slog.Attr{slog.Bool("stale", true)}}, nil
+}
+
+// deliverFromDB matches req with per-request queries, picks creatives and
+// runs the auction, recording query times and the auction in reg. details
+// describe the auction for the request log.
+func deliverFromDB(ctx context.Context, db *sql.DB, segs *segments.Cache, req models.DeliveryRequest, auctions auction.Config, reg *metrics.Registry) (matched []models.Campaign, details []slog.Attr, err error) {
+	ctx, span := tracing.Start(ctx, "service.DeliverFromDB", RequestAttributes(req)...)
+	defer func() {
+		span.SetAttributes(attribute.Int("delivery.matched", len(matched)))
+		tracing.End(span, err)
+	}()
+
+	// Resolve the placement, if any, before matching
+	placement, err := placements.Resolve(req, func(id string) (*models.Placement, error) {
+		ctx, span := tracing.Start(ctx, "store.placements.Get")
+		p, err := placements.Get(ctx, db, id)
+		tracing.End(span, err)
+		return p, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Get matching campaigns and pick their creatives
+	queryCtx, query := tracing.Start(ctx, "store.campaigns.MatchCampaigns")
+	start := time.Now()
+	matched, err = campaigns.MatchCampaigns(queryCtx, db, segs, req)
+	reg.ObserveDBQuery(time.Since(start).Seconds())
+	tracing.End(query, err)
+	if err != nil || len(matched) == 0 {
+		return matched, nil, err
+	}
+	queryCtx, query = tracing.Start(ctx, "store.creatives.ListByCampaign")
+	byCampaign, err := creatives.ListByCampaign(queryCtx, db, reg, campaignIDs(matched))
+	tracing.End(query, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	matched = creatives.Assign(matched, byCampaign, creatives.SlotFor(req, placement), req.DeviceID)
+	if !auctions.Enabled || len(matched) == 0 {
+		return matched, nil, nil
+	}
+
+	queryCtx, query = tracing.Start(ctx, "store.placements.GetAppFloor")
+	appFloor, err := placements.GetAppFloor(queryCtx, db, targeting.NormalizeApp(req.App))
+	tracing.End(query, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := auction.Run(matched, placements.FloorFor(placement, appFloor), auctions.IncrementCPM)
+	reg.ObserveAuction(res.Outcome, res.Auction.PriceCPM)
+	return res.Campaigns(), AuctionLogAttrs(res), nil
+}
+
+func campaignIDs(list []models.Campaign) []string {
+	ids := make([]string, len(list))
+	for i, c := range list {
+		ids[i] = c.ID
+	}
+	return ids
+}
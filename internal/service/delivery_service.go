@@ -1,25 +1,158 @@
 package service
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/tracing"
+)
+
+// ErrSnapshotNotLoaded is returned while no campaign snapshot is available
+var ErrSnapshotNotLoaded = errors.New("campaign snapshot not loaded")
+
+// Reasons a delivery was cancelled, as counted by metrics.ObserveCancelled.
+const (
+	CancelClientClosed   = "client_closed"
+	CancelRequestTimeout = "request_timeout"
+	CancelQueryTimeout   = "query_timeout"
 )
 
+// CancelReason reports why the request context ctx ended, or "" if it has
+// not.
+func CancelReason(ctx context.Context) string {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return CancelClientClosed
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return CancelRequestTimeout
+	}
+	return ""
+}
+
 // DeliveryService defines the business logic for campaign delivery
  type DeliveryService interface {
-	Deliver(app, country, os string) ([]models.Campaign, error)
+	Deliver(ctx context.Context, req models.DeliveryRequest) ([]models.Campaign, error)
+	Explain(ctx context.Context, req models.DeliveryRequest) ([]targeting.Explanation, error)
  }
 
  type deliveryService struct {
-	db *sql.DB
+	snapshots *campaigns.SnapshotStore
+	auctions  auction.Config
+	metrics   *metrics.Registry
+ }
+
+// NewDeliveryService serves delivery from snapshots, recording matches and
+// auctions in reg. With auctions enabled Deliver returns only the auction
+// winner. It answers while the database is down (see Fallback), explains
+// matches and backs targetingctl.
+ func NewDeliveryService(snapshots *campaigns.SnapshotStore, auctions auction.Config, reg *metrics.Registry) DeliveryService {
+	return &deliveryService{snapshots: snapshots, auctions: auctions, metrics: reg}
  }
 
- func NewDeliveryService(db *sql.DB) DeliveryService {
-	return &deliveryService{db: db}
+ func (s *deliveryService) Deliver(ctx context.Context, req models.DeliveryRequest) (matched []models.Campaign, err error) {
+	ctx, span := tracing.Start(ctx, "service.Deliver", RequestAttributes(req)...)
+	defer func() {
+		span.SetAttributes(attribute.Int("delivery.matched", len(matched)))
+		tracing.End(span, err)
+	}()
+
+	// Skip the work for requests nobody is waiting for
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	snap := s.snapshots.Current()
+	if snap == nil {
+		return nil, ErrSnapshotNotLoaded
+	}
+	placement, err := placements.Resolve(req, snap.Placements.Get)
+	if err != nil {
+		return nil, err
+	}
+	slot := creatives.SlotFor(req, placement)
+	_, match := tracing.Start(ctx, "matcher.Match")
+	start := time.Now()
+	candidates := snap.Matcher.Match(req)
+	s.metrics.ObserveMatch(time.Since(start).Seconds(), len(candidates))
+	match.SetAttributes(attribute.Int("delivery.candidates", len(candidates)))
+	match.End()
+	matched = creatives.Assign(candidates, snap.Creatives, slot, req.DeviceID)
+	if !s.auctions.Enabled || len(matched) == 0 {
+		return matched, nil
+	}
+
+	floor := placements.FloorFor(placement, snap.AppFloors[targeting.NormalizeApp(req.App)])
+	res := auction.Run(matched, floor, s.auctions.IncrementCPM)
+	s.metrics.ObserveAuction(res.Outcome, res.Auction.PriceCPM)
+	logging.AddAttrs(ctx, AuctionLogAttrs(res)...)
+	return res.Campaigns(), nil
  }
 
- func (s *deliveryService) Deliver(app, country, os string) ([]models.Campaign, error) {
-	return campaigns.GetMatchingCampaigns(s.db, app, country, os)
- }
\ No newline at end of file
+func (s *deliveryService) Explain(ctx context.Context, req models.DeliveryRequest) (_ []targeting.Explanation, err error) {
+	_, span := tracing.Start(ctx, "service.Explain", RequestAttributes(req)...)
+	defer func() { tracing.End(span, err) }()
+
+	snap := s.snapshots.Current()
+	if snap == nil {
+		return nil, ErrSnapshotNotLoaded
+	}
+	placement, err := placements.Resolve(req, snap.Placements.Get)
+	if err != nil {
+		return nil, err
+	}
+	slot := creatives.SlotFor(req, placement)
+	explanations := snap.Matcher.Explain(req)
+	for i := range explanations {
+		e := &explanations[i]
+		if e.Matched && !creatives.Fits(snap.Creatives[e.CampaignID], slot) {
+			e.Matched = false
+			e.Reason = "no creative fits format/size/placement"
+		}
+	}
+	return explanations, nil
+}
+
+// LogAttrs describes a delivery request in its log record.
+func LogAttrs(req models.DeliveryRequest) []slog.Attr {
+	return []slog.Attr{
+		slog.String("app", req.App),
+		slog.String("country", req.Country),
+		slog.String("os", req.OS),
+	}
+}
+
+// AuctionLogAttrs describes an auction in the request log. Unlike ordinary
+// delivery responses, the log carries the bids behind the clearing price.
+func AuctionLogAttrs(res auction.Result) []slog.Attr {
+	return append([]slog.Attr{slog.String("auction", res.Outcome)}, auction.LogAttrs(res.Auction.PriceCPM, res.Auction.Diagnostics())...)
+}
+
+// ObserveServed counts an answered delivery request in reg towards fill
+// rate and the served campaigns of API version api.
+func ObserveServed(reg *metrics.Registry, api string, req models.DeliveryRequest, served []models.Campaign) {
+	ids := make([]string, len(served))
+	for i, c := range served {
+		ids[i] = c.ID
+	}
+	reg.ObserveServed(api, req.Country, req.OS, ids)
+}
+
+// RequestAttributes describes a delivery request on a span.
+func RequestAttributes(req models.DeliveryRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("delivery.app", req.App),
+		attribute.String("delivery.country", req.Country),
+		attribute.String("delivery.os", req.OS),
+	}
+}
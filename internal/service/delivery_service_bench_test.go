@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/campaigns"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/synth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+)
+
+// BenchmarkDeliver measures the whole v2 delivery path over a synthetic
+// snapshot: matching, creative assignment, metrics and, optionally, the
+// auction.
+func BenchmarkDeliver(b *testing.B) {
+	g := synth.NewGenerator(synth.DefaultDistribution(), 1)
+	reqs := make([]models.DeliveryRequest, 1024)
+	for i := range reqs {
+		reqs[i] = g.Next()
+	}
+
+	for _, size := range []int{10, 1000, 100000} {
+		snapshots := campaigns.NewSnapshotStore(nil, nil)
+		snapshots.Set(&campaigns.Snapshot{
+			Matcher:  targeting.NewMatcher(synth.Catalogue(size, 1)),
+			LoadedAt: time.Now(),
+		})
+		for _, enabled := range []bool{false, true} {
+			name := "campaigns=" + strconv.Itoa(size) + "/auction=" + strconv.FormatBool(enabled)
+			b.Run(name, func(b *testing.B) {
+				svc := NewDeliveryService(snapshots, auction.Config{Enabled: enabled, IncrementCPM: 0.01}, nil)
+				ctx := context.Background()
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := svc.Deliver(ctx, reqs[i%len(reqs)]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
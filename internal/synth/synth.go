@@ -0,0 +1,233 @@
+// Package synth generates deterministic synthetic campaign catalogues and
+// delivery request streams for benchmarks and load tests. The same sizes
+// and seeds always produce the same data, so runs can be compared.
+package synth
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Countries, OSes and the size of the app pool targeted by synthetic
+// catalogues and requested by DefaultDistribution. Earlier countries and
+// apps are more popular.
+var (
+	Countries = []string{
+		"us", "in", "br", "id", "de", "gb", "fr", "jp", "mx", "ru",
+		"tr", "it", "es", "ca", "kr", "ph", "vn", "th", "pk", "ng",
+		"eg", "ar", "pl", "sa", "au", "nl", "za", "co", "my", "bd",
+	}
+	OSes = []string{"android", "ios", "web"}
+)
+
+// AppCount is the number of apps in the synthetic app pool.
+const AppCount = 1000
+
+// App returns the i-th app of the synthetic pool.
+func App(i int) string {
+	return fmt.Sprintf("com.synth.app%04d", i)
+}
+
+// cities are geofence centres, as lat, lon.
+var cities = [][2]float64{
+	{40.7128, -74.0060}, {19.0760, 72.8777}, {-23.5505, -46.6333}, {52.5200, 13.4050},
+	{51.5074, -0.1278}, {35.6762, 139.6503}, {-6.2088, 106.8456}, {48.8566, 2.3522},
+}
+
+// Catalogue returns n ACTIVE campaigns and their rules. Rules mix
+// country, OS, exact and pattern app, and geofence targeting in
+// proportions meant to resemble a real catalogue: most rules target a few
+// apps and countries, so a request from DefaultDistribution matches a few
+// dozen of 100,000 campaigns.
+func Catalogue(n int, seed uint64) ([]models.Campaign, []models.TargetingRule) {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	campaigns := make([]models.Campaign, n)
+	var rules []models.TargetingRule
+
+	for i := range campaigns {
+		id := fmt.Sprintf("synth-%06d", i)
+		campaigns[i] = models.Campaign{
+			ID:     id,
+			Name:   "Synthetic campaign " + strconv.Itoa(i),
+			Img:    "https://example.com/" + id + ".png",
+			CTA:    "Install",
+			Status: "ACTIVE",
+			BidCPM: float64(10+rng.IntN(490)) / 100,
+		}
+		for r := 0; r < 1+rng.IntN(2); r++ {
+			rules = append(rules, rule(rng, id))
+		}
+	}
+	return campaigns, rules
+}
+
+func rule(rng *rand.Rand, campaignID string) models.TargetingRule {
+	r := models.TargetingRule{CampaignID: campaignID}
+	// Nearly every rule names its apps. Run-of-network rules would match
+	// most requests, so they are rare and narrow.
+	switch p := rng.Float64(); {
+	case p < 0.9:
+		for i := 0; i < 1+rng.IntN(2); i++ {
+			r.IncludeApp = append(r.IncludeApp, App(rng.IntN(AppCount)))
+		}
+	case p < 0.99:
+		// Matches the ten apps com.synth.appNNN0 … appNNN9
+		r.IncludeApp = []string{App(rng.IntN(AppCount))[:len("com.synth.app000")] + "*"}
+	default:
+		r.IncludeCountry = pick(rng, Countries, 1)
+		r.IncludeOS = pick(rng, OSes, 1)
+		r.ExcludeApp = []string{App(rng.IntN(AppCount))}
+		if rng.Float64() < 0.5 {
+			r.IncludeGeofence = []models.Geofence{geofence(rng)}
+		}
+		return r
+	}
+	if rng.Float64() < 0.9 {
+		r.IncludeCountry = pick(rng, Countries, 1+rng.IntN(5))
+	} else {
+		r.ExcludeCountry = pick(rng, Countries, 1+rng.IntN(3))
+	}
+	if rng.Float64() < 0.5 {
+		r.IncludeOS = pick(rng, OSes, 1+rng.IntN(2))
+	}
+	if rng.Float64() < 0.05 {
+		r.IncludeGeofence = []models.Geofence{geofence(rng)}
+	}
+	return r
+}
+
+// geofence returns a circle around one of the cities.
+func geofence(rng *rand.Rand) models.Geofence {
+	c := cities[rng.IntN(len(cities))]
+	return models.Geofence{Lat: c[0], Lon: c[1], RadiusKm: float64(5 + rng.IntN(50))}
+}
+
+// pick returns k distinct values of from, sorted.
+func pick(rng *rand.Rand, from []string, k int) []string {
+	out := make([]string, 0, k)
+	for _, i := range rng.Perm(len(from))[:k] {
+		out = append(out, from[i])
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Weighted is a value drawn with probability proportional to Weight.
+type Weighted struct {
+	Value  string
+	Weight float64
+}
+
+// ParseWeighted parses a comma-separated list of value:weight pairs, such
+// as "us:50,in:30,de". A value without a weight has weight 1.
+func ParseWeighted(s string) ([]Weighted, error) {
+	var out []Weighted
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w := Weighted{Value: part, Weight: 1}
+		if value, weight, ok := strings.Cut(part, ":"); ok {
+			f, err := strconv.ParseFloat(weight, 64)
+			if err != nil || f <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q", part)
+			}
+			w = Weighted{Value: value, Weight: f}
+		}
+		out = append(out, w)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty list %q", s)
+	}
+	return out, nil
+}
+
+// Distribution describes the requests a Generator draws.
+type Distribution struct {
+	Apps      []Weighted
+	Countries []Weighted
+	OSes      []Weighted
+	// GeoRate is the fraction of requests carrying coordinates.
+	GeoRate float64
+}
+
+// DefaultDistribution requests the synthetic vocabulary with Zipf-like
+// app and country popularity, mostly from Android.
+func DefaultDistribution() Distribution {
+	d := Distribution{
+		OSes:    []Weighted{{"android", 70}, {"ios", 25}, {"web", 5}},
+		GeoRate: 0.2,
+	}
+	for i := 0; i < AppCount; i++ {
+		d.Apps = append(d.Apps, Weighted{App(i), 1 / float64(i+1)})
+	}
+	for i, c := range Countries {
+		d.Countries = append(d.Countries, Weighted{c, 1 / float64(i+1)})
+	}
+	return d
+}
+
+// Generator draws delivery requests from a Distribution. It is not safe
+// for concurrent use.
+type Generator struct {
+	rng                 *rand.Rand
+	geoRate             float64
+	apps, countries, os sampler
+}
+
+// NewGenerator creates a generator; equal seeds yield equal streams.
+func NewGenerator(d Distribution, seed uint64) *Generator {
+	return &Generator{
+		rng:       rand.New(rand.NewPCG(seed, ^seed)),
+		geoRate:   d.GeoRate,
+		apps:      newSampler(d.Apps),
+		countries: newSampler(d.Countries),
+		os:        newSampler(d.OSes),
+	}
+}
+
+// Next returns the next request.
+func (g *Generator) Next() models.DeliveryRequest {
+	req := models.DeliveryRequest{
+		App:     g.apps.draw(g.rng),
+		Country: g.countries.draw(g.rng),
+		OS:      g.os.draw(g.rng),
+	}
+	if g.rng.Float64() < g.geoRate {
+		c := cities[g.rng.IntN(len(cities))]
+		lat, lon := c[0]+g.rng.Float64()*0.2-0.1, c[1]+g.rng.Float64()*0.2-0.1
+		req.Lat, req.Lon = &lat, &lon
+	}
+	return req
+}
+
+// sampler draws values by weight with a binary search over cumulative
+// weights.
+type sampler struct {
+	values     []string
+	cumulative []float64
+}
+
+func newSampler(list []Weighted) sampler {
+	s := sampler{values: make([]string, len(list)), cumulative: make([]float64, len(list))}
+	var total float64
+	for i, w := range list {
+		total += w.Weight
+		s.values[i], s.cumulative[i] = w.Value, total
+	}
+	return s
+}
+
+func (s sampler) draw(rng *rand.Rand) string {
+	if len(s.values) == 0 {
+		return ""
+	}
+	x := rng.Float64() * s.cumulative[len(s.cumulative)-1]
+	return s.values[sort.SearchFloat64s(s.cumulative, x)]
+}
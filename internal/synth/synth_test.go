@@ -0,0 +1,70 @@
+package synth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogueIsDeterministic(t *testing.T) {
+	campaigns, rules := Catalogue(100, 1)
+	again, againRules := Catalogue(100, 1)
+	other, _ := Catalogue(100, 2)
+
+	require.Len(t, campaigns, 100)
+	assert.GreaterOrEqual(t, len(rules), 100)
+	assert.Equal(t, campaigns, again)
+	assert.Equal(t, rules, againRules)
+	assert.NotEqual(t, campaigns, other, "bids depend on the seed")
+	for _, r := range rules {
+		assert.Contains(t, r.CampaignID, "synth-")
+	}
+}
+
+func TestParseWeighted(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Weighted
+		wantErr bool
+	}{
+		{in: "us:50, in:30,de", want: []Weighted{{"us", 50}, {"in", 30}, {"de", 1}}},
+		{in: "android", want: []Weighted{{"android", 1}}},
+		{in: "us:0", wantErr: true},
+		{in: "us:many", wantErr: true},
+		{in: " , ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseWeighted(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	d := Distribution{
+		Apps:      []Weighted{{"com.test", 1}},
+		Countries: []Weighted{{"us", 3}, {"in", 1}},
+		OSes:      []Weighted{{"android", 1}},
+	}
+	g := NewGenerator(d, 7)
+	again := NewGenerator(d, 7)
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		req := g.Next()
+		assert.Equal(t, req, again.Next())
+		assert.Equal(t, "com.test", req.App)
+		assert.Nil(t, req.Lat)
+		counts[req.Country]++
+	}
+	assert.InDelta(t, 3000, counts["us"], 150)
+	assert.InDelta(t, 1000, counts["in"], 150)
+}
@@ -0,0 +1,232 @@
+package targeting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+const (
+	earthRadiusKm = 6371.0
+	kmPerDegree   = math.Pi * earthRadiusKm / 180
+
+	// geoCellDegrees is the edge of a GeoIndex grid cell
+	geoCellDegrees = 0.5
+	// maxFenceCells bounds how many cells one fence is registered in; larger
+	// fences are kept in a list checked on every lookup instead
+	maxFenceCells = 4096
+)
+
+// ValidateGeofence checks that f is a well-formed circle or polygon.
+func ValidateGeofence(f models.Geofence) error {
+	if len(f.Polygon) > 0 {
+		if f.RadiusKm != 0 {
+			return errors.New("geofence must be either a radius or a polygon, not both")
+		}
+		if len(f.Polygon) < 3 {
+			return errors.New("polygon geofence needs at least 3 vertices")
+		}
+		for _, v := range f.Polygon {
+			if err := validateLatLon(v[0], v[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if f.RadiusKm <= 0 {
+		return errors.New("radius geofence needs a positive radius_km")
+	}
+	return validateLatLon(f.Lat, f.Lon)
+}
+
+func validateLatLon(lat, lon float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return fmt.Errorf("latitude %v out of range", lat)
+	}
+	if math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return fmt.Errorf("longitude %v out of range", lon)
+	}
+	return nil
+}
+
+// ParseCoordinates parses optional lat/lon request parameters. Both must be
+// given together; when both are empty nil pointers are returned.
+func ParseCoordinates(latStr, lonStr string) (*float64, *float64, error) {
+	latStr, lonStr = strings.TrimSpace(latStr), strings.TrimSpace(lonStr)
+	if latStr == "" && lonStr == "" {
+		return nil, nil, nil
+	}
+	if latStr == "" || lonStr == "" {
+		return nil, nil, errors.New("lat and lon must be supplied together")
+	}
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil {
+		return nil, nil, errors.New("invalid lat param")
+	}
+	lon, err := strconv.ParseFloat(lonStr, 64)
+	if err != nil {
+		return nil, nil, errors.New("invalid lon param")
+	}
+	if err := validateLatLon(lat, lon); err != nil {
+		return nil, nil, err
+	}
+	return &lat, &lon, nil
+}
+
+// GeofenceContains reports whether the point lies inside f. Polygons are
+// treated as planar in lat/lon space, which is accurate for city-scale
+// shapes that do not cross the antimeridian.
+func GeofenceContains(f models.Geofence, lat, lon float64) bool {
+	if len(f.Polygon) > 0 {
+		return polygonContains(f.Polygon, lat, lon)
+	}
+	return haversineKm(f.Lat, f.Lon, lat, lon) <= f.RadiusKm
+}
+
+// InAnyGeofence reports whether the point lies inside any of fences.
+func InAnyGeofence(fences []models.Geofence, lat, lon float64) bool {
+	for _, f := range fences {
+		if GeofenceContains(f, lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// polygonContains is the even-odd ray casting test.
+func polygonContains(poly [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		yi, xi := poly[i][0], poly[i][1]
+		yj, xj := poly[j][0], poly[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// boundingBox returns the fence's extent in degrees. The longitudes of a
+// radius fence near the antimeridian may extend past ±180; see lonRanges.
+func boundingBox(f models.Geofence) (minLat, minLon, maxLat, maxLon float64) {
+	if len(f.Polygon) > 0 {
+		minLat, minLon = f.Polygon[0][0], f.Polygon[0][1]
+		maxLat, maxLon = minLat, minLon
+		for _, v := range f.Polygon[1:] {
+			minLat, maxLat = math.Min(minLat, v[0]), math.Max(maxLat, v[0])
+			minLon, maxLon = math.Min(minLon, v[1]), math.Max(maxLon, v[1])
+		}
+		return
+	}
+
+	dLat := f.RadiusKm / kmPerDegree
+	// Longitude degrees shrink with latitude; clamp near the poles
+	dLon := 180.0
+	if c := math.Cos(f.Lat * math.Pi / 180); c > 1e-6 {
+		dLon = math.Min(180, dLat/c)
+	}
+	return math.Max(-90, f.Lat-dLat), f.Lon - dLon,
+		math.Min(90, f.Lat+dLat), f.Lon + dLon
+}
+
+// lonRanges splits a longitude range crossing the antimeridian into ranges
+// within [-180, 180].
+func lonRanges(minLon, maxLon float64) [][2]float64 {
+	switch {
+	case maxLon-minLon >= 360:
+		return [][2]float64{{-180, 180}}
+	case minLon < -180:
+		return [][2]float64{{minLon + 360, 180}, {-180, maxLon}}
+	case maxLon > 180:
+		return [][2]float64{{minLon, 180}, {-180, maxLon - 360}}
+	}
+	return [][2]float64{{minLon, maxLon}}
+}
+
+// GeoIndex is a uniform grid over lat/lon. Each geofence is registered in
+// every cell its bounding box touches, so a lookup only runs the exact
+// containment test against fences near the point.
+type GeoIndex struct {
+	cells map[geoCell][]fenceEntry
+	large []fenceEntry
+	size  int
+}
+
+type geoCell struct{ lat, lon int32 }
+
+type fenceEntry struct {
+	id    int
+	fence models.Geofence
+}
+
+// NewGeoIndex creates an empty index.
+func NewGeoIndex() *GeoIndex {
+	return &GeoIndex{cells: make(map[geoCell][]fenceEntry)}
+}
+
+// Len returns the number of fences in the index.
+func (g *GeoIndex) Len() int {
+	return g.size
+}
+
+// Add registers fence for rule id.
+func (g *GeoIndex) Add(fence models.Geofence, id int) {
+	e := fenceEntry{id: id, fence: fence}
+	g.size++
+
+	minLat, minLon, maxLat, maxLon := boundingBox(fence)
+	ranges := lonRanges(minLon, maxLon)
+	var cells int64
+	for _, r := range ranges {
+		lo, hi := cellOf(minLat, r[0]), cellOf(maxLat, r[1])
+		cells += int64(hi.lat-lo.lat+1) * int64(hi.lon-lo.lon+1)
+	}
+	if cells > maxFenceCells {
+		g.large = append(g.large, e)
+		return
+	}
+	for _, r := range ranges {
+		lo, hi := cellOf(minLat, r[0]), cellOf(maxLat, r[1])
+		for la := lo.lat; la <= hi.lat; la++ {
+			for ln := lo.lon; ln <= hi.lon; ln++ {
+				c := geoCell{la, ln}
+				g.cells[c] = append(g.cells[c], e)
+			}
+		}
+	}
+}
+
+// Lookup returns the set of rule ids with a fence containing the point.
+func (g *GeoIndex) Lookup(lat, lon float64) map[int]struct{} {
+	hits := make(map[int]struct{})
+	check := func(entries []fenceEntry) {
+		for _, e := range entries {
+			if _, ok := hits[e.id]; !ok && GeofenceContains(e.fence, lat, lon) {
+				hits[e.id] = struct{}{}
+			}
+		}
+	}
+	check(g.cells[cellOf(lat, lon)])
+	check(g.large)
+	return hits
+}
+
+func cellOf(lat, lon float64) geoCell {
+	return geoCell{
+		lat: int32(math.Floor(lat / geoCellDegrees)),
+		lon: int32(math.Floor(lon / geoCellDegrees)),
+	}
+}
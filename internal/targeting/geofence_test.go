@@ -0,0 +1,148 @@
+package targeting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Bengaluru city centre and a small box around Koramangala
+var (
+	bengaluru   = models.Geofence{Lat: 12.9716, Lon: 77.5946, RadiusKm: 10}
+	koramangala = models.Geofence{Polygon: [][2]float64{
+		{12.920, 77.610}, {12.920, 77.640}, {12.945, 77.640}, {12.945, 77.610},
+	}}
+	wholeEurope = models.Geofence{Polygon: [][2]float64{{35, -10}, {35, 40}, {70, 40}, {70, -10}}}
+)
+
+func TestGeofenceContains(t *testing.T) {
+	assert.True(t, GeofenceContains(bengaluru, 12.98, 77.60))
+	assert.False(t, GeofenceContains(bengaluru, 13.20, 77.60), "~25km north")
+	assert.True(t, GeofenceContains(koramangala, 12.93, 77.62))
+	assert.False(t, GeofenceContains(koramangala, 12.95, 77.62))
+}
+
+func TestValidateGeofence(t *testing.T) {
+	assert.NoError(t, ValidateGeofence(bengaluru))
+	assert.NoError(t, ValidateGeofence(koramangala))
+	assert.Error(t, ValidateGeofence(models.Geofence{Lat: 1, Lon: 1}))
+	assert.Error(t, ValidateGeofence(models.Geofence{Lat: 91, Lon: 1, RadiusKm: 1}))
+	assert.Error(t, ValidateGeofence(models.Geofence{Polygon: [][2]float64{{1, 1}, {2, 2}}}))
+}
+
+func TestGeoIndexLookup(t *testing.T) {
+	idx := NewGeoIndex()
+	idx.Add(bengaluru, 1)
+	idx.Add(koramangala, 2)
+	idx.Add(wholeEurope, 3)
+	require.Equal(t, 3, idx.Len())
+	require.Len(t, idx.large, 1, "continent-sized fence should bypass the grid")
+
+	assert.Equal(t, map[int]struct{}{1: {}, 2: {}}, idx.Lookup(12.93, 77.62))
+	assert.Equal(t, map[int]struct{}{1: {}}, idx.Lookup(12.99, 77.57))
+	assert.Equal(t, map[int]struct{}{3: {}}, idx.Lookup(52.52, 13.40))
+	assert.Empty(t, idx.Lookup(40.71, -74.00))
+}
+
+func TestGeoIndexAntimeridian(t *testing.T) {
+	// 50km fences just west and east of 180°, around Fiji's Taveuni
+	west := models.Geofence{Lat: -16.8, Lon: 179.9, RadiusKm: 50}
+	east := models.Geofence{Lat: -16.8, Lon: -179.9, RadiusKm: 50}
+	idx := NewGeoIndex()
+	idx.Add(west, 1)
+	idx.Add(east, 2)
+	require.Empty(t, idx.large)
+
+	both := map[int]struct{}{1: {}, 2: {}}
+	assert.Equal(t, both, idx.Lookup(-16.8, 179.95))
+	assert.Equal(t, both, idx.Lookup(-16.8, -179.95))
+	assert.Equal(t, map[int]struct{}{1: {}}, idx.Lookup(-16.8, 179.6))
+	assert.Equal(t, map[int]struct{}{2: {}}, idx.Lookup(-16.8, -179.6))
+	assert.Empty(t, idx.Lookup(-16.8, 178.5))
+}
+
+func TestLonRanges(t *testing.T) {
+	assert.Equal(t, [][2]float64{{10, 20}}, lonRanges(10, 20))
+	assert.Equal(t, [][2]float64{{179.5, 180}, {-180, -179.5}}, lonRanges(179.5, 180.5))
+	assert.Equal(t, [][2]float64{{179.5, 180}, {-180, -179.5}}, lonRanges(-180.5, -179.5))
+	assert.Equal(t, [][2]float64{{-180, 180}}, lonRanges(-100, 260))
+}
+
+func TestParseCoordinates(t *testing.T) {
+	lat, lon, err := ParseCoordinates("12.97", " 77.59 ")
+	require.NoError(t, err)
+	assert.Equal(t, 12.97, *lat)
+	assert.Equal(t, 77.59, *lon)
+
+	lat, lon, err = ParseCoordinates("", "")
+	require.NoError(t, err)
+	assert.Nil(t, lat)
+	assert.Nil(t, lon)
+
+	_, _, err = ParseCoordinates("12.97", "")
+	assert.Error(t, err)
+	_, _, err = ParseCoordinates("north", "77")
+	assert.EqualError(t, err, "invalid lat param")
+	_, _, err = ParseCoordinates("12", "181")
+	assert.Error(t, err)
+}
+
+func TestMatcherLocationTargeting(t *testing.T) {
+	campaigns := []models.Campaign{
+		{ID: "fooddelivery", Status: "ACTIVE"},
+		{ID: "retail", Status: "ACTIVE"},
+		{ID: "regional", Status: "ACTIVE"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "fooddelivery", IncludeGeofence: []models.Geofence{bengaluru}, ExcludeGeofence: []models.Geofence{koramangala}},
+		{CampaignID: "retail", IncludeCity: []string{"Mumbai", "Bengaluru"}},
+		{CampaignID: "regional", IncludeRegion: []string{"ka"}, ExcludeCity: []string{"mysuru"}},
+	}
+	m := NewMatcher(campaigns, rules)
+
+	f := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name     string
+		req      models.DeliveryRequest
+		expected []string
+	}{
+		{
+			name:     "no location",
+			req:      models.DeliveryRequest{App: "a", Country: "in", OS: "android"},
+			expected: []string{},
+		},
+		{
+			name:     "inside region and city",
+			req:      models.DeliveryRequest{App: "a", Country: "in", OS: "android", Region: "KA", City: "Bengaluru"},
+			expected: []string{"regional", "retail"},
+		},
+		{
+			name:     "excluded city within region",
+			req:      models.DeliveryRequest{App: "a", Country: "in", OS: "android", Region: "ka", City: "mysuru"},
+			expected: []string{},
+		},
+		{
+			name:     "inside radius",
+			req:      models.DeliveryRequest{App: "a", Country: "in", OS: "android", Lat: f(12.99), Lon: f(77.57)},
+			expected: []string{"fooddelivery"},
+		},
+		{
+			name:     "inside exclude polygon",
+			req:      models.DeliveryRequest{App: "a", Country: "in", OS: "android", Lat: f(12.93), Lon: f(77.62)},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, campaignIDs(m.Match(tc.req)))
+		})
+	}
+
+	explained := m.Explain(tests[4].req)
+	assert.Equal(t, "location inside exclude_geofence", explained[0].Rules[0].Reason)
+}
@@ -0,0 +1,447 @@
+package targeting
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Matcher evaluates delivery requests against an in-memory catalogue of
+// active campaigns and their targeting rules. A campaign matches when any
+// of its rules matches, mirroring the SQL join in campaigns.GetMatchingCampaigns.
+//
+// A Matcher is immutable once built and safe for concurrent use.
+type Matcher struct {
+	campaigns  []models.Campaign // sorted by ID
+	byCampaign [][]int           // campaign index -> rule indexes
+	rules      []compiledRule
+
+	anyApp     []int // rules without an include_app list
+	includeApp *AppIndex
+	excludeApp *AppIndex
+
+	includeFence *GeoIndex
+	excludeFence *GeoIndex
+
+	segments map[string]SegmentSet
+
+	// Tenant block lists, see WithTenants
+	publisherByApp map[string]*publisherBlocks
+	blockedApps    map[string][]string // advertiser ID -> normalised patterns
+}
+
+type publisherBlocks struct {
+	advertisers map[string]struct{}
+	categories  stringSet
+}
+
+// SegmentSet is an audience segment that device IDs can be checked against.
+type SegmentSet interface {
+	Contains(deviceID string) bool
+}
+
+// Option configures optional Matcher inputs.
+type Option func(*Matcher)
+
+// WithSegments supplies the audience segments referenced by
+// include_segment/exclude_segment. Unknown segment names never match.
+func WithSegments(segments map[string]SegmentSet) Option {
+	return func(m *Matcher) {
+		m.segments = segments
+	}
+}
+
+// WithTenants enforces publisher block lists (advertisers and categories)
+// on the publisher's apps and advertiser app block lists on their campaigns.
+func WithTenants(advertisers []models.Advertiser, publishers []models.Publisher) Option {
+	return func(m *Matcher) {
+		m.blockedApps = make(map[string][]string, len(advertisers))
+		for _, a := range advertisers {
+			for _, p := range a.BlockedApps {
+				m.blockedApps[a.ID] = append(m.blockedApps[a.ID], NormalizeApp(p))
+			}
+		}
+		m.publisherByApp = make(map[string]*publisherBlocks)
+		for _, p := range publishers {
+			b := &publisherBlocks{
+				advertisers: make(map[string]struct{}, len(p.BlockedAdvertisers)),
+				categories:  newStringSet(p.BlockedCategories),
+			}
+			for _, id := range p.BlockedAdvertisers {
+				b.advertisers[id] = struct{}{}
+			}
+			for _, app := range p.Apps {
+				m.publisherByApp[NormalizeApp(app)] = b
+			}
+		}
+	}
+}
+
+type compiledRule struct {
+	campaign       int
+	includeCountry stringSet
+	excludeCountry stringSet
+	includeOS      stringSet
+	excludeOS      stringSet
+	includeRegion  stringSet
+	excludeRegion  stringSet
+	includeCity    stringSet
+	excludeCity    stringSet
+	includePlace   stringSet
+	excludePlace   stringSet
+	includeSegment []string
+	excludeSegment []string
+	hasIncludeApp  bool
+	hasIncludeGeo  bool
+}
+
+// stringSet is a lower-cased lookup set. A nil set means "no constraint".
+type stringSet map[string]struct{}
+
+func newStringSet(values []string) stringSet {
+	if values == nil {
+		return nil
+	}
+	s := make(stringSet, len(values))
+	for _, v := range values {
+		s[strings.ToLower(strings.TrimSpace(v))] = struct{}{}
+	}
+	return s
+}
+
+func (s stringSet) has(v string) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// Explanation describes why a campaign did or did not match a request.
+type Explanation struct {
+	CampaignID string            `json:"cid"`
+	Matched    bool              `json:"matched"`
+	Reason     string            `json:"reason,omitempty"`
+	Rules      []RuleExplanation `json:"rules,omitempty"`
+}
+
+// RuleExplanation is the outcome of a single targeting rule.
+type RuleExplanation struct {
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason,omitempty"`
+	// IncludeAppPattern is the include_app entry that admitted the app.
+	IncludeAppPattern string `json:"include_app_pattern,omitempty"`
+	// ExcludeAppPattern is the exclude_app entry that rejected the app.
+	ExcludeAppPattern string `json:"exclude_app_pattern,omitempty"`
+	// IncludeSegment is the include_segment entry containing the device.
+	IncludeSegment string `json:"include_segment,omitempty"`
+	// ExcludeSegment is the exclude_segment entry that rejected the device.
+	ExcludeSegment string `json:"exclude_segment,omitempty"`
+}
+
+// NewMatcher builds a matcher from campaigns and their targeting rules.
+// Campaigns that are not ACTIVE and rules that reference unknown campaigns
+// are ignored.
+func NewMatcher(campaigns []models.Campaign, rules []models.TargetingRule, opts ...Option) *Matcher {
+	m := &Matcher{
+		includeApp:   NewAppIndex(),
+		excludeApp:   NewAppIndex(),
+		includeFence: NewGeoIndex(),
+		excludeFence: NewGeoIndex(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	for _, c := range campaigns {
+		if c.Status == "ACTIVE" {
+			m.campaigns = append(m.campaigns, c)
+		}
+	}
+	sort.Slice(m.campaigns, func(i, j int) bool { return m.campaigns[i].ID < m.campaigns[j].ID })
+
+	index := make(map[string]int, len(m.campaigns))
+	for i, c := range m.campaigns {
+		index[c.ID] = i
+	}
+	m.byCampaign = make([][]int, len(m.campaigns))
+
+	for _, r := range rules {
+		ci, ok := index[r.CampaignID]
+		if !ok {
+			continue
+		}
+		id := len(m.rules)
+		m.rules = append(m.rules, compiledRule{
+			campaign:       ci,
+			includeCountry: newStringSet(r.IncludeCountry),
+			excludeCountry: newStringSet(r.ExcludeCountry),
+			includeOS:      newStringSet(r.IncludeOS),
+			excludeOS:      newStringSet(r.ExcludeOS),
+			includeRegion:  newStringSet(r.IncludeRegion),
+			excludeRegion:  newStringSet(r.ExcludeRegion),
+			includeCity:    newStringSet(r.IncludeCity),
+			excludeCity:    newStringSet(r.ExcludeCity),
+			includePlace:   newStringSet(r.IncludePlacement),
+			excludePlace:   newStringSet(r.ExcludePlacement),
+			includeSegment: r.IncludeSegment,
+			excludeSegment: r.ExcludeSegment,
+			hasIncludeApp:  r.IncludeApp != nil,
+			hasIncludeGeo:  r.IncludeGeofence != nil,
+		})
+		m.byCampaign[ci] = append(m.byCampaign[ci], id)
+
+		if r.IncludeApp == nil {
+			m.anyApp = append(m.anyApp, id)
+		}
+		for _, p := range r.IncludeApp {
+			m.includeApp.Add(p, id)
+		}
+		for _, p := range r.ExcludeApp {
+			m.excludeApp.Add(p, id)
+		}
+		for _, f := range r.IncludeGeofence {
+			m.includeFence.Add(f, id)
+		}
+		for _, f := range r.ExcludeGeofence {
+			m.excludeFence.Add(f, id)
+		}
+	}
+
+	return m
+}
+
+// Len returns the number of campaigns held by the matcher.
+func (m *Matcher) Len() int {
+	return len(m.campaigns)
+}
+
+// Match returns the campaigns matching req, ordered by campaign ID.
+func (m *Matcher) Match(req models.DeliveryRequest) []models.Campaign {
+	q := normalizeRequest(req)
+	hits := m.lookup(q)
+
+	matched := make([]bool, len(m.campaigns))
+	done := make([]bool, len(m.campaigns))
+	check := func(id int) {
+		ci := m.rules[id].campaign
+		if !done[ci] && m.evaluate(id, q, hits).Matched {
+			done[ci] = true
+			matched[ci] = m.blocked(&m.campaigns[ci], q.App) == ""
+		}
+	}
+	for _, id := range m.anyApp {
+		check(id)
+	}
+	for id := range hits.includeApp {
+		check(id)
+	}
+
+	var out []models.Campaign
+	for i, ok := range matched {
+		if ok {
+			out = append(out, m.campaigns[i])
+		}
+	}
+	return out
+}
+
+// Explain evaluates every rule of every campaign against req and reports
+// the outcome, including which app pattern matched.
+func (m *Matcher) Explain(req models.DeliveryRequest) []Explanation {
+	q := normalizeRequest(req)
+	hits := m.lookup(q)
+
+	out := make([]Explanation, 0, len(m.campaigns))
+	for ci, c := range m.campaigns {
+		e := Explanation{CampaignID: c.ID}
+		if len(m.byCampaign[ci]) == 0 {
+			e.Reason = "campaign has no targeting rules"
+		}
+		for _, id := range m.byCampaign[ci] {
+			res := m.evaluate(id, q, hits)
+			e.Matched = e.Matched || res.Matched
+			e.Rules = append(e.Rules, res)
+		}
+		if reason := m.blocked(&c, q.App); reason != "" {
+			e.Matched = false
+			e.Reason = reason
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// indexHits holds the per-rule results of the index lookups for a request.
+type indexHits struct {
+	includeApp   map[int]string
+	excludeApp   map[int]string
+	includeFence map[int]struct{}
+	excludeFence map[int]struct{}
+}
+
+func (m *Matcher) lookup(q models.DeliveryRequest) indexHits {
+	h := indexHits{
+		includeApp: hitsByRule(m.includeApp.Lookup(q.App)),
+		excludeApp: hitsByRule(m.excludeApp.Lookup(q.App)),
+	}
+	if q.Lat != nil && q.Lon != nil {
+		h.includeFence = m.includeFence.Lookup(*q.Lat, *q.Lon)
+		h.excludeFence = m.excludeFence.Lookup(*q.Lat, *q.Lon)
+	}
+	return h
+}
+
+// evaluate checks rule id against the normalised request q.
+func (m *Matcher) evaluate(id int, q models.DeliveryRequest, hits indexHits) RuleExplanation {
+	r := &m.rules[id]
+	var res RuleExplanation
+
+	if r.hasIncludeApp {
+		p, ok := hits.includeApp[id]
+		if !ok {
+			res.Reason = "app not in include_app"
+			return res
+		}
+		res.IncludeAppPattern = p
+	}
+	if p, ok := hits.excludeApp[id]; ok {
+		res.ExcludeAppPattern = p
+		res.Reason = "app in exclude_app"
+		return res
+	}
+	if r.includeCountry != nil && !r.includeCountry.has(q.Country) {
+		res.Reason = "country not in include_country"
+		return res
+	}
+	if r.excludeCountry.has(q.Country) {
+		res.Reason = "country in exclude_country"
+		return res
+	}
+	if r.includeOS != nil && !r.includeOS.has(q.OS) {
+		res.Reason = "os not in include_os"
+		return res
+	}
+	if r.excludeOS.has(q.OS) {
+		res.Reason = "os in exclude_os"
+		return res
+	}
+	if r.includeRegion != nil && !r.includeRegion.has(q.Region) {
+		res.Reason = "region not in include_region"
+		return res
+	}
+	if r.excludeRegion.has(q.Region) {
+		res.Reason = "region in exclude_region"
+		return res
+	}
+	if r.includeCity != nil && !r.includeCity.has(q.City) {
+		res.Reason = "city not in include_city"
+		return res
+	}
+	if r.excludeCity.has(q.City) {
+		res.Reason = "city in exclude_city"
+		return res
+	}
+	if r.includePlace != nil && !r.includePlace.has(q.PlacementID) {
+		res.Reason = "placement not in include_placement"
+		return res
+	}
+	if r.excludePlace.has(q.PlacementID) {
+		res.Reason = "placement in exclude_placement"
+		return res
+	}
+	if _, ok := hits.includeFence[id]; r.hasIncludeGeo && !ok {
+		res.Reason = "location outside include_geofence"
+		return res
+	}
+	if _, ok := hits.excludeFence[id]; ok {
+		res.Reason = "location inside exclude_geofence"
+		return res
+	}
+	if r.includeSegment != nil {
+		seg, ok := m.inSegment(r.includeSegment, q.DeviceID)
+		if !ok {
+			res.Reason = "device not in include_segment"
+			return res
+		}
+		res.IncludeSegment = seg
+	}
+	if seg, ok := m.inSegment(r.excludeSegment, q.DeviceID); ok {
+		res.ExcludeSegment = seg
+		res.Reason = "device in exclude_segment"
+		return res
+	}
+
+	res.Matched = true
+	return res
+}
+
+// blocked returns why tenant block lists forbid serving c on app, or "".
+func (m *Matcher) blocked(c *models.Campaign, app string) string {
+	if pub := m.publisherByApp[app]; pub != nil {
+		if _, ok := pub.advertisers[c.AdvertiserID]; ok && c.AdvertiserID != "" {
+			return "advertiser blocked by publisher"
+		}
+		if categoryBlocked(pub.categories, c.Categories) {
+			return "category blocked by publisher"
+		}
+	}
+	for _, p := range m.blockedApps[c.AdvertiserID] {
+		if MatchGlob(p, app) {
+			return "app blocked by advertiser"
+		}
+	}
+	return ""
+}
+
+// categoryBlocked reports whether any of categories is in blocked or is a
+// subcategory ("IAB9-30") of a blocked category ("IAB9").
+func categoryBlocked(blocked stringSet, categories []string) bool {
+	if len(blocked) == 0 {
+		return false
+	}
+	for _, c := range categories {
+		c = strings.ToLower(strings.TrimSpace(c))
+		for {
+			if blocked.has(c) {
+				return true
+			}
+			i := strings.LastIndexByte(c, '-')
+			if i < 0 {
+				break
+			}
+			c = c[:i]
+		}
+	}
+	return false
+}
+
+// inSegment returns the first of names whose segment contains deviceID.
+func (m *Matcher) inSegment(names []string, deviceID string) (string, bool) {
+	if deviceID == "" {
+		return "", false
+	}
+	for _, name := range names {
+		if seg, ok := m.segments[name]; ok && seg.Contains(deviceID) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func normalizeRequest(req models.DeliveryRequest) models.DeliveryRequest {
+	req.App = NormalizeApp(req.App)
+	req.Country = strings.ToLower(strings.TrimSpace(req.Country))
+	req.OS = strings.ToLower(strings.TrimSpace(req.OS))
+	req.Region = strings.ToLower(strings.TrimSpace(req.Region))
+	req.City = strings.ToLower(strings.TrimSpace(req.City))
+	req.PlacementID = strings.ToLower(strings.TrimSpace(req.PlacementID))
+	return req
+}
+
+// hitsByRule keeps the most specific matching pattern for each rule.
+func hitsByRule(matches []AppMatch) map[int]string {
+	hits := make(map[int]string, len(matches))
+	for _, m := range matches {
+		hits[m.ID] = m.Pattern
+	}
+	return hits
+}
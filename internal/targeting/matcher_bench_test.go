@@ -0,0 +1,52 @@
+package targeting
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/synth"
+)
+
+// benchSizes are the catalogue sizes benchmarked.
+var benchSizes = []int{10, 1000, 100000}
+
+// benchRequests draws a fixed stream of requests, so every run and size
+// matches the same traffic.
+func benchRequests(n int) []models.DeliveryRequest {
+	g := synth.NewGenerator(synth.DefaultDistribution(), 1)
+	reqs := make([]models.DeliveryRequest, n)
+	for i := range reqs {
+		reqs[i] = g.Next()
+	}
+	return reqs
+}
+
+func BenchmarkMatch(b *testing.B) {
+	reqs := benchRequests(1024)
+	for _, size := range benchSizes {
+		b.Run("campaigns="+strconv.Itoa(size), func(b *testing.B) {
+			m := NewMatcher(synth.Catalogue(size, 1))
+			matches := 0
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				matches += len(m.Match(reqs[i%len(reqs)]))
+			}
+			b.ReportMetric(float64(matches)/float64(b.N), "matches/op")
+		})
+	}
+}
+
+func BenchmarkNewMatcher(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run("campaigns="+strconv.Itoa(size), func(b *testing.B) {
+			campaigns, rules := synth.Catalogue(size, 1)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				NewMatcher(campaigns, rules)
+			}
+		})
+	}
+}
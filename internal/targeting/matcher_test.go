@@ -0,0 +1,238 @@
+package targeting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// seedCatalog mirrors db/seed/seed.sql plus a pattern-targeted campaign.
+func seedCatalog() ([]models.Campaign, []models.TargetingRule) {
+	campaigns := []models.Campaign{
+		{ID: "spotify", Name: "Spotify - Music for everyone", Img: "https://somelink", CTA: "Download", Status: "ACTIVE"},
+		{ID: "duolingo", Name: "Duolingo: Best way to learn", Img: "https://somelink2", CTA: "Install", Status: "ACTIVE"},
+		{ID: "subwaysurfer", Name: "Subway Surfer", Img: "https://somelink3", CTA: "Play", Status: "ACTIVE"},
+		{ID: "ludo", Name: "Ludo family", Img: "https://somelink4", CTA: "Play", Status: "ACTIVE"},
+		{ID: "paused", Name: "Paused", Img: "https://somelink5", CTA: "Play", Status: "PAUSED"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "spotify", IncludeCountry: []string{"us", "ca"}},
+		{CampaignID: "duolingo", ExcludeCountry: []string{"us"}, IncludeOS: []string{"android", "ios"}},
+		{CampaignID: "subwaysurfer", IncludeOS: []string{"android"}, IncludeApp: []string{"com.gametion.ludokinggame"}},
+		{CampaignID: "ludo", IncludeApp: []string{"com.gametion.*"}, ExcludeApp: []string{"com.gametion.ludo?lite"}},
+		{CampaignID: "paused"},
+	}
+	return campaigns, rules
+}
+
+func campaignIDs(campaigns []models.Campaign) []string {
+	ids := []string{}
+	for _, c := range campaigns {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestMatcherMatch(t *testing.T) {
+	m := NewMatcher(seedCatalog())
+	assert.Equal(t, 4, m.Len())
+
+	tests := []struct {
+		name     string
+		req      models.DeliveryRequest
+		expected []string
+	}{
+		{
+			name:     "Match spotify, subwaysurfer and ludo",
+			req:      models.DeliveryRequest{App: "com.gametion.ludokinggame", Country: "us", OS: "android"},
+			expected: []string{"ludo", "spotify", "subwaysurfer"},
+		},
+		{
+			name:     "Match duolingo only",
+			req:      models.DeliveryRequest{App: "com.test", Country: "germany", OS: "android"},
+			expected: []string{"duolingo"},
+		},
+		{
+			name:     "Prefix pattern admits sibling app",
+			req:      models.DeliveryRequest{App: "com.gametion.other", Country: "us", OS: "web"},
+			expected: []string{"ludo", "spotify"},
+		},
+		{
+			name:     "Exclude pattern rejects app",
+			req:      models.DeliveryRequest{App: "com.gametion.ludo-lite", Country: "in", OS: "web"},
+			expected: []string{},
+		},
+		{
+			name:     "Only country-targeted campaign on web",
+			req:      models.DeliveryRequest{App: "com.test", Country: "us", OS: "web"},
+			expected: []string{"spotify"},
+		},
+		{
+			name:     "Case insensitive matching",
+			req:      models.DeliveryRequest{App: "COM.GAMETION.LUDOKINGGAME", Country: "US", OS: "ANDROID"},
+			expected: []string{"ludo", "spotify", "subwaysurfer"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, campaignIDs(m.Match(tc.req)))
+		})
+	}
+}
+
+func TestMatcherExplain(t *testing.T) {
+	m := NewMatcher(seedCatalog())
+
+	explanations := m.Explain(models.DeliveryRequest{App: "com.gametion.ludokinggame", Country: "germany", OS: "ios"})
+	require.Len(t, explanations, 4)
+
+	byID := make(map[string]Explanation)
+	for _, e := range explanations {
+		byID[e.CampaignID] = e
+	}
+
+	ludo := byID["ludo"]
+	assert.True(t, ludo.Matched)
+	require.Len(t, ludo.Rules, 1)
+	assert.Equal(t, "com.gametion.*", ludo.Rules[0].IncludeAppPattern)
+
+	subway := byID["subwaysurfer"]
+	assert.False(t, subway.Matched)
+	require.Len(t, subway.Rules, 1)
+	assert.Equal(t, "com.gametion.ludokinggame", subway.Rules[0].IncludeAppPattern)
+	assert.Equal(t, "os not in include_os", subway.Rules[0].Reason)
+
+	spotify := byID["spotify"]
+	assert.False(t, spotify.Matched)
+	assert.Equal(t, "country not in include_country", spotify.Rules[0].Reason)
+
+	lite := m.Explain(models.DeliveryRequest{App: "com.gametion.ludoxlite", Country: "in", OS: "android"})
+	for _, e := range lite {
+		if e.CampaignID == "ludo" {
+			assert.False(t, e.Matched)
+			assert.Equal(t, "com.gametion.ludo?lite", e.Rules[0].ExcludeAppPattern)
+			assert.Equal(t, "app in exclude_app", e.Rules[0].Reason)
+		}
+	}
+}
+
+// segmentSet is a trivial SegmentSet for tests.
+type segmentSet map[string]bool
+
+func (s segmentSet) Contains(deviceID string) bool { return s[deviceID] }
+
+func TestMatcherSegments(t *testing.T) {
+	campaigns := []models.Campaign{
+		{ID: "retarget", Status: "ACTIVE"},
+		{ID: "acquire", Status: "ACTIVE"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "retarget", IncludeSegment: []string{"cart-abandoners", "missing"}},
+		{CampaignID: "acquire", ExcludeSegment: []string{"customers"}},
+	}
+	m := NewMatcher(campaigns, rules, WithSegments(map[string]SegmentSet{
+		"cart-abandoners": segmentSet{"d1": true},
+		"customers":       segmentSet{"d1": true, "d2": true},
+	}))
+
+	req := func(device string) models.DeliveryRequest {
+		return models.DeliveryRequest{App: "a", Country: "us", OS: "ios", DeviceID: device}
+	}
+
+	assert.Equal(t, []string{"retarget"}, campaignIDs(m.Match(req("d1"))))
+	assert.Equal(t, []string{}, campaignIDs(m.Match(req("d2"))))
+	assert.Equal(t, []string{"acquire"}, campaignIDs(m.Match(req("d3"))))
+	assert.Equal(t, []string{"acquire"}, campaignIDs(m.Match(req(""))))
+
+	explained := m.Explain(req("d1"))
+	assert.Equal(t, "customers", explained[0].Rules[0].ExcludeSegment)
+	assert.Equal(t, "cart-abandoners", explained[1].Rules[0].IncludeSegment)
+}
+
+func TestMatcherTenants(t *testing.T) {
+	campaigns := []models.Campaign{
+		{ID: "music", Status: "ACTIVE", AdvertiserID: "spotify", Categories: []string{"IAB1-6"}},
+		{ID: "casino", Status: "ACTIVE", AdvertiserID: "bet", Categories: []string{"IAB9-7"}},
+		{ID: "learn", Status: "ACTIVE", AdvertiserID: "duolingo", Categories: []string{"IAB5"}},
+		{ID: "house", Status: "ACTIVE"},
+	}
+	var rules []models.TargetingRule
+	for _, c := range campaigns {
+		rules = append(rules, models.TargetingRule{CampaignID: c.ID})
+	}
+	m := NewMatcher(campaigns, rules, WithTenants(
+		[]models.Advertiser{{ID: "duolingo", BlockedApps: []string{"com.gametion.*"}}},
+		[]models.Publisher{{
+			ID:                 "gametion",
+			Apps:               []string{"com.gametion.ludokinggame", "Com.Gametion.Snakes"},
+			BlockedAdvertisers: []string{"spotify"},
+			BlockedCategories:  []string{"iab9"},
+		}},
+	))
+
+	req := func(app string) models.DeliveryRequest {
+		return models.DeliveryRequest{App: app, Country: "us", OS: "android"}
+	}
+
+	tests := []struct {
+		name     string
+		app      string
+		expected []string
+	}{
+		{"Publisher blocks advertiser and parent category, advertiser blocks app", "com.gametion.ludokinggame", []string{"house"}},
+		{"Publisher apps are case-insensitive", "com.gametion.snakes", []string{"house"}},
+		{"Advertiser block pattern without publisher", "com.gametion.other", []string{"casino", "house", "music"}},
+		{"Unowned app", "com.other", []string{"casino", "house", "learn", "music"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, campaignIDs(m.Match(req(tt.app))))
+		})
+	}
+
+	reasons := make(map[string]string)
+	for _, e := range m.Explain(req("com.gametion.ludokinggame")) {
+		reasons[e.CampaignID] = e.Reason
+		assert.Equal(t, e.CampaignID == "house", e.Matched)
+	}
+	assert.Equal(t, "advertiser blocked by publisher", reasons["music"])
+	assert.Equal(t, "category blocked by publisher", reasons["casino"])
+	assert.Equal(t, "app blocked by advertiser", reasons["learn"])
+}
+
+func TestMatcherPlacements(t *testing.T) {
+	campaigns := []models.Campaign{
+		{ID: "home-only", Status: "ACTIVE"},
+		{ID: "not-home", Status: "ACTIVE"},
+	}
+	rules := []models.TargetingRule{
+		{CampaignID: "home-only", IncludePlacement: []string{"ludo-home-banner"}},
+		{CampaignID: "not-home", ExcludePlacement: []string{"ludo-home-banner"}},
+	}
+	m := NewMatcher(campaigns, rules)
+
+	tests := []struct {
+		name      string
+		placement string
+		expected  []string
+	}{
+		{"No placement", "", []string{"not-home"}},
+		{"Included placement", "Ludo-Home-Banner", []string{"home-only"}},
+		{"Other placement", "ludo-game-over", []string{"not-home"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.DeliveryRequest{App: "com.gametion.ludokinggame", Country: "us", OS: "android", PlacementID: tt.placement}
+			assert.Equal(t, tt.expected, campaignIDs(m.Match(req)))
+		})
+	}
+
+	explained := m.Explain(models.DeliveryRequest{App: "com.gametion.ludokinggame", PlacementID: "ludo-home-banner"})
+	assert.Equal(t, "placement in exclude_placement", explained[1].Rules[0].Reason)
+	explained = m.Explain(models.DeliveryRequest{App: "com.gametion.ludokinggame"})
+	assert.Equal(t, "placement not in include_placement", explained[0].Rules[0].Reason)
+}
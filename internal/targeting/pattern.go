@@ -0,0 +1,50 @@
+package targeting
+
+import "strings"
+
+// NormalizeApp lower-cases and trims an app bundle ID or pattern so that
+// app matching is case-insensitive.
+func NormalizeApp(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// IsPattern reports whether s contains glob wildcards.
+func IsPattern(s string) bool {
+	return strings.ContainsAny(s, "*?")
+}
+
+// MatchGlob reports whether name matches pattern. '*' matches any run of
+// characters (including none) and '?' matches exactly one character; every
+// other character matches itself.
+func MatchGlob(pattern, name string) bool {
+	p, n := 0, 0
+	starP, starN := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starN = p, n
+			p++
+		case starP >= 0:
+			// Let the last '*' swallow one more character and retry
+			starN++
+			p, n = starP+1, starN
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// literalPrefix returns the part of pattern before its first wildcard.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
@@ -0,0 +1,55 @@
+package targeting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/countries"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// NormalizeRule canonicalises a rule before it is written: countries become
+// ISO 3166-1 alpha-2 codes, other list values are lower-cased and trimmed,
+// and geofences are validated. Unknown countries are rejected.
+func NormalizeRule(r *models.TargetingRule) error {
+	var err error
+	if r.IncludeCountry, err = countries.NormalizeList(r.IncludeCountry); err != nil {
+		return fmt.Errorf("include_country: %w", err)
+	}
+	if r.ExcludeCountry, err = countries.NormalizeList(r.ExcludeCountry); err != nil {
+		return fmt.Errorf("exclude_country: %w", err)
+	}
+
+	for _, list := range []*[]string{
+		&r.IncludeOS, &r.ExcludeOS, &r.IncludeApp, &r.ExcludeApp,
+		&r.IncludeRegion, &r.ExcludeRegion, &r.IncludeCity, &r.ExcludeCity,
+		&r.IncludePlacement, &r.ExcludePlacement,
+	} {
+		*list = lowerList(*list)
+	}
+
+	for i, f := range r.IncludeGeofence {
+		if err := ValidateGeofence(f); err != nil {
+			return fmt.Errorf("include_geofence[%d]: %w", i, err)
+		}
+	}
+	for i, f := range r.ExcludeGeofence {
+		if err := ValidateGeofence(f); err != nil {
+			return fmt.Errorf("exclude_geofence[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func lowerList(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
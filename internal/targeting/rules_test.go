@@ -0,0 +1,38 @@
+package targeting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func TestNormalizeRule(t *testing.T) {
+	r := models.TargetingRule{
+		IncludeCountry:   []string{"US", "canada", "usa"},
+		ExcludeCountry:   []string{"UK"},
+		IncludeOS:        []string{" Android ", "iOS"},
+		IncludeApp:       []string{"COM.Gametion.*"},
+		IncludeCity:      []string{"Bengaluru"},
+		IncludePlacement: []string{" Ludo-Home-Banner "},
+	}
+	require.NoError(t, NormalizeRule(&r))
+
+	assert.Equal(t, []string{"us", "ca"}, r.IncludeCountry)
+	assert.Equal(t, []string{"gb"}, r.ExcludeCountry)
+	assert.Equal(t, []string{"android", "ios"}, r.IncludeOS)
+	assert.Equal(t, []string{"com.gametion.*"}, r.IncludeApp)
+	assert.Equal(t, []string{"bengaluru"}, r.IncludeCity)
+	assert.Equal(t, []string{"ludo-home-banner"}, r.IncludePlacement)
+	assert.Nil(t, r.ExcludeOS)
+}
+
+func TestNormalizeRuleRejectsInvalid(t *testing.T) {
+	err := NormalizeRule(&models.TargetingRule{ExcludeCountry: []string{"us", "gondor"}})
+	assert.EqualError(t, err, "exclude_country: unknown country code(s): gondor")
+
+	err = NormalizeRule(&models.TargetingRule{IncludeGeofence: []models.Geofence{{Lat: 1, Lon: 2}}})
+	assert.ErrorContains(t, err, "include_geofence[0]")
+}
@@ -0,0 +1,104 @@
+package targeting
+
+// AppIndex maps app bundle IDs and glob patterns to rule identifiers.
+//
+// Patterns are stored in a byte trie keyed by their literal prefix (the part
+// before the first wildcard), so a lookup only visits the nodes along the
+// requested app ID instead of scanning every rule. Exact IDs and trailing
+// "prefix*" patterns are resolved purely by the walk; other globs are
+// checked with MatchGlob only when their literal prefix matches.
+type AppIndex struct {
+	root *trieNode
+	size int
+}
+
+type trieNode struct {
+	children map[byte]*trieNode
+	exact    []indexEntry // no wildcard, ends at this node
+	prefix   []indexEntry // "<literal>*"
+	glob     []indexEntry // any other pattern whose literal prefix ends here
+}
+
+type indexEntry struct {
+	id      int
+	pattern string
+}
+
+// AppMatch is a single hit returned by AppIndex.Lookup.
+type AppMatch struct {
+	ID      int
+	Pattern string
+}
+
+// NewAppIndex creates an empty index.
+func NewAppIndex() *AppIndex {
+	return &AppIndex{root: &trieNode{}}
+}
+
+// Len returns the number of patterns in the index.
+func (x *AppIndex) Len() int {
+	return x.size
+}
+
+// Add registers pattern for rule id. The pattern is normalised first.
+func (x *AppIndex) Add(pattern string, id int) {
+	pattern = NormalizeApp(pattern)
+	if pattern == "" {
+		return
+	}
+
+	lit := literalPrefix(pattern)
+	node := x.root
+	for i := 0; i < len(lit); i++ {
+		if node.children == nil {
+			node.children = make(map[byte]*trieNode)
+		}
+		next, ok := node.children[lit[i]]
+		if !ok {
+			next = &trieNode{}
+			node.children[lit[i]] = next
+		}
+		node = next
+	}
+
+	entry := indexEntry{id: id, pattern: pattern}
+	switch rest := pattern[len(lit):]; {
+	case rest == "":
+		node.exact = append(node.exact, entry)
+	case rest == "*":
+		node.prefix = append(node.prefix, entry)
+	default:
+		node.glob = append(node.glob, entry)
+	}
+	x.size++
+}
+
+// Lookup returns every pattern matching app. Matches are ordered from the
+// least to the most specific literal prefix, with exact IDs last.
+func (x *AppIndex) Lookup(app string) []AppMatch {
+	app = NormalizeApp(app)
+
+	var matches []AppMatch
+	node := x.root
+	for i := 0; ; i++ {
+		for _, e := range node.prefix {
+			matches = append(matches, AppMatch{ID: e.id, Pattern: e.pattern})
+		}
+		for _, e := range node.glob {
+			if MatchGlob(e.pattern, app) {
+				matches = append(matches, AppMatch{ID: e.id, Pattern: e.pattern})
+			}
+		}
+		if i == len(app) {
+			for _, e := range node.exact {
+				matches = append(matches, AppMatch{ID: e.id, Pattern: e.pattern})
+			}
+			return matches
+		}
+		next, ok := node.children[app[i]]
+		if !ok {
+			return matches
+		}
+		node = next
+	}
+}
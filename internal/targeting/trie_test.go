@@ -0,0 +1,93 @@
+package targeting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"com.gametion.*", "com.gametion.ludokinggame", true},
+		{"com.gametion.*", "com.gametion.", true},
+		{"com.gametion.*", "com.gametio", false},
+		{"*.ludo*", "com.gametion.ludokinggame", true},
+		{"com.?ametion.*", "com.gametion.x", true},
+		{"com.?ametion.*", "com.ametion.x", false},
+		{"*", "", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern+"/"+tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, MatchGlob(tc.pattern, tc.name))
+		})
+	}
+}
+
+func TestAppIndexLookup(t *testing.T) {
+	idx := NewAppIndex()
+	idx.Add("com.gametion.ludokinggame", 1)
+	idx.Add("COM.GAMETION.*", 2)
+	idx.Add("com.*", 3)
+	idx.Add("*.game", 4)
+	idx.Add("com.king.candy?", 5)
+	idx.Add("", 6)
+
+	assert.Equal(t, 5, idx.Len())
+
+	tests := []struct {
+		name string
+		app  string
+		want []AppMatch
+	}{
+		{
+			name: "exact and prefix patterns, least specific first",
+			app:  "com.gametion.ludokinggame",
+			want: []AppMatch{
+				{ID: 3, Pattern: "com.*"},
+				{ID: 2, Pattern: "com.gametion.*"},
+				{ID: 1, Pattern: "com.gametion.ludokinggame"},
+			},
+		},
+		{
+			name: "case insensitive",
+			app:  "Com.Gametion.Other",
+			want: []AppMatch{
+				{ID: 3, Pattern: "com.*"},
+				{ID: 2, Pattern: "com.gametion.*"},
+			},
+		},
+		{
+			name: "leading wildcard glob",
+			app:  "org.example.game",
+			want: []AppMatch{{ID: 4, Pattern: "*.game"}},
+		},
+		{
+			name: "single character wildcard",
+			app:  "com.king.candy2",
+			want: []AppMatch{
+				{ID: 3, Pattern: "com.*"},
+				{ID: 5, Pattern: "com.king.candy?"},
+			},
+		},
+		{
+			name: "no match",
+			app:  "org.example",
+			want: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, idx.Lookup(tc.app))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a tracer provider that keeps finished spans in memory.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func attr(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestMiddleware(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name        string
+		traceparent string
+		status      int
+		wantError   bool
+	}{
+		{name: "new trace", status: http.StatusOK},
+		{name: "continues caller trace", traceparent: parent, status: http.StatusNoContent},
+		{name: "server error", status: http.StatusInternalServerError, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record(t)
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			r.Use(Middleware)
+			r.Get("/v2/delivery/{id}", func(w http.ResponseWriter, r *http.Request) {
+				_, span := Start(r.Context(), "child")
+				span.End()
+				w.WriteHeader(tt.status)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/delivery/42", nil)
+			req.Header.Set(middleware.RequestIDHeader, "req-1")
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 2)
+			child, server := spans[0], spans[1]
+			assert.Equal(t, "GET /v2/delivery/{id}", server.Name())
+			assert.Equal(t, server.SpanContext().SpanID(), child.Parent().SpanID())
+
+			id, ok := attr(server.Attributes(), RequestIDKey)
+			require.True(t, ok)
+			assert.Equal(t, "req-1", id.AsString())
+			code, ok := attr(server.Attributes(), "http.response.status_code")
+			require.True(t, ok)
+			assert.EqualValues(t, tt.status, code.AsInt64())
+
+			if tt.traceparent != "" {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+				assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+				assert.True(t, server.Parent().IsRemote())
+			} else {
+				assert.False(t, server.Parent().IsValid())
+			}
+			if tt.wantError {
+				assert.Equal(t, codes.Error, server.Status().Code)
+			} else {
+				assert.Equal(t, codes.Unset, server.Status().Code)
+			}
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"success", nil, codes.Unset},
+		{"failure", errors.New("boom"), codes.Error},
+		{"cancelled", context.Canceled, codes.Unset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record(t)
+			_, span := Start(context.Background(), "op")
+			End(span, tt.err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.want, spans[0].Status().Code)
+			assert.Equal(t, tt.err != nil, len(spans[0].Events()) > 0)
+		})
+	}
+}
+
+func TestEndpointMiddleware(t *testing.T) {
+	recorder := record(t)
+	ep := EndpointMiddleware("endpoint.Delivery")(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return request, errors.New("bad request")
+	})
+	_, err := ep(context.Background(), "req")
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "endpoint.Delivery", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
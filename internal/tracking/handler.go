@@ -0,0 +1,48 @@
+package tracking
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+)
+
+// HandleEvent serves /v1/track/{event}, called by clients when they show
+// or click a served campaign. The event, with the clearing price and
+// auction diagnostics its token carries, goes to the request log and is
+// counted in reg. Tokens are not single-use: consumers deduplicate by
+// event_id.
+func HandleEvent(issuer *Issuer, reg *metrics.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		event := chi.URLParam(r, "event")
+		if event != EventImpression && event != EventClick {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unknown tracking event"})
+			return
+		}
+
+		e, err := issuer.Open(r.URL.Query().Get("t"))
+		if err != nil {
+			result := "invalid"
+			if errors.Is(err, ErrExpiredToken) {
+				result = "expired"
+			}
+			logging.AddAttrs(ctx, logging.Err(err))
+			reg.ObserveTrackingEvent(event, result)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		logging.AddAttrs(ctx, e.LogAttrs(event)...)
+		reg.ObserveTrackingEvent(event, "ok")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,62 @@
+package tracking
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+func TestHandleEvent(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	i := newIssuer(t, "secret", now)
+	got, err := i.Annotate(req, []models.Campaign{{ID: "spotify"}})
+	require.NoError(t, err)
+	valid := token(t, got[0].Tracking.ImpressionURL)
+
+	tests := []struct {
+		name       string
+		path       string
+		issuer     *Issuer
+		wantCode   int
+		wantError  string
+		wantResult string
+	}{
+		{"impression", "/v1/track/impression?t=" + valid, i, http.StatusNoContent, "", "ok"},
+		{"click", "/v1/track/click?t=" + valid, i, http.StatusNoContent, "", "ok"},
+		{"unknown event", "/v1/track/install?t=" + valid, i, http.StatusNotFound, "unknown tracking event", ""},
+		{"invalid token", "/v1/track/impression?t=forged", i, http.StatusBadRequest, "invalid tracking token", "invalid"},
+		{"expired token", "/v1/track/click?t=" + valid, newIssuer(t, "secret", now.Add(2*time.Hour)), http.StatusBadRequest, "expired tracking token", "expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := metrics.New()
+			r := chi.NewRouter()
+			r.Get("/v1/track/{event}", HandleEvent(tt.issuer, reg))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantError != "" {
+				assert.JSONEq(t, `{"error":"`+tt.wantError+`"}`, w.Body.String())
+			}
+			if tt.wantResult == "" {
+				assert.Zero(t, testutil.CollectAndCount(reg.TrackingEventCount))
+				return
+			}
+			event := strings.TrimPrefix(strings.SplitN(tt.path, "?", 2)[0], Path)
+			assert.Equal(t, 1.0, testutil.ToFloat64(reg.TrackingEventCount.WithLabelValues(event, tt.wantResult)))
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package tracking issues impression and click tracking URLs for served
+// campaigns and reads back the events they report. A URL carries its event
+// in an encrypted token: the campaign, the request it was served for and,
+// for auction winners, the clearing price and auction diagnostics. Clients
+// cannot read the bids in it or forge a price.
+package tracking
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+// Tracked events, also used as metric labels.
+const (
+	EventImpression = "impression"
+	EventClick      = "click"
+)
+
+// Path is where tracking URLs point, followed by the event.
+const Path = "/v1/track/"
+
+var (
+	ErrInvalidToken = errors.New("invalid tracking token")
+	ErrExpiredToken = errors.New("expired tracking token")
+)
+
+// Event is what a tracking URL reports about the campaign it was issued
+// for. Impression and click URLs of one campaign share it.
+type Event struct {
+	// ID joins the impression and click of one delivery.
+	ID          string `json:"id"`
+	CampaignID  string `json:"cid"`
+	CreativeID  string `json:"creative_id,omitempty"`
+	App         string `json:"app"`
+	Country     string `json:"country"`
+	OS          string `json:"os"`
+	PlacementID string `json:"placement_id,omitempty"`
+	// PriceCPM and Auction are set for auction winners.
+	PriceCPM float64                    `json:"price_cpm,omitempty"`
+	Auction  *models.AuctionDiagnostics `json:"auction,omitempty"`
+	IssuedAt time.Time                  `json:"issued_at"`
+}
+
+// LogAttrs describes e, reported as event, in a log record.
+func (e Event) LogAttrs(event string) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("event", event),
+		slog.String("event_id", e.ID),
+		slog.String("campaign", e.CampaignID),
+		slog.String("creative", e.CreativeID),
+		slog.String("app", e.App),
+		slog.String("country", e.Country),
+		slog.String("os", e.OS),
+		slog.String("placement_id", e.PlacementID),
+	}
+	if e.Auction != nil {
+		attrs = append(attrs, auction.LogAttrs(e.PriceCPM, *e.Auction)...)
+	}
+	return attrs
+}
+
+// Issuer issues tracking URLs and opens their tokens. A nil *Issuer issues
+// none.
+type Issuer struct {
+	aead    cipher.AEAD
+	baseURL string
+	maxAge  time.Duration
+	now     func() time.Time
+}
+
+// NewIssuer creates an issuer encrypting tokens with a key derived from
+// secret. URLs point to baseURL, or are relative when it is empty, and are
+// accepted for maxAge after they are issued.
+func NewIssuer(secret, baseURL string, maxAge time.Duration) (*Issuer, error) {
+	if secret == "" {
+		return nil, errors.New("tracking secret must not be empty")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{aead: aead, baseURL: strings.TrimSuffix(baseURL, "/"), maxAge: maxAge, now: time.Now}, nil
+}
+
+// Annotate returns a copy of list, the campaigns served for req, with
+// their tracking URLs set.
+func (i *Issuer) Annotate(req models.DeliveryRequest, list []models.Campaign) ([]models.Campaign, error) {
+	if i == nil || len(list) == 0 {
+		return list, nil
+	}
+	out := make([]models.Campaign, len(list))
+	for n, c := range list {
+		e := Event{
+			CampaignID:  c.ID,
+			App:         req.App,
+			Country:     req.Country,
+			OS:          req.OS,
+			PlacementID: req.PlacementID,
+			IssuedAt:    i.now().UTC(),
+		}
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		e.ID = hex.EncodeToString(id)
+		if c.Creative != nil {
+			e.CreativeID = c.Creative.ID
+		}
+		if c.Auction != nil {
+			d := c.Auction.Diagnostics()
+			e.PriceCPM, e.Auction = c.Auction.PriceCPM, &d
+		}
+
+		token, err := i.seal(e)
+		if err != nil {
+			return nil, err
+		}
+		c.Tracking = &models.Tracking{
+			ImpressionURL: i.url(EventImpression, token),
+			ClickURL:      i.url(EventClick, token),
+		}
+		out[n] = c
+	}
+	return out, nil
+}
+
+// Open returns the event of token, which must have been issued by an
+// issuer with the same secret no longer than maxAge ago.
+func (i *Issuer) Open(token string) (Event, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < i.aead.NonceSize() {
+		return Event{}, ErrInvalidToken
+	}
+	nonce, sealed := raw[:i.aead.NonceSize()], raw[i.aead.NonceSize():]
+	data, err := i.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return Event{}, ErrInvalidToken
+	}
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Event{}, ErrInvalidToken
+	}
+	if i.now().Sub(e.IssuedAt) > i.maxAge {
+		return Event{}, ErrExpiredToken
+	}
+	return e, nil
+}
+
+func (i *Issuer) seal(e Event) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, i.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("tracking nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(i.aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+func (i *Issuer) url(event, token string) string {
+	return i.baseURL + Path + event + "?t=" + url.QueryEscape(token)
+}
@@ -0,0 +1,137 @@
+package tracking
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auction"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+)
+
+var req = models.DeliveryRequest{App: "com.gametion.ludokinggame", Country: "US", OS: "android", PlacementID: "ludo-home-banner"}
+
+func newIssuer(t *testing.T, secret string, now time.Time) *Issuer {
+	t.Helper()
+	i, err := NewIssuer(secret, "https://ads.example.com/", time.Hour)
+	require.NoError(t, err)
+	i.now = func() time.Time { return now }
+	return i
+}
+
+// token returns the token of a tracking URL.
+func token(t *testing.T, rawURL string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return u.Query().Get("t")
+}
+
+func TestAnnotate(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	i := newIssuer(t, "secret", now)
+
+	won := auction.Run([]models.Campaign{
+		{ID: "spotify", BidCPM: 2.5, Creative: &models.Creative{ID: "spotify-banner"}},
+		{ID: "duolingo", BidCPM: 1.8},
+	}, 0.3, auction.DefaultIncrementCPM)
+	served := append(won.Campaigns(), models.Campaign{ID: "subwaysurfer"})
+
+	got, err := i.Annotate(req, served)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	for _, c := range served {
+		assert.Nil(t, c.Tracking, "the served campaigns are left untouched")
+	}
+
+	// Impression and click URLs share the event
+	tr := got[0].Tracking
+	require.NotNil(t, tr)
+	assert.True(t, strings.HasPrefix(tr.ImpressionURL, "https://ads.example.com/v1/track/impression?t="), tr.ImpressionURL)
+	assert.True(t, strings.HasPrefix(tr.ClickURL, "https://ads.example.com/v1/track/click?t="), tr.ClickURL)
+	assert.Equal(t, token(t, tr.ImpressionURL), token(t, tr.ClickURL))
+
+	e, err := i.Open(token(t, tr.ImpressionURL))
+	require.NoError(t, err)
+	assert.NotEmpty(t, e.ID)
+	e.ID = ""
+	assert.Equal(t, Event{
+		CampaignID:  "spotify",
+		CreativeID:  "spotify-banner",
+		App:         req.App,
+		Country:     "US",
+		OS:          "android",
+		PlacementID: "ludo-home-banner",
+		PriceCPM:    1.8,
+		Auction: &models.AuctionDiagnostics{
+			PriceSource: auction.PriceSecond, BidCPM: 2.5, SecondCPM: 1.8, FloorCPM: 0.3, Bidders: 2,
+		},
+		IssuedAt: now,
+	}, e)
+
+	// Campaigns served without an auction carry no price
+	e, err = i.Open(token(t, got[1].Tracking.ClickURL))
+	require.NoError(t, err)
+	assert.Equal(t, "subwaysurfer", e.CampaignID)
+	assert.Zero(t, e.PriceCPM)
+	assert.Nil(t, e.Auction)
+
+	// Every delivery gets its own event
+	again, err := i.Annotate(req, served)
+	require.NoError(t, err)
+	assert.NotEqual(t, tr.ImpressionURL, again[0].Tracking.ImpressionURL)
+
+	// Without an issuer nothing is tracked
+	got, err = (*Issuer)(nil).Annotate(req, served)
+	require.NoError(t, err)
+	assert.Equal(t, served, got)
+}
+
+func TestOpen(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	i := newIssuer(t, "secret", now)
+	got, err := i.Annotate(req, []models.Campaign{{ID: "spotify"}})
+	require.NoError(t, err)
+	valid := token(t, got[0].Tracking.ImpressionURL)
+
+	tampered := []byte(valid)
+	if tampered[10] == 'A' {
+		tampered[10] = 'B'
+	} else {
+		tampered[10] = 'A'
+	}
+
+	tests := []struct {
+		name   string
+		issuer *Issuer
+		token  string
+		err    error
+	}{
+		{"valid", i, valid, nil},
+		{"missing", i, "", ErrInvalidToken},
+		{"not base64", i, "not a token!", ErrInvalidToken},
+		{"tampered", i, string(tampered), ErrInvalidToken},
+		{"other secret", newIssuer(t, "other", now), valid, ErrInvalidToken},
+		{"at max age", newIssuer(t, "secret", now.Add(time.Hour)), valid, nil},
+		{"expired", newIssuer(t, "secret", now.Add(time.Hour+time.Second)), valid, ErrExpiredToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := tt.issuer.Open(tt.token)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "spotify", e.CampaignID)
+		})
+	}
+
+	_, err = NewIssuer("", "", time.Hour)
+	assert.Error(t, err)
+}
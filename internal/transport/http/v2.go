@@ -3,35 +3,194 @@ package httptransport
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	kithttp "github.com/go-kit/kit/transport/http"
 
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/countries"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/creatives"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/delivery"
 	"github.com/arunbajpai35/greedygame-targeting-engine/internal/endpoints"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/geo"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/logging"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/targeting"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/tracing"
 )
 
-func RegisterV2Routes(r chi.Router, eps endpoints.Endpoints) {
+// RegisterV2Routes mounts the v2 API on r, recording delivery requests in
+// reg.
+func RegisterV2Routes(r chi.Router, eps endpoints.Endpoints, reg *metrics.Registry) {
+	opts := []kithttp.ServerOption{
+		kithttp.ServerErrorEncoder(encodeError),
+	}
+
+	// Delivery is high volume: sample its request logs. Its requests are
+	// counted once answered, decode failures included.
 	server := kithttp.NewServer(
 		eps.Delivery,
 		decodeDeliveryRequest,
 		encodeDeliveryResponse,
+		append(opts,
+			kithttp.ServerBefore(sampleLogs, startTimer, auctionDebug),
+			kithttp.ServerFinalizer(observeRequest(reg)),
+		)...,
+	)
+
+	explain := kithttp.NewServer(
+		eps.Explain,
+		decodeDeliveryRequest,
+		encodeExplainResponse,
+		opts...,
 	)
 
 	r.Get("/v2/delivery", server.ServeHTTP)
+	r.Get("/v2/explain", explain.ServeHTTP)
+}
+
+func decodeDeliveryRequest(ctx context.Context, r *http.Request) (_ interface{}, err error) {
+	_, span := tracing.Start(ctx, "transport.decode")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := delivery.AuctionDebug(r); err != nil {
+		return nil, err
+	}
+	q := r.URL.Query()
+	app := strings.TrimSpace(q.Get("app"))
+	country := geo.ResolveCountry(r.Context(), strings.TrimSpace(q.Get("country")))
+	if country != "" {
+		code, ok := countries.Normalize(country)
+		if !ok {
+			return nil, errBadRequest("invalid country param")
+		}
+		country = code
+	}
+	os := strings.ToLower(strings.TrimSpace(q.Get("os")))
+	// Rejected as in v1: dropping them would let a client slip past
+	// exclude_geofence rules
+	lat, lon, err := targeting.ParseCoordinates(q.Get("lat"), q.Get("lon"))
+	if err != nil {
+		return nil, errBadRequest(err.Error())
+	}
+
+	var format, size string
+	if v := q.Get("format"); v != "" {
+		if format, err = creatives.ParseFormat(v); err != nil {
+			return nil, errBadRequest("invalid format param")
+		}
+	}
+	if v := q.Get("size"); v != "" {
+		if size, err = creatives.ParseSize(v); err != nil {
+			return nil, errBadRequest("invalid size param")
+		}
+	}
+
+	req := models.DeliveryRequest{
+		App:      app,
+		Country:  country,
+		OS:       os,
+		Region:   strings.ToLower(strings.TrimSpace(q.Get("region"))),
+		City:     strings.ToLower(strings.TrimSpace(q.Get("city"))),
+		Lat:      lat,
+		Lon:      lon,
+		DeviceID: strings.TrimSpace(q.Get("device_id")),
+		Format:   format,
+		Size:     size,
+		// Unknown placements are rejected by the service, which owns the registry
+		PlacementID: placements.NormalizeID(q.Get("placement_id")),
+	}
+	geo.FillLocation(r.Context(), &req)
+	return endpoints.DeliveryRequest(req), nil
 }
 
-func decodeDeliveryRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	app := strings.TrimSpace(r.URL.Query().Get("app"))
-	country := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("country")))
-	os := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("os")))
-	return endpoints.DeliveryRequest{App: app, Country: country, OS: os}, nil
+func sampleLogs(ctx context.Context, _ *http.Request) context.Context {
+	logging.Sample(ctx)
+	return ctx
+}
+
+type startKey struct{}
+
+func startTimer(ctx context.Context, _ *http.Request) context.Context {
+	return context.WithValue(ctx, startKey{}, time.Now())
+}
+
+type debugKey struct{}
+
+// auctionDebug marks requests whose auction diagnostics are shown.
+// decodeDeliveryRequest rejects those that may not ask for them.
+func auctionDebug(ctx context.Context, r *http.Request) context.Context {
+	debug, _ := delivery.AuctionDebug(r)
+	return context.WithValue(ctx, debugKey{}, debug)
+}
+
+// observeRequest records v2 delivery requests in reg with the status
+// vocabulary of v1. Nothing is written for cancelled requests.
+func observeRequest(reg *metrics.Registry) kithttp.ServerFinalizerFunc {
+	return func(ctx context.Context, code int, _ *http.Request) {
+		written, _ := ctx.Value(kithttp.ContextKeyResponseSize).(int64)
+		var status string
+		switch {
+		case code == http.StatusOK && written == 0 && ctx.Err() != nil:
+			status = metrics.StatusCancelled
+		case code == http.StatusOK:
+			status = metrics.StatusOK
+		case code == http.StatusNoContent:
+			status = metrics.StatusNoContent
+		case code < http.StatusInternalServerError:
+			status = metrics.StatusBadRequest
+		default:
+			status = metrics.StatusError
+		}
+		var seconds float64
+		if start, ok := ctx.Value(startKey{}).(time.Time); ok {
+			seconds = time.Since(start).Seconds()
+		}
+		reg.ObserveRequest("v2", status, seconds)
+	}
+}
+
+// errBadRequest marks a decode failure caused by the client.
+type errBadRequest string
+
+func (e errBadRequest) Error() string { return string(e) }
+
+// encodeError writes transport and decode errors in the same JSON shape as
+// v1: 403 for auction diagnostics the client may not see, 400 for other
+// client errors, 500 otherwise. Cancelled requests get no body:
+// the client is gone, or the timeout middleware answers 504.
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	logging.AddAttrs(ctx, logging.Err(err))
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, delivery.ErrDebugForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	var bad errBadRequest
+	if errors.As(err, &bad) || errors.Is(err, placements.ErrInvalid) || errors.Is(err, delivery.ErrInvalidDebug) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"error": "internal server error"})
 }
 
 func encodeDeliveryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	resp := response.(endpoints.DeliveryResponse)
+	if resp.Stale {
+		w.Header().Set(delivery.StaleHeader, "true")
+	}
 	// On empty campaigns, align with v1 behavior and return 204
 	if resp.Err == "" && len(resp.Campaigns) == 0 {
 		w.WriteHeader(http.StatusNoContent)
@@ -41,5 +200,18 @@ func encodeDeliveryResponse(ctx context.Context, w http.ResponseWriter, response
 		w.WriteHeader(http.StatusInternalServerError)
 		return json.NewEncoder(w).Encode(map[string]string{"error": resp.Err})
 	}
+	if debug, _ := ctx.Value(debugKey{}).(bool); debug {
+		return json.NewEncoder(w).Encode(models.WithAuctionDiagnostics(resp.Campaigns))
+	}
 	return json.NewEncoder(w).Encode(resp.Campaigns)
-}
\ No newline at end of file
+}
+
+func encodeExplainResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	resp := response.(endpoints.ExplainResponse)
+	if resp.Err != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		return json.NewEncoder(w).Encode(map[string]string{"error": resp.Err})
+	}
+	return json.NewEncoder(w).Encode(resp.Explanations)
+}
@@ -0,0 +1,178 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/auth"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/delivery"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/endpoints"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/metrics"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/models"
+	"github.com/arunbajpai35/greedygame-targeting-engine/internal/placements"
+)
+
+func TestDeliveryRequestMetrics(t *testing.T) {
+	spotify := []models.Campaign{{ID: "spotify", Name: "Spotify", Status: "ACTIVE"}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		query      string
+		ctx        context.Context
+		response   interface{}
+		err        error
+		wantCode   int
+		wantStatus string
+		wantStale  bool
+	}{
+		{name: "ok", response: endpoints.DeliveryResponse{Campaigns: spotify}, wantCode: http.StatusOK, wantStatus: metrics.StatusOK},
+		{name: "stale", response: endpoints.DeliveryResponse{Campaigns: spotify, Stale: true}, wantCode: http.StatusOK, wantStatus: metrics.StatusOK, wantStale: true},
+		{name: "no campaigns", response: endpoints.DeliveryResponse{Campaigns: []models.Campaign{}}, wantCode: http.StatusNoContent, wantStatus: metrics.StatusNoContent},
+		{name: "decode failure", query: "&format=billboard", wantCode: http.StatusBadRequest, wantStatus: metrics.StatusBadRequest},
+		{name: "unknown placement", err: placements.ErrInvalid, wantCode: http.StatusBadRequest, wantStatus: metrics.StatusBadRequest},
+		{name: "service error", response: endpoints.DeliveryResponse{Err: "internal server error"}, wantCode: http.StatusInternalServerError, wantStatus: metrics.StatusError},
+		{name: "cancelled", ctx: cancelled, err: context.Canceled, wantCode: http.StatusOK, wantStatus: metrics.StatusCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := metrics.New()
+
+			r := chi.NewRouter()
+			RegisterV2Routes(r, endpoints.Endpoints{
+				Delivery: func(context.Context, interface{}) (interface{}, error) { return tt.response, tt.err },
+				Explain:  func(context.Context, interface{}) (interface{}, error) { return nil, errors.New("unused") },
+			}, reg)
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/delivery?app=com.test&country=us&os=android"+tt.query, nil)
+			if tt.ctx != nil {
+				req = req.WithContext(tt.ctx)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantStale, w.Header().Get(delivery.StaleHeader) == "true")
+			assert.Equal(t, 1, testutil.CollectAndCount(reg.RequestCount))
+			assert.Equal(t, 1.0, testutil.ToFloat64(reg.RequestCount.WithLabelValues("v2", tt.wantStatus)))
+		})
+	}
+}
+
+func TestDeliveryCoordinates(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantError string
+		wantLat   *float64
+	}{
+		{name: "none", wantCode: http.StatusNoContent},
+		{name: "valid", query: "&lat=12.97&lon=77.59", wantCode: http.StatusNoContent, wantLat: ptr(12.97)},
+		{name: "malformed lat", query: "&lat=abc&lon=77.59", wantCode: http.StatusBadRequest, wantError: "invalid lat param"},
+		{name: "lat without lon", query: "&lat=12.97", wantCode: http.StatusBadRequest, wantError: "lat and lon must be supplied together"},
+		{name: "out of range", query: "&lat=12.97&lon=181", wantCode: http.StatusBadRequest, wantError: "longitude 181 out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *models.DeliveryRequest
+			r := chi.NewRouter()
+			RegisterV2Routes(r, endpoints.Endpoints{
+				Delivery: func(_ context.Context, request interface{}) (interface{}, error) {
+					req := models.DeliveryRequest(request.(endpoints.DeliveryRequest))
+					got = &req
+					return endpoints.DeliveryResponse{}, nil
+				},
+				Explain: func(context.Context, interface{}) (interface{}, error) { return nil, errors.New("unused") },
+			}, nil)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/delivery?app=com.test&country=us&os=android"+tt.query, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantError != "" {
+				assert.JSONEq(t, `{"error":"`+tt.wantError+`"}`, w.Body.String())
+				assert.Nil(t, got, "the service must not see the request")
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, tt.wantLat, got.Lat)
+		})
+	}
+}
+
+func TestDeliveryAuctionDebug(t *testing.T) {
+	won := []models.Campaign{{ID: "spotify", Auction: &models.Auction{
+		PriceCPM: 1.8, PriceSource: "second_price", BidCPM: 2.5, SecondCPM: 1.8, FloorCPM: 0.3, Bidders: 2,
+	}}}
+	admin := &auth.Key{ID: "admin", Permissions: []auth.Permission{auth.PermDelivery, auth.PermAdmin}}
+	publisher := &auth.Key{ID: "gametion", Publisher: "gametion", Permissions: []auth.Permission{auth.PermDelivery, auth.PermAdmin}}
+
+	tests := []struct {
+		name      string
+		query     string
+		key       *auth.Key
+		disabled  bool
+		wantCode  int
+		wantError string
+		wantDebug bool
+	}{
+		{name: "no debug", key: admin, wantCode: http.StatusOK},
+		{name: "global admin key", query: "&debug=auction", key: admin, wantCode: http.StatusOK, wantDebug: true},
+		{name: "authentication off", query: "&debug=auction", disabled: true, wantCode: http.StatusOK, wantDebug: true},
+		{name: "publisher key", query: "&debug=auction", key: publisher, wantCode: http.StatusForbidden, wantError: delivery.ErrDebugForbidden.Error()},
+		{name: "unknown debug", query: "&debug=bids", key: admin, wantCode: http.StatusBadRequest, wantError: delivery.ErrInvalidDebug.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			r := chi.NewRouter()
+			if tt.disabled {
+				r.Use(auth.Disabled)
+			}
+			RegisterV2Routes(r, endpoints.Endpoints{
+				Delivery: func(context.Context, interface{}) (interface{}, error) {
+					called = true
+					return endpoints.DeliveryResponse{Campaigns: won}, nil
+				},
+				Explain: func(context.Context, interface{}) (interface{}, error) { return nil, errors.New("unused") },
+			}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/delivery?app=com.test&country=us&os=android"+tt.query, nil)
+			if tt.key != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), tt.key))
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantError != "" {
+				assert.JSONEq(t, `{"error":"`+tt.wantError+`"}`, w.Body.String())
+				assert.False(t, called, "the service must not see the request")
+				return
+			}
+			var got []models.Campaign
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			require.Len(t, got, 1)
+			assert.Equal(t, 1.8, got[0].Auction.PriceCPM)
+			assert.Equal(t, tt.wantDebug, got[0].Auction.Debug != nil)
+			assert.Equal(t, tt.wantDebug, strings.Contains(w.Body.String(), `"bid_cpm":2.5`))
+		})
+	}
+}
+
+func ptr(f float64) *float64 { return &f }
@@ -1,6 +1,9 @@
 # PowerShell script to test the Targeting Engine API
 
 $BaseUrl = "http://localhost:8080"
+# Delivery needs an API key; defaults to the docker-compose bootstrap token
+$ApiKey = if ($env:API_KEY) { $env:API_KEY } else { "dev-admin-key" }
+$Headers = @{ "X-API-Key" = $ApiKey }
 
 Write-Host "🧪 Testing Targeting Engine API" -ForegroundColor Yellow
 Write-Host "==================================" -ForegroundColor Yellow
@@ -19,7 +22,7 @@ try {
 # Test successful delivery request
 Write-Host "`n2. Testing Successful Delivery Request" -ForegroundColor Yellow
 try {
-    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=com.gametion.ludokinggame&country=us&os=android" -Method Get
+    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=com.gametion.ludokinggame&country=us&os=android" -Method Get -Headers $Headers
     Write-Host "✅ Delivery request successful" -ForegroundColor Green
     Write-Host "Response: $($response | ConvertTo-Json -Depth 3)"
 } catch {
@@ -30,7 +33,7 @@ try {
 # Test delivery request with no matches
 Write-Host "`n3. Testing Delivery Request with No Matches" -ForegroundColor Yellow
 try {
-    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=com.test&country=us&os=web" -Method Get
+    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=com.test&country=us&os=web" -Method Get -Headers $Headers
     Write-Host "❌ Expected 204, but got response" -ForegroundColor Red
 } catch {
     if ($_.Exception.Response.StatusCode -eq 204) {
@@ -43,7 +46,7 @@ try {
 # Test missing parameters
 Write-Host "`n4. Testing Missing Parameters" -ForegroundColor Yellow
 try {
-    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?country=us&os=android" -Method Get
+    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?country=us&os=android" -Method Get -Headers $Headers
     Write-Host "❌ Expected 400, but got response" -ForegroundColor Red
 } catch {
     if ($_.Exception.Response.StatusCode -eq 400) {
@@ -60,7 +63,7 @@ try {
 # Test case insensitive matching
 Write-Host "`n5. Testing Case Insensitive Matching" -ForegroundColor Yellow
 try {
-    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=COM.GAMETION.LUDOKINGGAME&country=US&os=ANDROID" -Method Get
+    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=COM.GAMETION.LUDOKINGGAME&country=US&os=ANDROID" -Method Get -Headers $Headers
     Write-Host "✅ Case insensitive matching works" -ForegroundColor Green
     Write-Host "Response: $($response | ConvertTo-Json -Depth 3)"
 } catch {
@@ -71,7 +74,7 @@ try {
 # Test duolingo campaign
 Write-Host "`n6. Testing Duolingo Campaign" -ForegroundColor Yellow
 try {
-    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=com.test&country=germany&os=android" -Method Get
+    $response = Invoke-RestMethod -Uri "$BaseUrl/v1/delivery?app=com.test&country=germany&os=android" -Method Get -Headers $Headers
     Write-Host "✅ Duolingo campaign found" -ForegroundColor Green
     Write-Host "Response: $($response | ConvertTo-Json -Depth 3)"
 } catch {
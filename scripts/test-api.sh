@@ -7,6 +7,8 @@ YELLOW='\033[1;33m'
 NC='\033[0m' # No Color
 
 BASE_URL="http://localhost:8080"
+# Delivery needs an API key; defaults to the docker-compose bootstrap token
+API_KEY="${API_KEY:-dev-admin-key}"
 
 echo -e "${YELLOW}🧪 Testing Targeting Engine API${NC}"
 echo "=================================="
@@ -28,7 +30,7 @@ fi
 
 # Test successful delivery request
 echo -e "\n${YELLOW}2. Testing Successful Delivery Request${NC}"
-response=$(curl -s -w "%{http_code}" "$BASE_URL/v1/delivery?app=com.gametion.ludokinggame&country=us&os=android")
+response=$(curl -s -w "%{http_code}" -H "X-API-Key: $API_KEY" "$BASE_URL/v1/delivery?app=com.gametion.ludokinggame&country=us&os=android")
 http_code="${response: -3}"
 body="${response%???}"
 
@@ -43,7 +45,7 @@ fi
 
 # Test delivery request with no matches
 echo -e "\n${YELLOW}3. Testing Delivery Request with No Matches${NC}"
-response=$(curl -s -w "%{http_code}" "$BASE_URL/v1/delivery?app=com.test&country=us&os=web")
+response=$(curl -s -w "%{http_code}" -H "X-API-Key: $API_KEY" "$BASE_URL/v1/delivery?app=com.test&country=us&os=web")
 http_code="${response: -3}"
 
 if [ "$http_code" -eq 204 ]; then
@@ -54,7 +56,7 @@ fi
 
 # Test missing parameters
 echo -e "\n${YELLOW}4. Testing Missing Parameters${NC}"
-response=$(curl -s -w "%{http_code}" "$BASE_URL/v1/delivery?country=us&os=android")
+response=$(curl -s -w "%{http_code}" -H "X-API-Key: $API_KEY" "$BASE_URL/v1/delivery?country=us&os=android")
 http_code="${response: -3}"
 body="${response%???}"
 
@@ -68,7 +70,7 @@ fi
 
 # Test case insensitive matching
 echo -e "\n${YELLOW}5. Testing Case Insensitive Matching${NC}"
-response=$(curl -s -w "%{http_code}" "$BASE_URL/v1/delivery?app=COM.GAMETION.LUDOKINGGAME&country=US&os=ANDROID")
+response=$(curl -s -w "%{http_code}" -H "X-API-Key: $API_KEY" "$BASE_URL/v1/delivery?app=COM.GAMETION.LUDOKINGGAME&country=US&os=ANDROID")
 http_code="${response: -3}"
 body="${response%???}"
 
@@ -83,7 +85,7 @@ fi
 
 # Test duolingo campaign
 echo -e "\n${YELLOW}6. Testing Duolingo Campaign${NC}"
-response=$(curl -s -w "%{http_code}" "$BASE_URL/v1/delivery?app=com.test&country=germany&os=android")
+response=$(curl -s -w "%{http_code}" -H "X-API-Key: $API_KEY" "$BASE_URL/v1/delivery?app=com.test&country=germany&os=android")
 http_code="${response: -3}"
 body="${response%???}"
 